@@ -0,0 +1,212 @@
+//go:build js && wasm
+
+// Command wasm exposes epubverify to JavaScript when compiled with
+// GOOS=js GOARCH=wasm. It registers these global functions:
+//
+//	validateEPUB(uint8Array, onProgress?) -> string
+//	    Validates the EPUB bytes and returns the JSON report. If given,
+//	    onProgress(phase, percent) is called as each phase completes.
+//
+//	isValidEPUB(uint8Array) -> boolean
+//	    Reports whether the EPUB has no fatal errors or errors, without
+//	    building the JSON report. Bytes that cannot be read give false.
+//
+//	validateEPUBStreaming(uint8Array, onMessage) -> Promise<object>
+//	    Calls onMessage with each message object as checks report it, then
+//	    resolves with the summary {valid, fatal_count, error_count,
+//	    warning_count, info_count}. The page can render between
+//	    validation phases, but not within one.
+//
+//	repairEPUB(uint8Array) -> object
+//	    Runs doctor mode in memory and returns {report, fixes, epub}: the
+//	    JSON report for the repaired book, an array of {check_id,
+//	    description, file} objects, and the repaired EPUB as a Uint8Array
+//	    (the input bytes when nothing needed fixing).
+//
+//	profileEPUB(uint8Array) -> string
+//	    Returns the JSON structural profile (version, spine size, resource
+//	    counts, layout, scripts, media overlays) without validating content.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/adammathes/epubverify/pkg/doctor"
+	"github.com/adammathes/epubverify/pkg/report"
+	"github.com/adammathes/epubverify/pkg/validate"
+)
+
+func main() {
+	js.Global().Set("validateEPUB", js.FuncOf(validateEPUB))
+	js.Global().Set("isValidEPUB", js.FuncOf(isValidEPUB))
+	js.Global().Set("validateEPUBStreaming", js.FuncOf(validateEPUBStreaming))
+	js.Global().Set("repairEPUB", js.FuncOf(repairEPUB))
+	js.Global().Set("profileEPUB", js.FuncOf(profileEPUB))
+	select {}
+}
+
+func validateEPUB(this js.Value, args []js.Value) any {
+	if len(args) < 1 {
+		return jsError("validateEPUB: expected a Uint8Array")
+	}
+	var opts validate.Options
+	if len(args) > 1 && args[1].Type() == js.TypeFunction {
+		onProgress := args[1]
+		opts.Progress = func(phase string, pct int) {
+			onProgress.Invoke(phase, pct)
+		}
+	}
+	r, err := validate.ValidateBytes(copyBytes(args[0]), opts)
+	if err != nil {
+		return jsError(err.Error())
+	}
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		return jsError(err.Error())
+	}
+	return buf.String()
+}
+
+func isValidEPUB(this js.Value, args []js.Value) any {
+	if len(args) < 1 {
+		return false
+	}
+	// Warnings and info don't affect validity, so don't keep them.
+	r, err := validate.ValidateBytes(copyBytes(args[0]), validate.Options{MinSeverity: report.Error})
+	if err != nil {
+		return false
+	}
+	return r.IsValid()
+}
+
+func validateEPUBStreaming(this js.Value, args []js.Value) any {
+	if len(args) < 2 || args[1].Type() != js.TypeFunction {
+		return jsError("validateEPUBStreaming: expected a Uint8Array and a callback")
+	}
+	data := copyBytes(args[0])
+	onMessage := args[1]
+
+	handler := js.FuncOf(func(this js.Value, p []js.Value) any {
+		resolve, reject := p[0], p[1]
+		// Run off the calling goroutine so the Promise is returned first.
+		// Under GOOS=js the Go runtime shares the page's single thread, so
+		// validation only lets the host render or handle input where it
+		// blocks: after each phase it waits for a zero-delay timer.
+		go func() {
+			opts := validate.Options{
+				Sink: func(m report.Message) {
+					onMessage.Invoke(messageToJS(m))
+				},
+				Progress: func(string, int) {
+					yieldToHost()
+				},
+			}
+			r, err := validate.ValidateBytes(data, opts)
+			if err != nil {
+				reject.Invoke(jsError(err.Error()))
+				return
+			}
+			resolve.Invoke(map[string]any{
+				"valid":         r.IsValid(),
+				"fatal_count":   r.FatalCount(),
+				"error_count":   r.ErrorCount(),
+				"warning_count": r.WarningCount(),
+				"info_count":    r.InfoCount(),
+			})
+		}()
+		return nil
+	})
+	defer handler.Release()
+
+	return js.Global().Get("Promise").New(handler)
+}
+
+// yieldToHost blocks until a zero-delay setTimeout fires, returning
+// control to the JavaScript event loop in the meantime.
+func yieldToHost() {
+	done := make(chan struct{})
+	cb := js.FuncOf(func(js.Value, []js.Value) any {
+		close(done)
+		return nil
+	})
+	defer cb.Release()
+	js.Global().Call("setTimeout", cb, 0)
+	<-done
+}
+
+func repairEPUB(this js.Value, args []js.Value) any {
+	if len(args) < 1 {
+		return jsError("repairEPUB: expected a Uint8Array")
+	}
+	result, repaired, err := doctor.RepairBytes(copyBytes(args[0]))
+	if err != nil {
+		return jsError(err.Error())
+	}
+	var buf bytes.Buffer
+	if err := result.AfterReport.WriteJSON(&buf); err != nil {
+		return jsError(err.Error())
+	}
+
+	fixes := js.Global().Get("Array").New()
+	for _, fix := range result.Fixes {
+		obj := js.Global().Get("Object").New()
+		obj.Set("check_id", fix.CheckID)
+		obj.Set("description", fix.Description)
+		obj.Set("file", fix.File)
+		fixes.Call("push", obj)
+	}
+
+	epub := js.Global().Get("Uint8Array").New(len(repaired))
+	js.CopyBytesToJS(epub, repaired)
+
+	out := js.Global().Get("Object").New()
+	out.Set("report", buf.String())
+	out.Set("fixes", fixes)
+	out.Set("epub", epub)
+	return out
+}
+
+func profileEPUB(this js.Value, args []js.Value) any {
+	if len(args) < 1 {
+		return jsError("profileEPUB: expected a Uint8Array")
+	}
+	p, err := validate.ProfileBytes(copyBytes(args[0]))
+	if err != nil {
+		return jsError(err.Error())
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return jsError(err.Error())
+	}
+	return string(data)
+}
+
+// messageToJS converts a message to a plain JS object using the same
+// field names as the JSON report.
+func messageToJS(m report.Message) js.Value {
+	obj := js.Global().Get("Object").New()
+	obj.Set("severity", string(m.Severity))
+	obj.Set("check_id", m.CheckID)
+	obj.Set("message", m.Message)
+	if m.Location != "" {
+		obj.Set("location", m.Location)
+	}
+	if m.Line > 0 {
+		obj.Set("line", m.Line)
+		obj.Set("column", m.Column)
+	}
+	return obj
+}
+
+// copyBytes copies a JS Uint8Array into Go memory.
+func copyBytes(v js.Value) []byte {
+	data := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(data, v)
+	return data
+}
+
+func jsError(msg string) js.Value {
+	return js.Global().Get("Error").New(msg)
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/adammathes/epubverify/pkg/doctor"
 	"github.com/adammathes/epubverify/pkg/report"
@@ -15,7 +18,7 @@ func main() {
 	args := os.Args[1:]
 
 	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "Usage: epubverify <file.epub> [--json <output.json | ->] [--doctor [-o output.epub]] [--version]")
+		fmt.Fprintln(os.Stderr, "Usage: epubverify <file.epub | dir> [--json <output.json | ->] [--ids] [--sarif] [--junit] [--markdown] [--group <file|check>] [--no-color] [--rootfile <path> | --all-renditions] [--doctor [-o output.epub | --dry-run] [--straight-quotes | --curly-quotes] [--normalize-whitespace] [--remove-unlisted]] [--version]")
 		os.Exit(2)
 	}
 
@@ -29,51 +32,181 @@ func main() {
 
 	epubPath := args[0]
 	var jsonOutput string
+	var idsOnly bool
+	var sarif bool
+	var junit bool
+	var markdown bool
+	var groupBy string
+	var noColor bool
 	var doctorMode bool
 	var doctorOutput string
+	var dryRun bool
+	var repairOpts doctor.RepairOptions
+	var opts validate.Options
 
 	for i := 1; i < len(args); i++ {
 		if args[i] == "--json" && i+1 < len(args) {
+			if jsonOutput != "" && jsonOutput != args[i+1] {
+				fmt.Fprintln(os.Stderr, "--json given more than once")
+				os.Exit(2)
+			}
 			jsonOutput = args[i+1]
 			i++
 		}
+		if args[i] == "--ids" {
+			idsOnly = true
+		}
+		if args[i] == "--sarif" {
+			sarif = true
+		}
+		if args[i] == "--junit" {
+			junit = true
+		}
+		if args[i] == "--markdown" {
+			markdown = true
+		}
+		if args[i] == "--group" && i+1 < len(args) {
+			if groupBy != "" && groupBy != args[i+1] {
+				fmt.Fprintln(os.Stderr, "--group given more than once")
+				os.Exit(2)
+			}
+			groupBy = args[i+1]
+			i++
+		}
+		if args[i] == "--no-color" {
+			noColor = true
+		}
+		if args[i] == "--rootfile" && i+1 < len(args) {
+			opts.Rootfile = args[i+1]
+			i++
+		}
+		if args[i] == "--all-renditions" {
+			opts.AllRenditions = true
+		}
 		if args[i] == "--doctor" {
 			doctorMode = true
 		}
+		if args[i] == "--straight-quotes" {
+			repairOpts.StraightQuotes = true
+		}
+		if args[i] == "--curly-quotes" {
+			repairOpts.CurlyQuotes = true
+		}
+		if args[i] == "--normalize-whitespace" {
+			repairOpts.NormalizeWhitespace = true
+		}
+		if args[i] == "--dry-run" {
+			dryRun = true
+		}
+		if args[i] == "--remove-unlisted" {
+			repairOpts.RemoveUnlisted = true
+		}
 		if args[i] == "-o" && i+1 < len(args) {
 			doctorOutput = args[i+1]
 			i++
 		}
 	}
 
+	// The output formats replace one another, and --json names a JSON
+	// file, so at most one format may be picked and only --ids goes with --json
+	var formats []string
+	for _, f := range []struct {
+		set  bool
+		flag string
+	}{{idsOnly, "--ids"}, {sarif, "--sarif"}, {junit, "--junit"}, {markdown, "--markdown"}} {
+		if f.set {
+			formats = append(formats, f.flag)
+		}
+	}
+	if len(formats) > 1 {
+		fmt.Fprintf(os.Stderr, "%s cannot be used together\n", strings.Join(formats, " and "))
+		os.Exit(2)
+	}
+	if len(formats) == 1 && !idsOnly && jsonOutput != "" {
+		fmt.Fprintf(os.Stderr, "--json cannot be used with %s\n", formats[0])
+		os.Exit(2)
+	}
+
+	// --all-renditions has no effect once a single rootfile is picked
+	if opts.Rootfile != "" && opts.AllRenditions {
+		fmt.Fprintln(os.Stderr, "--rootfile cannot be used with --all-renditions")
+		os.Exit(2)
+	}
+
+	if doctorMode && dryRun {
+		runDoctorPlan(epubPath, repairOpts)
+		return
+	}
 	if doctorMode {
-		runDoctor(epubPath, doctorOutput)
+		runDoctor(epubPath, doctorOutput, repairOpts)
 		return
 	}
 
-	r, err := validate.Validate(epubPath)
+	validateFn := validate.ValidateWithOptions
+	// An unpacked book is validated in place, without zipping it first
+	if fi, err := os.Stat(epubPath); err == nil && fi.IsDir() {
+		validateFn = validate.ValidateDir
+	}
+	r, err := validateFn(epubPath, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Fatal: %v\n", err)
 		os.Exit(2)
 	}
 
-	// Text output to stderr
-	r.WriteText(os.Stderr)
+	// Text output to stderr; --group switches to the grouped, colored report
+	switch groupBy {
+	case "":
+		r.WriteText(os.Stderr)
+	case "file", "check":
+		textOpts := report.TextOptions{NoColor: noColor || !isTerminal(os.Stderr)}
+		if groupBy == "check" {
+			textOpts.GroupBy = report.GroupByCheck
+		}
+		r.TextReport(os.Stderr, textOpts)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown --group %q: use file or check\n", groupBy)
+		os.Exit(2)
+	}
+
+	// --ids switches JSON output to just the sorted, distinct check IDs
+	writeOutput := r.WriteJSON
+	format := "JSON"
+	if idsOnly {
+		writeOutput = r.WriteCheckIDsJSON
+	}
+	// --sarif switches it to SARIF 2.1.0 for code-scanning annotations
+	if sarif {
+		writeOutput = r.WriteSARIF
+		format = "SARIF"
+	}
+	// --junit switches it to a JUnit XML test suite for CI test reports
+	if junit {
+		writeOutput = func(w io.Writer) error {
+			return r.WriteJUnitXML(w, filepath.Base(epubPath))
+		}
+		format = "JUnit XML"
+	}
+
+	// --markdown switches it to a Markdown table for pull request comments
+	if markdown {
+		writeOutput = r.MarkdownReport
+		format = "Markdown"
+	}
 
 	// JSON output: always write to stdout for tool interop, and to file if --json specified
 	if jsonOutput == "" || jsonOutput == "-" {
-		if err := r.WriteJSON(os.Stdout); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
+		if err := writeOutput(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", format, err)
 			os.Exit(2)
 		}
 	} else {
 		// Write to both stdout (for piping) and the specified file
-		if err := r.WriteJSON(os.Stdout); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
+		if err := writeOutput(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", format, err)
 			os.Exit(2)
 		}
-		if err := writeJSON(r, jsonOutput); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
+		if err := writeJSON(writeOutput, jsonOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", format, err)
 			os.Exit(2)
 		}
 	}
@@ -88,8 +221,8 @@ func main() {
 	os.Exit(0)
 }
 
-func runDoctor(inputPath, outputPath string) {
-	result, err := doctor.Repair(inputPath, outputPath)
+func runDoctor(inputPath, outputPath string, opts doctor.RepairOptions) {
+	result, err := doctor.RepairWithOptions(inputPath, outputPath, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Doctor error: %v\n", err)
 		os.Exit(2)
@@ -100,6 +233,7 @@ func runDoctor(inputPath, outputPath string) {
 
 	if len(result.Fixes) == 0 {
 		fmt.Fprintf(os.Stderr, "No fixable issues found (%d errors, %d warnings remain).\n", beforeErrors, beforeWarnings)
+		printSkipped(result.Skipped)
 		os.Exit(0)
 	}
 
@@ -112,6 +246,8 @@ func runDoctor(inputPath, outputPath string) {
 		}
 	}
 
+	printSkipped(result.Skipped)
+
 	afterErrors := result.AfterReport.ErrorCount() + result.AfterReport.FatalCount()
 	afterWarnings := result.AfterReport.WarningCount()
 
@@ -124,14 +260,66 @@ func runDoctor(inputPath, outputPath string) {
 	fmt.Fprintf(os.Stderr, "Output: %s\n", outputPath)
 }
 
-func writeJSON(r *report.Report, path string) error {
+// runDoctorPlan lists the fixes doctor would make without writing a file.
+func runDoctorPlan(inputPath string, opts doctor.RepairOptions) {
+	result, err := doctor.PlanWithOptions(inputPath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Doctor error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if len(result.Fixes) == 0 {
+		fmt.Fprintf(os.Stderr, "No fixable issues found (%d errors, %d warnings remain).\n",
+			result.BeforeReport.ErrorCount()+result.BeforeReport.FatalCount(), result.BeforeReport.WarningCount())
+	} else {
+		fmt.Fprintf(os.Stderr, "Would apply %d fixes:\n", len(result.Fixes))
+		for _, fix := range result.Fixes {
+			if fix.File != "" {
+				fmt.Fprintf(os.Stderr, "  [%s] %s (%s)\n", fix.CheckID, fix.Description, fix.File)
+			} else {
+				fmt.Fprintf(os.Stderr, "  [%s] %s\n", fix.CheckID, fix.Description)
+			}
+		}
+	}
+	printSkipped(result.Skipped)
+
+	if len(result.Fixes) > 0 {
+		fmt.Fprintf(os.Stderr, "\nBefore:            %d errors, %d warnings\n",
+			result.BeforeReport.ErrorCount()+result.BeforeReport.FatalCount(), result.BeforeReport.WarningCount())
+		fmt.Fprintf(os.Stderr, "After (projected): %d errors, %d warnings\n",
+			result.AfterReport.ErrorCount()+result.AfterReport.FatalCount(), result.AfterReport.WarningCount())
+	}
+}
+
+// printSkipped lists the checks doctor left for manual editing.
+func printSkipped(skipped []doctor.SkippedFix) {
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\nSkipped %d checks:\n", len(skipped))
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "  [%s] %s\n", s.CheckID, s.Reason)
+	}
+}
+
+func writeJSON(write func(io.Writer) error, path string) error {
 	if path == "-" {
-		return r.WriteJSON(os.Stdout)
+		return write(os.Stdout)
 	}
 	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	return r.WriteJSON(f)
+	return write(f)
+}
+
+// isTerminal reports whether f is a character device, and so likely to
+// render ANSI colors. NO_COLOR turns colors off regardless.
+func isTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
 }
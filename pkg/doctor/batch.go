@@ -0,0 +1,101 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BatchSummary aggregates the results of a RepairAll run.
+type BatchSummary struct {
+	Files        int            // EPUBs processed
+	Repaired     int            // EPUBs that had at least one fix applied
+	Failed       int            // EPUBs that could not be opened or written
+	Fixes        int            // fixes applied across all EPUBs
+	Unfixable    int            // errors and fatals remaining after repair
+	FixesByCheck map[string]int // fixes applied per check ID
+}
+
+// BatchResult is the outcome of repairing one file in a RepairAll run.
+type BatchResult struct {
+	*Result // nil when Err is set
+
+	Input  string // path of the EPUB that was repaired
+	Output string // path of the repaired copy; empty if nothing was written
+	Err    error  // why the file could not be repaired
+}
+
+// RepairAll repairs every .epub file directly inside inputDir, writing
+// fixed copies under the same names in outputDir. A file that fails to
+// repair does not stop the batch; its BatchResult carries the error in Err.
+// As with Repair, no output is written for files that need no fixes.
+func RepairAll(inputDir, outputDir string, opts RepairOptions) ([]BatchResult, error) {
+	entries, err := os.ReadDir(inputDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading input directory: %w", err)
+	}
+	inAbs, err := filepath.Abs(inputDir)
+	if err != nil {
+		return nil, err
+	}
+	outAbs, err := filepath.Abs(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if inAbs == outAbs {
+		return nil, fmt.Errorf("output directory must differ from input directory")
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating output directory: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".epub") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	results := make([]BatchResult, 0, len(names))
+	for _, name := range names {
+		in := filepath.Join(inputDir, name)
+		out := filepath.Join(outputDir, name)
+		res, err := RepairWithOptions(in, out, opts)
+		if err != nil {
+			results = append(results, BatchResult{Input: in, Output: out, Err: err})
+			continue
+		}
+		br := BatchResult{Result: res, Input: in}
+		if len(res.Fixes) > 0 {
+			br.Output = out
+		}
+		results = append(results, br)
+	}
+	return results, nil
+}
+
+// Summarize totals the fixes and remaining problems across batch results.
+func Summarize(results []BatchResult) BatchSummary {
+	s := BatchSummary{FixesByCheck: make(map[string]int)}
+	for _, res := range results {
+		s.Files++
+		if res.Err != nil {
+			s.Failed++
+			continue
+		}
+		if len(res.Fixes) > 0 {
+			s.Repaired++
+		}
+		s.Fixes += len(res.Fixes)
+		for _, f := range res.Fixes {
+			s.FixesByCheck[f.CheckID]++
+		}
+		if res.AfterReport != nil {
+			s.Unfixable += res.AfterReport.ErrorCount() + res.AfterReport.FatalCount()
+		}
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepairAll(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter</title></head>
+<body><p>Hi</p></body></html>`
+
+	inDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "fixed")
+
+	data, err := os.ReadFile(createCustomEPUB(t, opf, chapter, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string][]byte{
+		"a.epub":      data,
+		"b.EPUB":      data,
+		"broken.epub": []byte("not a zip"),
+		"notes.txt":   []byte("ignored"),
+	} {
+		if err := os.WriteFile(filepath.Join(inDir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := RepairAll(inDir, outDir, RepairOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3 (txt file ignored)", len(results))
+	}
+
+	s := Summarize(results)
+	if s.Files != 3 || s.Repaired != 2 || s.Failed != 1 {
+		t.Errorf("summary = %+v, want 3 files, 2 repaired, 1 failed", s)
+	}
+	if s.FixesByCheck["OPF-004"] != 2 {
+		t.Errorf("FixesByCheck[OPF-004] = %d, want 2", s.FixesByCheck["OPF-004"])
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			if filepath.Base(res.Input) != "broken.epub" {
+				t.Errorf("unexpected error for %s: %v", res.Input, res.Err)
+			}
+			continue
+		}
+		if _, err := os.Stat(res.Output); err != nil {
+			t.Errorf("missing output for %s: %v", res.Input, err)
+		}
+	}
+
+	if _, err := RepairAll(inDir, inDir, RepairOptions{}); err == nil {
+		t.Error("expected an error when output directory equals input directory")
+	}
+}
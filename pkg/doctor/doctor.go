@@ -8,17 +8,29 @@
 //  4. Write a new EPUB with all fixes applied
 //  5. Re-validate the output to confirm fixes worked
 //
+// Plan stops after step 3, as a dry run that reports the fixes without
+// writing anything.
+//
 // Tier 1 fixes (safe, deterministic, content-preserving):
 //   - OCF-001/002/003/004/005: mimetype file issues — all handled by correct ZIP writing
 //   - OPF-004: missing dcterms:modified — adds current timestamp
 //   - OPF-024/MED-001: media-type mismatch — corrects based on file magic bytes
+//   - FONT-004: legacy font media-type — replaces with font/otf, font/ttf, or font/woff
+//   - OPF-074: misspelled media-type (e.g. image/jpg) — replaces with the core media type
+//   - FXL-009: fixed viewports without rendition:layout — declares pre-paginated
 //   - HTM-005/006/007: missing manifest properties — adds scripted/svg/mathml
 //   - HTM-010/011: wrong DOCTYPE — replaces with <!DOCTYPE html>
+//   - XML-002: whitespace before the XML declaration — strips it
+//   - ENC-011: UTF-8 byte order mark in XML or CSS — strips it
 //
 // Tier 2 fixes (low-to-medium complexity, still safe):
 //   - OPF-039: deprecated <guide> element in EPUB 3 — removes it
+//   - OPF-001/002/003: missing dc:title/identifier/language — adds placeholders ("Untitled", generated UUID, "und")
 //   - OPF-036: bad dc:date format — reformats to W3CDTF
+//   - OPF-020/076: malformed or non-canonical dc:language — normalizes ("EN_us" → "en-US")
+//   - RSC-002: OS junk files (.DS_Store, Thumbs.db, __MACOSX/) — removes them
 //   - RSC-002: files in container but not in manifest — adds manifest entries
+//   - NAV-001: no EPUB 3 nav document — converts the NCX, or lists the spine documents
 //   - HTM-003: empty href="" on <a> elements — removes the href attribute
 //   - HTM-004: obsolete HTML elements (center, big, strike, tt, etc.) — replaces with styled modern equivalents
 //
@@ -26,21 +38,31 @@
 //   - CSS-005: @import rules — inlines imported CSS content
 //   - ENC-001: non-UTF-8 encoding declaration — transcodes (iso-8859-1, windows-1252) or fixes declaration
 //   - ENC-002: UTF-16 encoded content — transcodes to UTF-8
+//   - ENC-010: UTF-16 declaration on UTF-8 content — fixes declaration
 //
 // Tier 4 fixes (cleanup and consistency):
 //   - OPF-028: multiple dcterms:modified — removes duplicates
 //   - OPF-033: fragment in manifest href — strips fragment identifier
 //   - OPF-017: duplicate spine idrefs — removes duplicate itemrefs
 //   - OPF-038: invalid spine linear value — normalizes to "yes"/"no"
+//   - OPF-081: duplicate tokens or stray whitespace in properties — normalizes the list
+//   - OPF-060: no cover-image property — adds it to the image named by <meta name="cover"> or the only image called "cover"
 //   - HTM-009: <base> element present — removes it
 //   - HTM-020: processing instructions — removes non-XML PIs
 //   - HTM-026: lang/xml:lang mismatch — syncs lang to match xml:lang
 //   - HTM-002: missing <title> element — adds <title>Untitled</title>
+//   - ACC-003: html element without lang/xml:lang — adds them from dc:language
+//
+// Opt-in fixes (enabled through RepairOptions):
+//   - Typography: curly/straight quote conversion and unusual whitespace
+//     normalization in text content
+//   - RemoveUnlisted: removes files not in the manifest instead of listing them
 package doctor
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"io"
 
 	"github.com/adammathes/epubverify/pkg/epub"
 	"github.com/adammathes/epubverify/pkg/report"
@@ -52,45 +74,273 @@ type Result struct {
 	Fixes       []Fix
 	BeforeReport *report.Report
 	AfterReport  *report.Report
+
+	// Skipped lists the checks in BeforeReport that doctor has no fix
+	// for, so they are left to be corrected by hand.
+	Skipped []SkippedFix
+}
+
+// RepairOptions enables opt-in repairs that change content beyond what is
+// needed to pass validation. The zero value applies only the standard fixes.
+type RepairOptions struct {
+	// StraightQuotes converts curly quotes in text content to straight
+	// ASCII quotes.
+	StraightQuotes bool
+
+	// CurlyQuotes converts straight quotes in text content to typographic
+	// quotes. It cannot be combined with StraightQuotes.
+	CurlyQuotes bool
+
+	// NormalizeWhitespace replaces no-break, thin, and other unusual spaces
+	// in text content with a plain space and removes zero-width spaces.
+	NormalizeWhitespace bool
+
+	// RemoveUnlisted removes container files that are not in the manifest,
+	// other than mimetype, the package document and META-INF, instead of
+	// adding them to the manifest.
+	RemoveUnlisted bool
+
+	// MaxDecompressedBytes caps how many bytes any single entry may
+	// decompress to while the book is read into memory. Larger entries are
+	// copied through unrepaired. Zero uses validate.DefaultMaxDecompressedBytes.
+	MaxDecompressedBytes int64
+}
+
+// maxDecompressedBytes returns the per-entry decompression cap to use.
+func (o RepairOptions) maxDecompressedBytes() int64 {
+	if o.MaxDecompressedBytes > 0 {
+		return o.MaxDecompressedBytes
+	}
+	return validate.DefaultMaxDecompressedBytes
+}
+
+// typography reports whether any text normalization is enabled.
+func (o RepairOptions) typography() bool {
+	return o.StraightQuotes || o.CurlyQuotes || o.NormalizeWhitespace
 }
 
 // Repair opens an EPUB, applies fixes, and writes the repaired version.
 // If outputPath is empty, it writes to inputPath with a ".fixed.epub" suffix.
 func Repair(inputPath, outputPath string) (*Result, error) {
+	return RepairWithOptions(inputPath, outputPath, RepairOptions{})
+}
+
+// RepairWithOptions is like Repair but also applies the opt-in repairs
+// enabled in opts.
+func RepairWithOptions(inputPath, outputPath string, opts RepairOptions) (*Result, error) {
 	if outputPath == "" {
 		outputPath = inputPath + ".fixed.epub"
 	}
 
+	// Steps 1 to 3: validate and apply fixes in memory
+	result, ep, files, err := plan(inputPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer ep.Close()
+	if len(result.Fixes) == 0 {
+		return result, nil
+	}
+
+	// Step 4: Write repaired EPUB
+	// The writer handles OCF-002 (mimetype first), OCF-004 (no extra field),
+	// and OCF-005 (stored not compressed) by construction.
+	if err := writeEPUB(outputPath, files, ep.Zip); err != nil {
+		return nil, fmt.Errorf("writing repaired epub: %w", err)
+	}
+
+	// Step 5: Re-validate to confirm
+	afterReport, err := validate.ValidateWithOptions(outputPath, validate.Options{MaxDecompressedBytes: opts.MaxDecompressedBytes})
+	if err != nil {
+		return nil, fmt.Errorf("validating repaired epub: %w", err)
+	}
+	result.AfterReport = afterReport
+	return result, nil
+}
+
+// Plan is a dry run of Repair: it validates the EPUB and applies the fixes
+// in memory, then returns the fixes it would make without writing a file.
+// AfterReport is the projected validation of the repaired book, which is
+// built and validated in memory, or BeforeReport when there are no fixes.
+func Plan(inputPath string) (*Result, error) {
+	return PlanWithOptions(inputPath, RepairOptions{})
+}
+
+// PlanWithOptions is like Plan but also plans the opt-in repairs enabled
+// in opts.
+func PlanWithOptions(inputPath string, opts RepairOptions) (*Result, error) {
+	result, ep, files, err := plan(inputPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer ep.Close()
+	if len(result.Fixes) == 0 {
+		return result, nil
+	}
+
+	var buf bytes.Buffer
+	if err := writeEPUBTo(&buf, files, ep.Zip); err != nil {
+		return nil, fmt.Errorf("building repaired epub: %w", err)
+	}
+	afterReport, err := validate.ValidateBytes(buf.Bytes(), validate.Options{MaxDecompressedBytes: opts.MaxDecompressedBytes})
+	if err != nil {
+		return nil, fmt.Errorf("validating repaired epub: %w", err)
+	}
+	result.AfterReport = afterReport
+	return result, nil
+}
+
+// plan opens and validates the EPUB at inputPath and applies the fixes to
+// an in-memory copy of its files. The caller must close the returned EPUB.
+// When nothing needs fixing the result's AfterReport is its BeforeReport;
+// otherwise AfterReport is left for the caller to fill in.
+func plan(inputPath string, opts RepairOptions) (*Result, *epub.EPUB, map[string][]byte, error) {
+	if opts.StraightQuotes && opts.CurlyQuotes {
+		return nil, nil, nil, fmt.Errorf("StraightQuotes and CurlyQuotes cannot both be set")
+	}
+
 	// Step 1: Open and validate original
 	ep, err := epub.Open(inputPath)
 	if err != nil {
-		return nil, fmt.Errorf("opening epub: %w", err)
+		return nil, nil, nil, fmt.Errorf("opening epub: %w", err)
 	}
+	ep.MaxDecompressedBytes = opts.maxDecompressedBytes()
 
-	beforeReport, err := validate.Validate(inputPath)
+	beforeReport, err := validate.ValidateWithOptions(inputPath, validate.Options{MaxDecompressedBytes: opts.MaxDecompressedBytes})
 	if err != nil {
 		ep.Close()
-		return nil, fmt.Errorf("validating: %w", err)
+		return nil, nil, nil, fmt.Errorf("validating: %w", err)
+	}
+	result := &Result{
+		BeforeReport: beforeReport,
+		AfterReport:  beforeReport,
+		Skipped:      skippedFixes(beforeReport),
 	}
 
-	// If already valid, nothing to do
-	if beforeReport.IsValid() && beforeReport.WarningCount() == 0 {
+	// If already valid and no opt-in repairs were requested, nothing to do
+	if beforeReport.IsValid() && beforeReport.WarningCount() == 0 && !opts.typography() && !opts.RemoveUnlisted && !hasOSJunk(ep) {
+		return result, ep, nil, nil
+	}
+
+	// Steps 2 and 3: Read all files into memory and apply fixes
+	files, allFixes, err := applyFixes(ep, beforeReport, opts)
+	if err != nil {
 		ep.Close()
-		return &Result{
-			BeforeReport: beforeReport,
-			AfterReport:  beforeReport,
-		}, nil
+		return nil, nil, nil, err
+	}
+	if len(allFixes) > 0 {
+		result.Fixes = allFixes
+		result.AfterReport = nil
+	}
+	return result, ep, files, nil
+}
+
+// RepairBytes is like Repair for an EPUB held in memory, as in the browser
+// build. It returns the result and the repaired EPUB, which is data itself
+// when nothing needed fixing.
+func RepairBytes(data []byte) (*Result, []byte, error) {
+	ep, err := epub.OpenFromBytes(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening epub: %w", err)
 	}
+	defer ep.Close()
+	ep.MaxDecompressedBytes = validate.DefaultMaxDecompressedBytes
 
-	// Step 2: Read all files into memory
+	beforeReport, err := validate.ValidateBytes(data, validate.Options{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("validating: %w", err)
+	}
+	unchanged := &Result{BeforeReport: beforeReport, AfterReport: beforeReport, Skipped: skippedFixes(beforeReport)}
+	if beforeReport.IsValid() && beforeReport.WarningCount() == 0 && !hasOSJunk(ep) {
+		return unchanged, data, nil
+	}
+
+	files, allFixes, err := applyFixes(ep, beforeReport, RepairOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(allFixes) == 0 {
+		return unchanged, data, nil
+	}
+
+	var buf bytes.Buffer
+	if err := writeEPUBTo(&buf, files, ep.Zip); err != nil {
+		return nil, nil, fmt.Errorf("writing repaired epub: %w", err)
+	}
+	repaired := buf.Bytes()
+
+	afterReport, err := validate.ValidateBytes(repaired, validate.Options{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("validating repaired epub: %w", err)
+	}
+
+	return &Result{
+		Fixes:        allFixes,
+		BeforeReport: beforeReport,
+		AfterReport:  afterReport,
+		Skipped:      skippedFixes(beforeReport),
+	}, repaired, nil
+}
+
+// SkippedFix is a check found before repair that doctor cannot fix.
+type SkippedFix struct {
+	CheckID string
+	Reason  string
+}
+
+// skippedFixes returns one SkippedFix per fatal, error or warning check ID
+// in before that doctor has no fix for, in check ID order. Whether a check
+// is fixable comes from report.Remediation, which is kept in step with the
+// fixes in this package.
+func skippedFixes(before *report.Report) []SkippedFix {
+	present := make(map[string]bool)
+	for _, m := range before.Messages {
+		if m.Severity != report.Info && m.Severity != report.Usage {
+			present[m.CheckID] = true
+		}
+	}
+	var skipped []SkippedFix
+	for _, rem := range before.Remediation() {
+		if !present[rem.CheckID] || rem.DoctorFixable {
+			continue
+		}
+		skipped = append(skipped, SkippedFix{
+			CheckID: rem.CheckID,
+			Reason:  "requires manual editing: " + rem.Suggestion,
+		})
+	}
+	return skipped
+}
+
+// hasOSJunk reports whether ep contains files that fixExtraneousFiles
+// always removes. The validator does not flag them, so a book can be
+// valid and still need repair.
+func hasOSJunk(ep *epub.EPUB) bool {
+	for name := range ep.Files {
+		if isOSJunk(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyFixes reads every entry of ep into memory and applies the fixes in
+// tier order, returning the modified files and the fixes made. A nil entry
+// in files marks a file a fix removed. It fails if an entry decompresses
+// past ep.MaxDecompressedBytes despite a smaller declared size.
+func applyFixes(ep *epub.EPUB, beforeReport *report.Report, opts RepairOptions) (map[string][]byte, []Fix, error) {
+	// Read all files into memory
 	files := make(map[string][]byte)
 	for name, f := range ep.Files {
-		rc, err := f.Open()
-		if err != nil {
+		// Entries declared larger than the cap, such as video, are left
+		// out and copied through unchanged without being decompressed.
+		if limit := ep.MaxDecompressedBytes; limit > 0 && f.UncompressedSize64 > uint64(limit) {
 			continue
 		}
-		data, err := io.ReadAll(rc)
-		rc.Close()
+		data, err := ep.ReadFile(name)
+		if errors.Is(err, epub.ErrDecompressionLimit) {
+			return nil, nil, fmt.Errorf("reading epub: %w", err)
+		}
 		if err != nil {
 			continue
 		}
@@ -102,7 +352,6 @@ func Repair(inputPath, outputPath string) (*Result, error) {
 	ep.ParseContainer()
 	ep.ParseOPF()
 
-	// Step 3: Apply fixes
 	var allFixes []Fix
 
 	// ZIP-level: ensure correct mimetype (also fixes OCF-001 if missing)
@@ -118,23 +367,48 @@ func Repair(inputPath, outputPath string) (*Result, error) {
 	// OPF-level: correct media-type mismatches
 	allFixes = append(allFixes, fixMediaTypes(files, ep)...)
 
+	// OPF-level: correct misspelled media-type values
+	allFixes = append(allFixes, fixMediaTypeTypos(files, ep)...)
+
+	// OPF-level: declare fixed layout for fixed-viewport content
+	allFixes = append(allFixes, fixMissingRenditionLayout(files, ep)...)
+
 	// OPF-level: add missing manifest properties (scripted/svg/mathml)
 	allFixes = append(allFixes, fixManifestProperties(files, ep)...)
 
 	// Content-level: fix DOCTYPE declarations
 	allFixes = append(allFixes, fixDoctype(files, ep)...)
 
+	// Content-level: strip whitespace before XML declarations
+	allFixes = append(allFixes, fixLeadingXMLDeclaration(files, ep)...)
+
+	// Content-level: strip UTF-8 byte order marks from XML and CSS
+	allFixes = append(allFixes, fixUTF8BOM(files, ep)...)
+
 	// --- Tier 2 fixes ---
 
 	// OPF-level: remove deprecated <guide> element (EPUB 3)
 	allFixes = append(allFixes, fixGuideElement(files, ep)...)
 
+	// OPF-level: add placeholder required dc elements
+	allFixes = append(allFixes, fixMissingRequiredMetadata(files, ep)...)
+
 	// OPF-level: reformat bad dc:date values
 	allFixes = append(allFixes, fixDCDateFormat(files, ep)...)
 
+	// OPF-level: normalize dc:language tags
+	allFixes = append(allFixes, fixLanguageTags(files, ep)...)
+
+	// Container-level: remove OS junk and, if asked, other unlisted files
+	allFixes = append(allFixes, fixExtraneousFiles(files, ep, opts)...)
+
 	// OPF-level: add unlisted container files to manifest
 	allFixes = append(allFixes, fixFilesNotInManifest(files, ep)...)
 
+	// OPF-level: generate a nav document if there is none (after RSC-002,
+	// so the new file is not listed twice)
+	allFixes = append(allFixes, fixMissingNav(files, ep)...)
+
 	// Content-level: remove empty href attributes
 	allFixes = append(allFixes, fixEmptyHref(files, ep)...)
 
@@ -163,6 +437,12 @@ func Repair(inputPath, outputPath string) (*Result, error) {
 	// OPF-level: fix invalid spine linear attribute values
 	allFixes = append(allFixes, fixInvalidLinear(files, ep)...)
 
+	// OPF-level: normalize manifest properties lists
+	allFixes = append(allFixes, fixPropertiesTokens(files, ep)...)
+
+	// OPF-level: mark the cover image with the cover-image property
+	allFixes = append(allFixes, fixCoverImageProperty(files, ep)...)
+
 	// Content-level: remove <base> elements
 	allFixes = append(allFixes, fixBaseElement(files, ep)...)
 
@@ -175,35 +455,15 @@ func Repair(inputPath, outputPath string) (*Result, error) {
 	// Content-level: add missing <title> element
 	allFixes = append(allFixes, fixMissingTitle(files, ep)...)
 
-	if len(allFixes) == 0 {
-		ep.Close()
-		return &Result{
-			BeforeReport: beforeReport,
-			AfterReport:  beforeReport,
-		}, nil
-	}
-
-	// Step 4: Write repaired EPUB
-	// The writer handles OCF-002 (mimetype first), OCF-004 (no extra field),
-	// and OCF-005 (stored not compressed) by construction.
-	if err := writeEPUB(outputPath, files, ep.ZipFile); err != nil {
-		ep.Close()
-		return nil, fmt.Errorf("writing repaired epub: %w", err)
-	}
+	// Content-level: declare the language on the html element
+	allFixes = append(allFixes, fixMissingHTMLLang(files, ep)...)
 
-	ep.Close()
+	// --- Opt-in fixes ---
 
-	// Step 5: Re-validate to confirm
-	afterReport, err := validate.Validate(outputPath)
-	if err != nil {
-		return nil, fmt.Errorf("validating repaired epub: %w", err)
-	}
+	// Content-level: normalize quotes and whitespace in text
+	allFixes = append(allFixes, fixTypography(files, ep, opts)...)
 
-	return &Result{
-		Fixes:        allFixes,
-		BeforeReport: beforeReport,
-		AfterReport:  afterReport,
-	}, nil
+	return files, allFixes, nil
 }
 
 // Note on OCF-002/004/005:
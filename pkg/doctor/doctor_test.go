@@ -3,10 +3,14 @@ package doctor
 import (
 	"archive/zip"
 	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/adammathes/epubverify/pkg/epub"
 	"github.com/adammathes/epubverify/pkg/validate"
@@ -29,6 +33,7 @@ type epubOpts struct {
 	badDate          string // bad dc:date value (non-W3CDTF)
 	extraFile        bool   // add a file not in manifest
 	obsoleteElements bool   // add obsolete HTML elements (center, big, etc.)
+	noHTMLLang       bool   // omit lang and xml:lang from the html elements
 }
 
 func defaultOpts() epubOpts {
@@ -54,6 +59,11 @@ func createTestEPUB(t *testing.T, opts epubOpts) string {
 
 	w := zip.NewWriter(f)
 
+	htmlLang := ` lang="en" xml:lang="en"`
+	if opts.noHTMLLang {
+		htmlLang = ""
+	}
+
 	mimetypeContent := opts.mimetypeContent
 	if mimetypeContent == "" {
 		mimetypeContent = "application/epub+zip"
@@ -128,7 +138,7 @@ func createTestEPUB(t *testing.T, opts epubOpts) string {
 		cw, _ := w.Create("OEBPS/nav.xhtml")
 		cw.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE html>
-<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"` + htmlLang + `>
 <head><title>Navigation</title></head>
 <body>
 <nav epub:type="toc"><ol><li><a href="chapter1.xhtml">Chapter 1</a></li></ol></nav>
@@ -164,7 +174,7 @@ func createTestEPUB(t *testing.T, opts epubOpts) string {
 		cw, _ := w.Create("OEBPS/chapter1.xhtml")
 		cw.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
 ` + doctype + `
-<html xmlns="http://www.w3.org/1999/xhtml">
+<html xmlns="http://www.w3.org/1999/xhtml"` + htmlLang + `>
 <head><title>Chapter 1</title></head>
 <body>
 ` + scriptTag + emptyHrefTag + obsoleteTags + `<p>Hello world</p>
@@ -348,6 +358,26 @@ func TestDoctorFixesMissingScriptedProperty(t *testing.T) {
 	}
 }
 
+func TestDetectContentFeaturesInlineScript(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`<p>Static</p>`, false},
+		{`<p onclick="go()">Tap</p>`, true},
+		{`<a href="javascript:go()">Go</a>`, true},
+		{`<details open="open"><summary>S</summary></details>`, false},
+		{`<script>go()</script>`, true},
+		{`<script type="application/ld+json">{"@type": "Book"}</script>`, false},
+	}
+	for _, tt := range tests {
+		xhtml := `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>T</title></head><body>` + tt.body + `</body></html>`
+		if got, _, _ := detectContentFeatures([]byte(xhtml)); got != tt.want {
+			t.Errorf("%s: hasScript = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
 func TestDoctorFixesMediaTypeMismatch(t *testing.T) {
 	opts := defaultOpts()
 	opts.wrongMediaType = "image/png" // file is actually JPEG
@@ -630,6 +660,84 @@ func TestDoctorFixesFileNotInManifest(t *testing.T) {
 	}
 }
 
+func TestDoctorRemovesExtraneousFiles(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Ch</title></head><body><p>Hi</p></body></html>`
+	input := createCustomEPUB(t, opf, chapter, map[string][]byte{
+		"OEBPS/.DS_Store":                 []byte("junk"),
+		"__MACOSX/OEBPS/._chapter1.xhtml": []byte("junk"),
+		"OEBPS/notes.txt":                 []byte("draft notes"),
+	})
+
+	zipNames := func(path string) map[string]bool {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		names := make(map[string]bool)
+		for _, f := range zr.File {
+			names[f.Name] = true
+		}
+		return names
+	}
+
+	// By default only OS junk is removed; notes.txt is added to the manifest.
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	removed := 0
+	for _, fix := range result.Fixes {
+		if strings.HasPrefix(fix.Description, "Removed") {
+			removed++
+		}
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 removal fixes, got %d: %v", removed, result.Fixes)
+	}
+	names := zipNames(output)
+	if names["OEBPS/.DS_Store"] || names["__MACOSX/OEBPS/._chapter1.xhtml"] {
+		t.Errorf("OS junk files still present: %v", names)
+	}
+	if !names["OEBPS/notes.txt"] || !names["OEBPS/chapter1.xhtml"] {
+		t.Errorf("expected notes.txt and chapter1.xhtml to be kept: %v", names)
+	}
+
+	// RemoveUnlisted drops notes.txt as well.
+	output = filepath.Join(t.TempDir(), "fixed.epub")
+	result, err = RepairWithOptions(input, output, RepairOptions{RemoveUnlisted: true})
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	names = zipNames(output)
+	if names["OEBPS/notes.txt"] {
+		t.Error("notes.txt should be removed with RemoveUnlisted")
+	}
+	if !names["mimetype"] || !names["META-INF/container.xml"] || !names["OEBPS/content.opf"] {
+		t.Errorf("required OCF files missing: %v", names)
+	}
+	if !result.AfterReport.IsValid() {
+		t.Errorf("repaired EPUB not valid: %v", result.AfterReport.Messages)
+	}
+}
+
 func TestDoctorFixesObsoleteElements(t *testing.T) {
 	opts := defaultOpts()
 	opts.obsoleteElements = true
@@ -1614,3 +1722,1099 @@ func TestDoctorFixesMissingTitle(t *testing.T) {
 		t.Error("Output should contain a <title> element")
 	}
 }
+
+func TestDoctorFixesHTMLLangInDefaultRun(t *testing.T) {
+	// The standard fixture as it was before ACC-003 was fixable: any
+	// repair now also declares the language on both documents.
+	opts := defaultOpts()
+	opts.includeDCModified = false
+	opts.noHTMLLang = true
+	input := createTestEPUB(t, opts)
+
+	result, err := Repair(input, filepath.Join(t.TempDir(), "fixed.epub"))
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	fixed := make(map[string]bool)
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "ACC-003" {
+			fixed[fix.File] = true
+		}
+	}
+	if !fixed["OEBPS/nav.xhtml"] || !fixed["OEBPS/chapter1.xhtml"] {
+		t.Errorf("Expected ACC-003 fixes for nav and chapter, got %v", result.Fixes)
+	}
+}
+
+func TestDoctorFixesMissingHTMLLang(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	// The missing title gives doctor a reason to run.
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="fr">
+<head></head>
+<body><p>Bonjour</p></body></html>`
+
+	input := createCustomEPUB(t, opf, chapter, nil)
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	fixed := make(map[string]bool)
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "ACC-003" {
+			fixed[fix.File] = true
+		}
+	}
+	if !fixed["OEBPS/nav.xhtml"] || !fixed["OEBPS/chapter1.xhtml"] {
+		t.Errorf("Expected ACC-003 fixes for nav and chapter, got %v", result.Fixes)
+	}
+
+	ep, err := epub.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Close()
+	data, _ := ep.ReadFile("OEBPS/nav.xhtml")
+	if !strings.Contains(string(data), `lang="en" xml:lang="en">`) {
+		t.Errorf("Expected package language on nav html element:\n%s", data)
+	}
+	data, _ = ep.ReadFile("OEBPS/chapter1.xhtml")
+	if !strings.Contains(string(data), `lang="fr" xml:lang="fr">`) {
+		t.Errorf("Expected existing lang kept and copied to xml:lang:\n%s", data)
+	}
+	if result.AfterReport.ErrorCount() != 0 {
+		t.Errorf("Repaired EPUB has errors: %v", result.AfterReport.Messages)
+	}
+
+	// A prefixed root element gets the attributes on the html tag itself.
+	files := map[string][]byte{
+		"OEBPS/nav.xhtml":      []byte(`<h:html xmlns:h="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en"/>`),
+		"OEBPS/chapter1.xhtml": []byte(`<h:html xmlns:h="http://www.w3.org/1999/xhtml"><h:head/></h:html>`),
+	}
+	ep.ParseContainer()
+	ep.ParseOPF()
+	fixes := fixMissingHTMLLang(files, ep)
+	if len(fixes) != 1 {
+		t.Fatalf("Expected 1 fix for the prefixed root, got %v", fixes)
+	}
+	want := `<h:html xmlns:h="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en"><h:head/></h:html>`
+	if string(files["OEBPS/chapter1.xhtml"]) != want {
+		t.Errorf("got %s, want %s", files["OEBPS/chapter1.xhtml"], want)
+	}
+}
+
+func TestDoctorFixesMediaTypeTypo(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="logo" href="logo.svg" media-type="image/svg"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Ch</title></head><body><p>Hi <img src="logo.svg" alt="Logo"/></p></body></html>`
+
+	input := createCustomEPUB(t, opf, chapter, map[string][]byte{
+		"OEBPS/logo.svg": []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"/>`),
+	})
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	foundFix := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "OPF-074" {
+			foundFix = true
+			break
+		}
+	}
+	if !foundFix {
+		t.Error("Expected OPF-074 fix for misspelled media-type")
+	}
+
+	for _, msg := range result.AfterReport.Messages {
+		if msg.CheckID == "OPF-074" {
+			t.Errorf("OPF-074 still present after fix: %s", msg.Message)
+		}
+	}
+}
+
+func TestDoctorFixesLanguageTag(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>EN_us</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Ch</title></head><body><p>Hi</p></body></html>`
+
+	input := createCustomEPUB(t, opf, chapter, nil)
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	foundFix := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "OPF-020" && strings.Contains(fix.Description, "'en-US'") {
+			foundFix = true
+			break
+		}
+	}
+	if !foundFix {
+		t.Errorf("Expected OPF-020 fix normalizing to en-US, got %v", result.Fixes)
+	}
+
+	for _, msg := range result.AfterReport.Messages {
+		if msg.CheckID == "OPF-020" || msg.CheckID == "OPF-076" {
+			t.Errorf("%s still present after fix: %s", msg.CheckID, msg.Message)
+		}
+	}
+}
+
+func TestDoctorLeavesExtensionLanguageTags(t *testing.T) {
+	for _, lang := range []string{"en-x-ab", "de-DE-u-co-phonebk", "en-a-bc-de"} {
+		t.Run(lang, func(t *testing.T) {
+			opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>` + lang + `</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+			chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Ch</title></head><body><p>Hi</p></body></html>`
+
+			input := createCustomEPUB(t, opf, chapter, nil)
+			output := filepath.Join(t.TempDir(), "fixed.epub")
+			result, err := Repair(input, output)
+			if err != nil {
+				t.Fatalf("Repair failed: %v", err)
+			}
+			for _, fix := range result.Fixes {
+				if fix.CheckID == "OPF-020" || fix.CheckID == "OPF-076" {
+					t.Errorf("canonical tag %q should not be rewritten: %s", lang, fix.Description)
+				}
+			}
+		})
+	}
+}
+
+func TestDoctorFixesMissingRequiredMetadata(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata>
+  </metadata>
+  <manifest>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+  </manifest>
+  <spine toc="ncx"><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.1//EN" "http://www.w3.org/TR/xhtml11/DTD/xhtml11.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Ch</title></head><body><p>Hi</p></body></html>`
+	ncx := `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head><meta name="dtb:uid" content="x"/></head>
+  <docTitle><text>Test</text></docTitle>
+  <navMap><navPoint id="n1" playOrder="1"><navLabel><text>Ch</text></navLabel><content src="chapter1.xhtml"/></navPoint></navMap>
+</ncx>`
+
+	input := createCustomEPUB(t, opf, chapter, map[string][]byte{
+		"OEBPS/toc.ncx": []byte(ncx),
+	})
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	fixed := make(map[string]bool)
+	for _, fix := range result.Fixes {
+		fixed[fix.CheckID] = true
+	}
+	for _, id := range []string{"OPF-001", "OPF-002", "OPF-003"} {
+		if !fixed[id] {
+			t.Errorf("Expected %s fix, got %v", id, result.Fixes)
+		}
+	}
+
+	for _, msg := range result.AfterReport.Messages {
+		switch msg.CheckID {
+		case "OPF-001", "OPF-002", "OPF-003", "OPF-027", "OPF-030":
+			t.Errorf("%s still present after fix: %s", msg.CheckID, msg.Message)
+		}
+	}
+}
+
+func TestDoctorFixesLeadingXMLDeclaration(t *testing.T) {
+	opf := "\n  " + `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := "\n" + `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter</title></head>
+<body><p>Hi</p></body></html>`
+
+	input := createCustomEPUB(t, opf, chapter, nil)
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	fixed := make(map[string]bool)
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "XML-002" {
+			fixed[fix.File] = true
+		}
+	}
+	if !fixed["OEBPS/content.opf"] || !fixed["OEBPS/chapter1.xhtml"] {
+		t.Errorf("Expected XML-002 fixes for the OPF and chapter, got %v", result.Fixes)
+	}
+	for _, m := range result.AfterReport.Messages {
+		if m.CheckID == "XML-002" {
+			t.Errorf("XML-002 still reported after repair: %s", m.Message)
+		}
+	}
+}
+
+func TestDoctorStripsUTF8BOM(t *testing.T) {
+	bom := "\xef\xbb\xbf"
+	opf := bom + `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="css" href="style.css" media-type="text/css"/>
+    <item id="font" href="font.bin" media-type="application/octet-stream"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := bom + `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Chapter</title><link rel="stylesheet" href="style.css"/></head>
+<body><p>Hi</p></body></html>`
+	font := []byte(bom + "\x00\x01binary")
+
+	input := createCustomEPUB(t, opf, chapter, map[string][]byte{
+		"OEBPS/style.css": []byte(bom + "p { margin: 0; }"),
+		"OEBPS/font.bin":  font,
+	})
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	fixed := make(map[string]bool)
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "ENC-011" {
+			fixed[fix.File] = true
+		}
+	}
+	for _, f := range []string{"OEBPS/content.opf", "OEBPS/chapter1.xhtml", "OEBPS/style.css"} {
+		if !fixed[f] {
+			t.Errorf("Expected ENC-011 fix for %s, got %v", f, result.Fixes)
+		}
+	}
+	if fixed["OEBPS/font.bin"] {
+		t.Error("BOM should not be stripped from a binary resource")
+	}
+	for _, m := range result.AfterReport.Messages {
+		if m.CheckID == "ENC-011" {
+			t.Errorf("ENC-011 still reported after repair: %s", m.Message)
+		}
+	}
+
+	ep, err := epub.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Close()
+	data, err := ep.ReadFile("OEBPS/font.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, font) {
+		t.Errorf("font.bin changed: %q", data)
+	}
+}
+
+func TestDoctorFixesPropertiesTokens(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav  nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter</title></head>
+<body><p>Hi</p></body></html>`
+
+	input := createCustomEPUB(t, opf, chapter, nil)
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	foundFix := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "OPF-081" {
+			foundFix = true
+			break
+		}
+	}
+	if !foundFix {
+		t.Error("Expected OPF-081 fix for duplicate properties")
+	}
+
+	ep, err := epub.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Close()
+	data, _ := ep.ReadFile("OEBPS/content.opf")
+	if !strings.Contains(string(data), `properties="nav"`) {
+		t.Errorf("Output OPF should have properties=\"nav\", got:\n%s", data)
+	}
+}
+
+func TestDoctorFixesLegacyFontMediaType(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="font" href="font.otf" media-type="application/vnd.ms-opentype"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter</title><style>@font-face { font-family: A; src: url(font.otf); }</style></head>
+<body><p>Hi</p></body></html>`
+
+	input := createCustomEPUB(t, opf, chapter, map[string][]byte{"OEBPS/font.otf": []byte("OTTO")})
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	foundFix := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "FONT-004" {
+			foundFix = true
+			break
+		}
+	}
+	if !foundFix {
+		t.Errorf("Expected FONT-004 fix for legacy font media-type, got %v", result.Fixes)
+	}
+	for _, m := range result.AfterReport.Messages {
+		if m.CheckID == "FONT-004" {
+			t.Errorf("FONT-004 still reported after repair: %s", m.Message)
+		}
+	}
+}
+
+func TestDoctorFixesMissingRenditionLayout(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Page</title><meta name="viewport" content="width=600, height=800"/></head>
+<body style="width: 600px; height: 800px"><div>Hi</div></body></html>`
+
+	input := createCustomEPUB(t, opf, chapter, nil)
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	foundFix := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "FXL-009" {
+			foundFix = true
+			break
+		}
+	}
+	if !foundFix {
+		t.Errorf("Expected FXL-009 fix for missing rendition:layout, got %v", result.Fixes)
+	}
+	for _, m := range result.AfterReport.Messages {
+		if m.CheckID == "FXL-009" {
+			t.Errorf("FXL-009 still reported after repair: %s", m.Message)
+		}
+	}
+}
+
+// createEPUBFromFiles writes an EPUB with a correct mimetype entry, the
+// standard container.xml, and exactly the given files, so tests can build
+// books without the nav document createCustomEPUB always adds.
+func createEPUBFromFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	epubPath := filepath.Join(t.TempDir(), "test.epub")
+	f, err := os.Create(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	mw, _ := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	mw.Write([]byte("application/epub+zip"))
+	files["META-INF/container.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+	for name, data := range files {
+		fw, _ := w.Create(name)
+		fw.Write([]byte(data))
+	}
+	w.Close()
+	f.Close()
+	return epubPath
+}
+
+func TestDoctorGeneratesMissingNav(t *testing.T) {
+	input := createEPUBFromFiles(t, map[string]string{
+		"OEBPS/content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="ch1" href="text/chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="text/chapter2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/><itemref idref="ch2"/></spine>
+</package>`,
+		"OEBPS/text/chapter1.xhtml": `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Opening &amp; Intro</title></head><body><p>One</p></body></html>`,
+		"OEBPS/text/chapter2.xhtml": `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Two</title></head><body><p>Two</p></body></html>`,
+	})
+
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	foundFix := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "NAV-001" {
+			foundFix = true
+			break
+		}
+	}
+	if !foundFix {
+		t.Errorf("Expected NAV-001 fix for missing nav document, got %v", result.Fixes)
+	}
+	for _, m := range result.AfterReport.Messages {
+		if strings.HasPrefix(m.CheckID, "NAV-") || m.CheckID == "RSC-002" {
+			t.Errorf("%s still reported after repair: %s", m.CheckID, m.Message)
+		}
+	}
+	if !result.AfterReport.IsValid() {
+		t.Errorf("Expected valid output, got %v", result.AfterReport.Messages)
+	}
+
+	ep, err := epub.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Close()
+	nav, err := ep.ReadFile("OEBPS/nav.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(nav), `<a href="text/chapter1.xhtml">Opening &amp; Intro</a>`) {
+		t.Errorf("nav document missing labelled chapter link:\n%s", nav)
+	}
+}
+
+func TestDoctorConvertsNCXToNav(t *testing.T) {
+	chapter := func(title string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>` + title + `</title></head><body><h1 id="s1">` + title + `</h1></body></html>`
+	}
+	input := createEPUBFromFiles(t, map[string]string{
+		"OEBPS/content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="ch1" href="text/ch1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="text/ch2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx"><itemref idref="ch1"/><itemref idref="ch2"/></spine>
+</package>`,
+		"OEBPS/toc.ncx": `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head><meta name="dtb:uid" content="urn:uuid:12345678-1234-1234-1234-123456789012"/></head>
+  <docTitle><text>Test</text></docTitle>
+  <navMap>
+    <navPoint id="p2" playOrder="3"><navLabel><text>Part Two</text></navLabel><content src="text/ch2.xhtml"/></navPoint>
+    <navPoint id="p1" playOrder="1"><navLabel><text>Part One</text></navLabel><content src="text/ch1.xhtml"/>
+      <navPoint id="p1s1" playOrder="2"><navLabel><text>Section 1.1</text></navLabel><content src="text/ch1.xhtml#s1"/></navPoint>
+    </navPoint>
+  </navMap>
+</ncx>`,
+		"OEBPS/text/ch1.xhtml": chapter("One"),
+		"OEBPS/text/ch2.xhtml": chapter("Two"),
+	})
+
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	foundFix := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "NAV-001" && strings.Contains(fix.Description, "Converted NCX") {
+			foundFix = true
+		}
+	}
+	if !foundFix {
+		t.Errorf("Expected NAV-001 NCX conversion fix, got %v", result.Fixes)
+	}
+	if !result.AfterReport.IsValid() {
+		t.Errorf("Expected valid output, got %v", result.AfterReport.Messages)
+	}
+
+	ep, err := epub.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Close()
+	if _, ok := ep.Files["OEBPS/toc.ncx"]; !ok {
+		t.Error("NCX should be kept for EPUB 2 reading systems")
+	}
+	nav, err := ep.ReadFile("OEBPS/nav.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	one := strings.Index(string(nav), `<a href="text/ch1.xhtml">Part One</a>`)
+	sub := strings.Index(string(nav), `<a href="text/ch1.xhtml#s1">Section 1.1</a>`)
+	two := strings.Index(string(nav), `<a href="text/ch2.xhtml">Part Two</a>`)
+	if one < 0 || sub < one || two < sub {
+		t.Errorf("nav entries missing or out of playOrder:\n%s", nav)
+	}
+	if !strings.Contains(string(nav), "<li><a href=\"text/ch1.xhtml\">Part One</a>\n        <ol>") {
+		t.Errorf("Section 1.1 should be nested under Part One:\n%s", nav)
+	}
+}
+
+func TestRepairBytes(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter</title></head><body><p>Hi</p></body></html>`
+	data, err := os.ReadFile(createCustomEPUB(t, opf, chapter, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, repaired, err := RepairBytes(data)
+	if err != nil {
+		t.Fatalf("RepairBytes failed: %v", err)
+	}
+	if len(result.Fixes) == 0 || !result.AfterReport.IsValid() {
+		t.Fatalf("expected fixes and a valid result, got fixes %v and %v", result.Fixes, result.AfterReport.Messages)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(repaired), int64(len(repaired)))
+	if err != nil {
+		t.Fatalf("repaired bytes are not a ZIP: %v", err)
+	}
+	if first := zr.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("first entry = %s (method %d), want stored mimetype", first.Name, first.Method)
+	}
+
+	result, same, err := RepairBytes(repaired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Fixes) != 0 || !bytes.Equal(same, repaired) {
+		t.Errorf("repairing a valid EPUB should return it unchanged, got fixes %v", result.Fixes)
+	}
+}
+
+func TestDoctorFixesUTF16DeclarationOnUTF8(t *testing.T) {
+	xhtml := `<?xml version="1.0" encoding="UTF-16"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Chapter 1</title></head>
+<body><p>Hello</p></body>
+</html>`
+	input := createEPUBWithBadEncoding(t, "UTF-16", []byte(xhtml))
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	foundFix := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "ENC-010" {
+			foundFix = true
+			break
+		}
+	}
+	if !foundFix {
+		t.Error("Expected ENC-010 fix for a UTF-16 declaration on UTF-8 content")
+	}
+	for _, m := range result.AfterReport.Messages {
+		if strings.HasPrefix(m.CheckID, "ENC-") {
+			t.Errorf("unexpected %s after repair: %s", m.CheckID, m.Message)
+		}
+	}
+}
+
+func TestDoctorReportsSkippedChecks(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="img" href="missing.png" media-type="image/png"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Chapter</title></head>
+<body><p>Hi</p></body></html>`
+
+	input := createCustomEPUB(t, opf, chapter, nil)
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := Repair(input, output)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	skipped := make(map[string]string)
+	for _, s := range result.Skipped {
+		skipped[s.CheckID] = s.Reason
+	}
+	if reason, ok := skipped["RSC-001"]; !ok || !strings.HasPrefix(reason, "requires manual editing") {
+		t.Errorf("Expected RSC-001 to be skipped for manual editing, got %v", result.Skipped)
+	}
+	if _, ok := skipped["OPF-004"]; ok {
+		t.Error("OPF-004 is fixed by doctor and should not be skipped")
+	}
+}
+
+func TestDoctorPlanDoesNotWrite(t *testing.T) {
+	input := createTestEPUB(t, epubOpts{
+		mimetypeContent: "application/epub+zip",
+		mimetypeFirst:   true,
+		version:         "3.0",
+	})
+
+	result, err := Plan(input)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	found := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "OPF-004" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected OPF-004 in the plan, got %v", result.Fixes)
+	}
+	if result.AfterReport == nil {
+		t.Fatal("Plan should project the repaired book, but AfterReport is nil")
+	}
+	for _, m := range result.AfterReport.Messages {
+		if m.CheckID == "OPF-004" {
+			t.Errorf("projected AfterReport still reports OPF-004: %s", m.Message)
+		}
+	}
+	if _, err := os.Stat(input + ".fixed.epub"); !os.IsNotExist(err) {
+		t.Errorf("Plan wrote an output file (stat err: %v)", err)
+	}
+}
+
+func TestDoctorCopiesUntouchedEntries(t *testing.T) {
+	plain := createTestEPUB(t, epubOpts{
+		mimetypeContent: "application/epub+zip",
+		mimetypeFirst:   true,
+		version:         "3.0",
+	})
+
+	// Recompress the book at the fastest level with an old timestamp, so
+	// a rewrite at the default level would change the stored bytes.
+	zr, err := zip.OpenReader(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	input := filepath.Join(t.TempDir(), "fast.epub")
+	f, err := os.Create(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	w.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, flate.BestSpeed)
+	})
+	mtime := time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)
+	var css strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&css, ".c%d { margin: %dpx %dem; color: #%06x; }\n", i, i%17, i%5, i*7919)
+	}
+	add := func(name string, method uint16, data []byte) {
+		ew, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: mtime})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ew.Write(data)
+	}
+	for _, zf := range zr.File {
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		add(zf.Name, zf.Method, data)
+	}
+	add("OEBPS/style.css", zip.Deflate, []byte(css.String()))
+	w.Close()
+	f.Close()
+
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+	if _, err := Repair(input, output); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	in, err := zip.OpenReader(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := zip.OpenReader(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	before := make(map[string]*zip.File)
+	for _, zf := range in.File {
+		before[zf.Name] = zf
+	}
+	for _, zf := range out.File {
+		orig, ok := before[zf.Name]
+		if !ok || zf.Name == "mimetype" {
+			continue
+		}
+		if zf.CRC32 != orig.CRC32 {
+			if zf.Name != "OEBPS/content.opf" {
+				t.Errorf("%s changed, but only the package document was fixed", zf.Name)
+			}
+			continue
+		}
+		if zf.CompressedSize64 != orig.CompressedSize64 || !zf.Modified.Equal(orig.Modified) {
+			t.Errorf("%s was rewritten: compressed %d -> %d bytes, modified %v -> %v",
+				zf.Name, orig.CompressedSize64, zf.CompressedSize64, orig.Modified, zf.Modified)
+		}
+	}
+}
+
+func TestDoctorLeavesEntriesOverDecompressionCap(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="vid" href="video.mp4" media-type="video/mp4"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Ch</title></head><body><p>Hi</p></body></html>`
+	video := make([]byte, 64<<10)
+
+	input := createCustomEPUB(t, opf, chapter, map[string][]byte{"OEBPS/video.mp4": video})
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	result, err := RepairWithOptions(input, output, RepairOptions{MaxDecompressedBytes: 8 << 10})
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	foundFix := false
+	for _, fix := range result.Fixes {
+		if fix.CheckID == "OPF-004" {
+			foundFix = true
+		}
+	}
+	if !foundFix {
+		t.Error("Expected OPF-004 fix alongside the oversized entry")
+	}
+
+	zr, err := zip.OpenReader(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name == "OEBPS/video.mp4" && f.UncompressedSize64 != uint64(len(video)) {
+			t.Errorf("video.mp4 is %d bytes after repair, want %d", f.UncompressedSize64, len(video))
+		}
+	}
+}
+
+func TestDoctorFixesCoverImageProperty(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00")
+	tests := []struct {
+		name     string
+		meta     string
+		items    string
+		wantItem string // item expected to gain cover-image, or "" for no fix
+	}{
+		{
+			name:     "meta name cover",
+			meta:     `<meta name="cover" content="front"/>`,
+			items:    `<item id="front" href="images/a.png" media-type="image/png"/><item id="cover-back" href="images/b.png" media-type="image/png"/>`,
+			wantItem: "front",
+		},
+		{
+			name:     "single image called cover",
+			items:    `<item id="img1" href="images/Cover.png" media-type="image/png"/><item id="img2" href="images/b.png" media-type="image/png"/>`,
+			wantItem: "img1",
+		},
+		{
+			name:  "ambiguous",
+			items: `<item id="cover-front" href="images/a.png" media-type="image/png"/><item id="cover-back" href="images/b.png" media-type="image/png"/>`,
+		},
+		{
+			name:  "already declared",
+			items: `<item id="cover" href="images/a.png" media-type="image/png"/><item id="img2" href="images/b.png" media-type="image/png" properties="cover-image"/>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+    ` + tt.meta + `
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    ` + tt.items + `
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+			chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter</title></head>
+<body><p>Hi</p></body></html>`
+			extra := map[string][]byte{"OEBPS/images/a.png": png, "OEBPS/images/b.png": png, "OEBPS/images/Cover.png": png}
+
+			input := createCustomEPUB(t, opf, chapter, extra)
+			output := filepath.Join(t.TempDir(), "fixed.epub")
+			result, err := Repair(input, output)
+			if err != nil {
+				t.Fatalf("Repair failed: %v", err)
+			}
+
+			var fixes []Fix
+			for _, fix := range result.Fixes {
+				if fix.CheckID == "OPF-060" {
+					fixes = append(fixes, fix)
+				}
+			}
+			if tt.wantItem == "" {
+				if len(fixes) != 0 {
+					t.Errorf("expected no OPF-060 fix, got %v", fixes)
+				}
+				return
+			}
+			if len(fixes) != 1 || !strings.Contains(fixes[0].Description, "'"+tt.wantItem+"'") {
+				t.Fatalf("expected one OPF-060 fix for %s, got %v", tt.wantItem, fixes)
+			}
+
+			ep, err := epub.Open(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ep.Close()
+			if err := ep.ParseContainer(); err != nil {
+				t.Fatal(err)
+			}
+			if err := ep.ParseOPF(); err != nil {
+				t.Fatal(err)
+			}
+			var covers []string
+			for _, item := range ep.Package.Manifest {
+				if hasProperty(item.Properties, "cover-image") {
+					covers = append(covers, item.ID)
+				}
+			}
+			if len(covers) != 1 || covers[0] != tt.wantItem {
+				t.Errorf("cover-image items = %v, want [%s]", covers, tt.wantItem)
+			}
+			for _, m := range result.AfterReport.Messages {
+				if m.CheckID == "OPF-060" {
+					t.Errorf("OPF-060 still reported after repair: %s", m)
+				}
+			}
+		})
+	}
+}
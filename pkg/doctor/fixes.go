@@ -2,6 +2,7 @@ package doctor
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/adammathes/epubverify/pkg/epub"
 	"github.com/adammathes/epubverify/pkg/report"
+	"github.com/adammathes/epubverify/pkg/validate"
 )
 
 // Fix represents a single applied fix.
@@ -87,8 +89,16 @@ func fixDCTermsModified(files map[string][]byte, ep *epub.EPUB) []Fix {
 	}}
 }
 
+// legacyFontMediaTypes are the pre-font/* font media types that
+// fixMediaTypes upgrades by file extension (FONT-004).
+var legacyFontMediaTypes = map[string]bool{
+	"application/vnd.ms-opentype": true,
+	"application/font-woff":       true,
+	"application/font-sfnt":       true,
+}
+
 // fixMediaTypes corrects manifest media-type attributes that don't match actual content.
-// Fixes OPF-024 and MED-001.
+// Fixes OPF-024, MED-001, and FONT-004.
 func fixMediaTypes(files map[string][]byte, ep *epub.EPUB) []Fix {
 	if ep.Package == nil {
 		return nil
@@ -138,8 +148,12 @@ func fixMediaTypes(files map[string][]byte, ep *epub.EPUB) []Fix {
 		}
 
 		if correctType != "" {
+			checkID := "OPF-024"
+			if legacyFontMediaTypes[item.MediaType] && strings.HasPrefix(correctType, "font/") {
+				checkID = "FONT-004"
+			}
 			fixes = append(fixes, Fix{
-				CheckID:     "OPF-024",
+				CheckID:     checkID,
 				Description: fmt.Sprintf("Fixed media-type for '%s' from '%s' to '%s'", item.Href, item.MediaType, correctType),
 				File:        ep.RootfilePath,
 			})
@@ -156,6 +170,42 @@ func fixMediaTypes(files map[string][]byte, ep *epub.EPUB) []Fix {
 	return fixes
 }
 
+// fixMediaTypeTypos corrects misspelled manifest media-type values such as
+// "image/jpg" or "application/xhtml", using the suggestion OPF-074 reports.
+// Fixes OPF-074.
+func fixMediaTypeTypos(files map[string][]byte, ep *epub.EPUB) []Fix {
+	if ep.Package == nil {
+		return nil
+	}
+
+	var fixes []Fix
+	for _, item := range ep.Package.Manifest {
+		if item.Href == "\x00MISSING" || item.MediaType == "\x00MISSING" {
+			continue
+		}
+
+		correctType := validate.SuggestMediaType(item.MediaType, item.Href)
+		if correctType == "" {
+			continue
+		}
+
+		// fixMediaTypes may already have rewritten this item
+		opfStr := string(files[ep.RootfilePath])
+		fixed := fixManifestItemMediaType(opfStr, item.Href, item.MediaType, correctType)
+		if fixed == opfStr {
+			continue
+		}
+		files[ep.RootfilePath] = []byte(fixed)
+		fixes = append(fixes, Fix{
+			CheckID:     "OPF-074",
+			Description: fmt.Sprintf("Fixed media-type for '%s' from '%s' to '%s'", item.Href, item.MediaType, correctType),
+			File:        ep.RootfilePath,
+		})
+	}
+
+	return fixes
+}
+
 // fixManifestProperties adds missing scripted/svg/mathml properties to manifest items.
 // Fixes HTM-005, HTM-006, HTM-007.
 func fixManifestProperties(files map[string][]byte, ep *epub.EPUB) []Fix {
@@ -230,6 +280,70 @@ func fixManifestProperties(files map[string][]byte, ep *epub.EPUB) []Fix {
 	return fixes
 }
 
+// fixMissingRenditionLayout declares pre-paginated layout when every
+// spine content document sets a pixel viewport but the package has no
+// rendition:layout at all. An explicit "reflowable" is left alone.
+// Fixes FXL-009.
+func fixMissingRenditionLayout(files map[string][]byte, ep *epub.EPUB) []Fix {
+	if ep.Package == nil || ep.Package.Version < "3.0" || ep.Package.RenditionLayout != "" {
+		return nil
+	}
+
+	viewportRe := regexp.MustCompile(`(?is)<meta\s[^>]*name=["']viewport["'][^>]*>`)
+	widthRe := regexp.MustCompile(`(?i)width\s*=\s*\d+`)
+	heightRe := regexp.MustCompile(`(?i)height\s*=\s*\d+`)
+
+	manifestByID := make(map[string]epub.ManifestItem)
+	for _, item := range ep.Package.Manifest {
+		manifestByID[item.ID] = item
+	}
+	total := 0
+	for _, ref := range ep.Package.Spine {
+		if strings.Contains(ref.Properties, "rendition:layout-") {
+			return nil
+		}
+		item, ok := manifestByID[ref.IDRef]
+		if !ok || item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" ||
+			strings.Contains(" "+item.Properties+" ", " nav ") {
+			continue
+		}
+		data, ok := files[ep.ResolveHref(item.Href)]
+		if !ok {
+			continue
+		}
+		meta := viewportRe.FindString(string(data))
+		if !widthRe.MatchString(meta) || !heightRe.MatchString(meta) {
+			return nil
+		}
+		total++
+	}
+	if total == 0 {
+		return nil
+	}
+
+	opfData, ok := files[ep.RootfilePath]
+	if !ok {
+		return nil
+	}
+	content := string(opfData)
+	metaClose := strings.Index(content, "</metadata>")
+	if metaClose == -1 {
+		metaClose = findClosingTag(content, "metadata")
+	}
+	if metaClose == -1 {
+		return nil
+	}
+
+	insertion := "    <meta property=\"rendition:layout\">pre-paginated</meta>\n  "
+	files[ep.RootfilePath] = []byte(content[:metaClose] + insertion + content[metaClose:])
+
+	return []Fix{{
+		CheckID:     "FXL-009",
+		Description: fmt.Sprintf("Added rendition:layout pre-paginated (all %d spine documents set a fixed viewport)", total),
+		File:        ep.RootfilePath,
+	}}
+}
+
 // fixDoctype replaces XHTML/DTD doctypes with HTML5 DOCTYPE in EPUB 3 content docs.
 // Fixes HTM-010 and HTM-011.
 func fixDoctype(files map[string][]byte, ep *epub.EPUB) []Fix {
@@ -350,7 +464,12 @@ func hasProperty(properties, prop string) bool {
 	return false
 }
 
+// detectContentFeatures reports whether a content document has script,
+// inline SVG or MathML. Script is what the validator's HTM-005 counts:
+// executable <script> elements, inline event handlers such as onclick and
+// javascript: URLs, but not data blocks such as JSON-LD.
 func detectContentFeatures(data []byte) (hasScript, hasSVG, hasMathML bool) {
+	hasScript = len(validate.ScriptingKinds(data)) > 0
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
 	for {
 		tok, err := decoder.Token()
@@ -361,9 +480,6 @@ func detectContentFeatures(data []byte) (hasScript, hasSVG, hasMathML bool) {
 		if !ok {
 			continue
 		}
-		if se.Name.Local == "script" {
-			hasScript = true
-		}
 		if se.Name.Local == "svg" || se.Name.Space == "http://www.w3.org/2000/svg" {
 			hasSVG = true
 		}
@@ -453,6 +569,79 @@ func findClosingTag(content, tagName string) int {
 
 // --- Tier 2 fixes ---
 
+// fixLeadingXMLDeclaration removes whitespace before the XML declaration
+// in the container, package, and XML manifest items. Leading comments are
+// left alone since moving them may lose content the author wanted kept.
+// Fixes XML-002.
+func fixLeadingXMLDeclaration(files map[string][]byte, ep *epub.EPUB) []Fix {
+	leadingRe := regexp.MustCompile(`^(\x{FEFF})?[ \t\r\n]+(<\?xml\s)`)
+
+	paths := []string{"META-INF/container.xml"}
+	if ep.RootfilePath != "" {
+		paths = append(paths, ep.RootfilePath)
+	}
+	if ep.Package != nil {
+		for _, item := range ep.Package.Manifest {
+			if item.Href == "\x00MISSING" || !strings.HasSuffix(item.MediaType, "xml") {
+				continue
+			}
+			paths = append(paths, ep.ResolveHref(item.Href))
+		}
+	}
+
+	var fixes []Fix
+	for _, fullPath := range paths {
+		data, ok := files[fullPath]
+		if !ok || !leadingRe.Match(data) {
+			continue
+		}
+		files[fullPath] = leadingRe.ReplaceAll(data, []byte("${1}${2}"))
+		fixes = append(fixes, Fix{
+			CheckID:     "XML-002",
+			Description: "Removed whitespace before the XML declaration",
+			File:        fullPath,
+		})
+	}
+
+	return fixes
+}
+
+// fixUTF8BOM strips a leading UTF-8 byte order mark from the container,
+// package, and XML and CSS manifest items. Other resources are binary and
+// are never touched. Fixes ENC-011.
+func fixUTF8BOM(files map[string][]byte, ep *epub.EPUB) []Fix {
+	bom := []byte{0xef, 0xbb, 0xbf}
+
+	paths := []string{"META-INF/container.xml"}
+	if ep.RootfilePath != "" {
+		paths = append(paths, ep.RootfilePath)
+	}
+	if ep.Package != nil {
+		for _, item := range ep.Package.Manifest {
+			if item.Href == "\x00MISSING" || (!strings.HasSuffix(item.MediaType, "xml") && item.MediaType != "text/css") {
+				continue
+			}
+			paths = append(paths, ep.ResolveHref(item.Href))
+		}
+	}
+
+	var fixes []Fix
+	for _, fullPath := range paths {
+		data, ok := files[fullPath]
+		if !ok || !bytes.HasPrefix(data, bom) {
+			continue
+		}
+		files[fullPath] = data[len(bom):]
+		fixes = append(fixes, Fix{
+			CheckID:     "ENC-011",
+			Description: "Removed UTF-8 byte order mark",
+			File:        fullPath,
+		})
+	}
+
+	return fixes
+}
+
 // fixGuideElement removes the <guide> element from EPUB 3 OPF documents.
 // Fixes OPF-039.
 func fixGuideElement(files map[string][]byte, ep *epub.EPUB) []Fix {
@@ -574,6 +763,133 @@ func fixDCDateFormat(files map[string][]byte, ep *epub.EPUB) []Fix {
 	return fixes
 }
 
+// fixMissingRequiredMetadata adds placeholder dc:title, dc:identifier, and
+// dc:language elements when they are absent, so minimal packages are at least
+// structurally complete. The placeholders should be replaced with real values.
+// Fixes OPF-001, OPF-002, and OPF-003.
+func fixMissingRequiredMetadata(files map[string][]byte, ep *epub.EPUB) []Fix {
+	if ep.Package == nil || !ep.HasMetadata {
+		return nil
+	}
+	md := ep.Package.Metadata
+	if len(md.Titles) > 0 && len(md.Identifiers) > 0 && len(md.Languages) > 0 {
+		return nil
+	}
+
+	opfData, ok := files[ep.RootfilePath]
+	if !ok {
+		return nil
+	}
+	content := string(opfData)
+
+	var fixes []Fix
+	var insertion string
+
+	if len(md.Identifiers) == 0 {
+		uid := ep.Package.UniqueIdentifier
+		if uid == "" {
+			uid = "uid"
+			pkgRe := regexp.MustCompile(`<((?:\w+:)?package)\b`)
+			if loc := pkgRe.FindStringSubmatchIndex(content); loc != nil {
+				content = content[:loc[3]] + ` unique-identifier="` + uid + `"` + content[loc[3]:]
+			}
+		}
+		value := "urn:uuid:" + newUUID()
+		insertion += fmt.Sprintf("    <dc:identifier id=\"%s\">%s</dc:identifier>\n", uid, value)
+		fixes = append(fixes, Fix{
+			CheckID:     "OPF-002",
+			Description: fmt.Sprintf("Added generated dc:identifier '%s'; replace it with the book's real identifier", value),
+			File:        ep.RootfilePath,
+		})
+	}
+	if len(md.Titles) == 0 {
+		insertion += "    <dc:title>Untitled</dc:title>\n"
+		fixes = append(fixes, Fix{
+			CheckID:     "OPF-001",
+			Description: "Added placeholder dc:title 'Untitled'; replace it with the book's title",
+			File:        ep.RootfilePath,
+		})
+	}
+	if len(md.Languages) == 0 {
+		insertion += "    <dc:language>und</dc:language>\n"
+		fixes = append(fixes, Fix{
+			CheckID:     "OPF-003",
+			Description: "Added placeholder dc:language 'und' (undetermined); replace it with the book's language",
+			File:        ep.RootfilePath,
+		})
+	}
+
+	// Declare the dc prefix on <metadata> if the package doesn't already
+	if !strings.Contains(content, "xmlns:dc=") {
+		metaRe := regexp.MustCompile(`<((?:\w+:)?metadata)\b`)
+		if loc := metaRe.FindStringSubmatchIndex(content); loc != nil {
+			content = content[:loc[3]] + ` xmlns:dc="http://purl.org/dc/elements/1.1/"` + content[loc[3]:]
+		}
+	}
+
+	metaClose := findClosingTag(content, "metadata")
+	if metaClose == -1 {
+		return nil
+	}
+	content = strings.TrimRight(content[:metaClose], " \t") + insertion + "  " + content[metaClose:]
+	files[ep.RootfilePath] = []byte(content)
+
+	return fixes
+}
+
+// newUUID returns a random (version 4) UUID string.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// fixLanguageTags normalizes dc:language values to canonical BCP 47 form,
+// e.g. "english" -> "en", "EN_us" -> "en-US". Fixes OPF-020 and OPF-076.
+func fixLanguageTags(files map[string][]byte, ep *epub.EPUB) []Fix {
+	if ep.Package == nil || len(ep.Package.Metadata.Languages) == 0 {
+		return nil
+	}
+
+	opfData, ok := files[ep.RootfilePath]
+	if !ok {
+		return nil
+	}
+
+	var fixes []Fix
+	content := string(opfData)
+
+	for _, lang := range ep.Package.Metadata.Languages {
+		norm := validate.NormalizeLanguageTag(lang)
+		if norm == "" || norm == lang {
+			continue
+		}
+
+		checkID := "OPF-076"
+		if !validate.WellFormedLanguageTag(lang) {
+			checkID = "OPF-020"
+		}
+
+		langRe := regexp.MustCompile(`(<dc:language[^>]*>)\s*` + regexp.QuoteMeta(lang) + `\s*(</dc:language>)`)
+		if langRe.MatchString(content) {
+			content = langRe.ReplaceAllString(content, "${1}"+norm+"${2}")
+			fixes = append(fixes, Fix{
+				CheckID:     checkID,
+				Description: fmt.Sprintf("Normalized dc:language from '%s' to '%s'", lang, norm),
+				File:        ep.RootfilePath,
+			})
+		}
+	}
+
+	if len(fixes) > 0 {
+		files[ep.RootfilePath] = []byte(content)
+	}
+
+	return fixes
+}
+
 // tryReformatDate attempts to parse common non-W3CDTF date formats and
 // returns a W3CDTF-compliant string, or "" if unparseable.
 func tryReformatDate(s string) string {
@@ -682,7 +998,10 @@ func fixFilesNotInManifest(files map[string][]byte, ep *epub.EPUB) []Fix {
 	}
 
 	var insertions []string
-	for name := range files {
+	for name, data := range files {
+		if data == nil {
+			continue // removed by fixExtraneousFiles
+		}
 		if ignorePaths[name] {
 			continue
 		}
@@ -731,6 +1050,73 @@ func fixFilesNotInManifest(files map[string][]byte, ep *epub.EPUB) []Fix {
 	return fixes
 }
 
+// isOSJunk reports whether name is metadata left by an operating system
+// or archiver rather than publication content: .DS_Store, Thumbs.db,
+// desktop.ini, AppleDouble "._" files, and anything under __MACOSX/.
+func isOSJunk(name string) bool {
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return true
+	}
+	base := path.Base(name)
+	switch base {
+	case ".DS_Store", "Thumbs.db", "desktop.ini":
+		return true
+	}
+	return strings.HasPrefix(base, "._")
+}
+
+// fixExtraneousFiles removes container entries that are not in the
+// manifest. OS junk files are always removed; with opts.RemoveUnlisted,
+// every other unlisted file outside META-INF is removed too, instead of
+// being added to the manifest by fixFilesNotInManifest. Removed files are
+// set to nil in files so the writer leaves them out. Fixes RSC-002.
+func fixExtraneousFiles(files map[string][]byte, ep *epub.EPUB, opts RepairOptions) []Fix {
+	if ep.Package == nil {
+		return nil
+	}
+
+	manifestPaths := make(map[string]bool)
+	for _, item := range ep.Package.Manifest {
+		if item.Href != "\x00MISSING" {
+			manifestPaths[ep.ResolveHref(item.Href)] = true
+		}
+	}
+
+	var names []string
+	for name, data := range files {
+		if data == nil || manifestPaths[name] {
+			continue
+		}
+		if name == "mimetype" || name == ep.RootfilePath {
+			continue
+		}
+		// container.xml, encryption.xml, signatures.xml and the other
+		// META-INF files are required or reserved by OCF.
+		if strings.HasPrefix(name, "META-INF/") {
+			continue
+		}
+		if isOSJunk(name) || opts.RemoveUnlisted {
+			names = append(names, name)
+		}
+	}
+	sortStrings(names)
+
+	var fixes []Fix
+	for _, name := range names {
+		files[name] = nil
+		desc := fmt.Sprintf("Removed '%s', which is not in the manifest", name)
+		if isOSJunk(name) {
+			desc = fmt.Sprintf("Removed operating system metadata file '%s'", name)
+		}
+		fixes = append(fixes, Fix{
+			CheckID:     "RSC-002",
+			Description: desc,
+			File:        name,
+		})
+	}
+	return fixes
+}
+
 // generateUniqueID creates a unique manifest item ID based on the filename.
 func generateUniqueID(filePath string, existing map[string]bool) string {
 	// Use the filename without extension as the base
@@ -966,6 +1352,7 @@ func resolveCSSPath(cssDir, rel string) string {
 // fixEncodingDeclaration fixes non-UTF-8 encoding declarations in XHTML content.
 // For ENC-001: changes encoding declaration to UTF-8, transcoding if needed.
 // For ENC-002: transcodes UTF-16 files to UTF-8.
+// For ENC-010: changes a UTF-16 declaration on UTF-8 content to UTF-8.
 func fixEncodingDeclaration(files map[string][]byte, ep *epub.EPUB) []Fix {
 	if ep.Package == nil {
 		return nil
@@ -992,7 +1379,7 @@ func fixEncodingDeclaration(files map[string][]byte, ep *epub.EPUB) []Fix {
 		if bytes.HasPrefix(data, utf16LEBOM) {
 			utf8Data, err := transcodeUTF16ToUTF8(data, false) // little-endian
 			if err == nil {
-				files[fullPath] = utf8Data
+				files[fullPath] = xmlEncodingRe.ReplaceAll(utf8Data, []byte("${1}UTF-8${3}"))
 				fixes = append(fixes, Fix{
 					CheckID:     "ENC-002",
 					Description: fmt.Sprintf("Transcoded from UTF-16LE to UTF-8"),
@@ -1004,7 +1391,7 @@ func fixEncodingDeclaration(files map[string][]byte, ep *epub.EPUB) []Fix {
 		if bytes.HasPrefix(data, utf16BEBOM) {
 			utf8Data, err := transcodeUTF16ToUTF8(data, true) // big-endian
 			if err == nil {
-				files[fullPath] = utf8Data
+				files[fullPath] = xmlEncodingRe.ReplaceAll(utf8Data, []byte("${1}UTF-8${3}"))
 				fixes = append(fixes, Fix{
 					CheckID:     "ENC-002",
 					Description: fmt.Sprintf("Transcoded from UTF-16BE to UTF-8"),
@@ -1037,12 +1424,17 @@ func fixEncodingDeclaration(files map[string][]byte, ep *epub.EPUB) []Fix {
 				File:        fullPath,
 			})
 		} else if isValidUTF8(data) {
-			// File is actually valid UTF-8, just fix the declaration
+			// File is actually valid UTF-8, just fix the declaration.
+			// A UTF-16 declaration on UTF-8 bytes is reported as ENC-010.
+			checkID := "ENC-001"
+			if strings.HasPrefix(declaredEnc, "utf-16") {
+				checkID = "ENC-010"
+			}
 			content := string(data)
 			newContent := xmlEncodingRe.ReplaceAllString(content, "${1}UTF-8${3}")
 			files[fullPath] = []byte(newContent)
 			fixes = append(fixes, Fix{
-				CheckID:     "ENC-001",
+				CheckID:     checkID,
 				Description: fmt.Sprintf("Fixed encoding declaration from '%s' to 'UTF-8' (content was already UTF-8)", matches[2]),
 				File:        fullPath,
 			})
@@ -1383,6 +1775,117 @@ func fixInvalidLinear(files map[string][]byte, ep *epub.EPUB) []Fix {
 	return fixes
 }
 
+// fixPropertiesTokens collapses whitespace and drops duplicate tokens in
+// manifest properties attributes. Fixes OPF-081.
+func fixPropertiesTokens(files map[string][]byte, ep *epub.EPUB) []Fix {
+	if ep.Package == nil {
+		return nil
+	}
+
+	opfPath := ep.RootfilePath
+	data, ok := files[opfPath]
+	if !ok {
+		return nil
+	}
+
+	opf := string(data)
+	var fixes []Fix
+	for _, item := range ep.Package.Manifest {
+		if item.Properties == "" || item.ID == "" {
+			continue
+		}
+		seen := make(map[string]bool)
+		var tokens []string
+		for _, t := range strings.Fields(item.Properties) {
+			if !seen[t] {
+				seen[t] = true
+				tokens = append(tokens, t)
+			}
+		}
+		normalized := strings.Join(tokens, " ")
+		if normalized == item.Properties {
+			continue
+		}
+		updated := fixManifestItemProperties(opf, item.ID, item.Properties, normalized)
+		if updated == opf {
+			continue
+		}
+		opf = updated
+		fixes = append(fixes, Fix{
+			CheckID:     "OPF-081",
+			Description: fmt.Sprintf("Normalized properties on '%s' to '%s'", item.ID, normalized),
+			File:        opfPath,
+		})
+	}
+
+	if len(fixes) > 0 {
+		files[opfPath] = []byte(opf)
+	}
+	return fixes
+}
+
+// fixCoverImageProperty marks the cover image with the cover-image property
+// in an EPUB 3 that has none. The cover is the image named by an EPUB 2
+// <meta name="cover">, or else the only image whose id or file name
+// contains "cover"; if there is no such image, or several, nothing is
+// changed. Fixes OPF-060.
+func fixCoverImageProperty(files map[string][]byte, ep *epub.EPUB) []Fix {
+	if ep.Package == nil || ep.Package.Version < "3.0" {
+		return nil
+	}
+
+	opfPath := ep.RootfilePath
+	data, ok := files[opfPath]
+	if !ok {
+		return nil
+	}
+
+	var cover *epub.ManifestItem
+	var candidates []*epub.ManifestItem
+	metaCover := ""
+	if names := ep.Package.Metadata.NamedMeta["cover"]; len(names) > 0 {
+		metaCover = names[0]
+	}
+	for i := range ep.Package.Manifest {
+		item := &ep.Package.Manifest[i]
+		if hasProperty(item.Properties, "cover-image") {
+			return nil
+		}
+		if item.ID == "" || !strings.HasPrefix(item.MediaType, "image/") {
+			continue
+		}
+		if item.ID == metaCover {
+			cover = item
+		}
+		if strings.Contains(strings.ToLower(item.ID), "cover") ||
+			strings.Contains(strings.ToLower(path.Base(item.Href)), "cover") {
+			candidates = append(candidates, item)
+		}
+	}
+	if cover == nil && len(candidates) == 1 {
+		cover = candidates[0]
+	}
+	if cover == nil {
+		return nil
+	}
+
+	newProps := "cover-image"
+	if cover.Properties != "" {
+		newProps = cover.Properties + " cover-image"
+	}
+	opf := string(data)
+	updated := fixManifestItemProperties(opf, cover.ID, cover.Properties, newProps)
+	if updated == opf {
+		return nil
+	}
+	files[opfPath] = []byte(updated)
+	return []Fix{{
+		CheckID:     "OPF-060",
+		Description: fmt.Sprintf("Added 'cover-image' property to manifest item '%s'", cover.ID),
+		File:        opfPath,
+	}}
+}
+
 // fixBaseElement removes <base> elements from XHTML content documents.
 // Fixes HTM-009.
 func fixBaseElement(files map[string][]byte, ep *epub.EPUB) []Fix {
@@ -1528,6 +2031,80 @@ func fixLangXMLLangMismatch(files map[string][]byte, ep *epub.EPUB) []Fix {
 	return fixes
 }
 
+// fixMissingHTMLLang declares the language on the root html element of
+// content documents that lack lang or xml:lang, using the package's first
+// dc:language. When one of the two is present the other is added with its
+// value, so no mismatch (HTM-026) is introduced. Prefixed roots such as
+// <h:html> are handled. Fixes ACC-003.
+func fixMissingHTMLLang(files map[string][]byte, ep *epub.EPUB) []Fix {
+	if ep.Package == nil || len(ep.Package.Metadata.Languages) == 0 {
+		return nil
+	}
+	pkgLang := strings.TrimSpace(ep.Package.Metadata.Languages[0])
+	if pkgLang == "" {
+		return nil
+	}
+
+	htmlRe := regexp.MustCompile(`<(?:[A-Za-z_][\w.-]*:)?html\b[^>]*>`)
+	langRe := regexp.MustCompile(`\slang\s*=\s*["']([^"']*)["']`)
+	xmlLangRe := regexp.MustCompile(`\sxml:lang\s*=\s*["']([^"']*)["']`)
+
+	var fixes []Fix
+	for _, item := range ep.Package.Manifest {
+		if item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" {
+			continue
+		}
+
+		fullPath := ep.ResolveHref(item.Href)
+		data, ok := files[fullPath]
+		if !ok {
+			continue
+		}
+
+		content := string(data)
+		loc := htmlRe.FindStringIndex(content)
+		if loc == nil {
+			continue
+		}
+		tag := content[loc[0]:loc[1]]
+		langMatch := langRe.FindStringSubmatch(tag)
+		xmlLangMatch := xmlLangRe.FindStringSubmatch(tag)
+		if langMatch != nil && xmlLangMatch != nil {
+			continue
+		}
+
+		lang := pkgLang
+		var attrs string
+		switch {
+		case langMatch != nil:
+			lang = langMatch[1]
+			attrs = ` xml:lang="` + xmlEscape(lang) + `"`
+		case xmlLangMatch != nil:
+			lang = xmlLangMatch[1]
+			attrs = ` lang="` + xmlEscape(lang) + `"`
+		default:
+			attrs = ` lang="` + xmlEscape(lang) + `" xml:lang="` + xmlEscape(lang) + `"`
+		}
+
+		// Insert before the closing > (or />) of the start tag.
+		end := len(tag) - 1
+		if strings.HasSuffix(tag, "/>") {
+			end--
+		}
+		newTag := strings.TrimRight(tag[:end], " \t\r\n") + attrs + tag[end:]
+		content = content[:loc[0]] + newTag + content[loc[1]:]
+		files[fullPath] = []byte(content)
+
+		fixes = append(fixes, Fix{
+			CheckID:     "ACC-003",
+			Description: fmt.Sprintf("Declared language '%s' on the html element", lang),
+			File:        fullPath,
+		})
+	}
+
+	return fixes
+}
+
 // fixMissingTitle adds a <title> element to content documents that lack one.
 // Fixes HTM-002.
 func fixMissingTitle(files map[string][]byte, ep *epub.EPUB) []Fix {
@@ -1626,3 +2203,263 @@ func navDocHasToc(data []byte) bool {
 	}
 	return false
 }
+
+// fixMissingNav generates a navigation document for EPUB 3 publications
+// that have none. When the spine names an NCX, its navMap is converted,
+// keeping nesting and playOrder, and the NCX is left in place for EPUB 2
+// reading systems. Otherwise the table of contents lists each XHTML spine
+// document, labelled with its <title> (or "Section N" when it has none).
+// Fixes NAV-001.
+func fixMissingNav(files map[string][]byte, ep *epub.EPUB) []Fix {
+	if ep.Package == nil || ep.Package.Version < "3.0" {
+		return nil
+	}
+
+	manifestByID := make(map[string]epub.ManifestItem)
+	manifestIDs := make(map[string]bool)
+	for _, item := range ep.Package.Manifest {
+		if strings.Contains(" "+item.Properties+" ", " nav ") {
+			return nil
+		}
+		manifestByID[item.ID] = item
+		manifestIDs[item.ID] = true
+	}
+
+	opfData, ok := files[ep.RootfilePath]
+	if !ok {
+		return nil
+	}
+	content := string(opfData)
+	manifestClose := strings.Index(content, "</manifest>")
+	if manifestClose == -1 {
+		manifestClose = findClosingTag(content, "manifest")
+	}
+	if manifestClose == -1 {
+		return nil
+	}
+
+	// Pick a file name that is not already in the container
+	navPath := ep.ResolveHref("nav.xhtml")
+	for n := 2; files[navPath] != nil; n++ {
+		navPath = ep.ResolveHref(fmt.Sprintf("nav%d.xhtml", n))
+	}
+	href := relativeHref(ep.RootfilePath, navPath)
+
+	var list string
+	var description string
+	if ncx, ok := manifestByID[ep.Package.SpineToc]; ok && ncx.MediaType == "application/x-dtbncx+xml" {
+		ncxPath := ep.ResolveHref(ncx.Href)
+		if points := parseNCXNavMap(files[ncxPath]); len(points) > 0 {
+			var lines []string
+			count := renderNavPoints(points, path.Dir(ncxPath), ep.RootfilePath, "    ", &lines)
+			list = strings.Join(lines, "\n")
+			description = fmt.Sprintf("Converted NCX '%s' into navigation document '%s' (%d entries)", ncx.Href, href, count)
+		}
+	}
+	if list == "" {
+		var entries []string
+		for _, ref := range ep.Package.Spine {
+			item, ok := manifestByID[ref.IDRef]
+			if !ok || item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" {
+				continue
+			}
+			label := documentTitle(files[ep.ResolveHref(item.Href)])
+			if label == "" {
+				label = fmt.Sprintf("Section %d", len(entries)+1)
+			}
+			entries = append(entries, fmt.Sprintf(`      <li><a href="%s">%s</a></li>`,
+				xmlEscape(item.Href), xmlEscape(label)))
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		list = "    <ol>\n" + strings.Join(entries, "\n") + "\n    </ol>"
+		description = fmt.Sprintf("Generated navigation document '%s' with %d table of contents entries", href, len(entries))
+	}
+
+	lang := "und"
+	if len(ep.Package.Metadata.Languages) > 0 && ep.Package.Metadata.Languages[0] != "" {
+		lang = ep.Package.Metadata.Languages[0]
+	}
+
+	files[navPath] = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="` + xmlEscape(lang) + `" xml:lang="` + xmlEscape(lang) + `">
+<head>
+  <title>Contents</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>Contents</h1>
+` + list + `
+  </nav>
+</body>
+</html>
+`)
+
+	id := generateUniqueID(navPath, manifestIDs)
+	insertion := fmt.Sprintf(`    <item id="%s" href="%s" media-type="application/xhtml+xml" properties="nav"/>`, id, href) + "\n  "
+	files[ep.RootfilePath] = []byte(content[:manifestClose] + insertion + content[manifestClose:])
+
+	return []Fix{{
+		CheckID:     "NAV-001",
+		Description: description,
+		File:        navPath,
+	}}
+}
+
+// ncxNavPoint is a navPoint from an NCX navMap.
+type ncxNavPoint struct {
+	label     string
+	src       string
+	playOrder int
+	children  []ncxNavPoint
+}
+
+// parseNCXNavMap returns the top-level navPoints of an NCX navMap with
+// their children, each level ordered by playOrder.
+func parseNCXNavMap(data []byte) []ncxNavPoint {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	var stack []*ncxNavPoint
+	var roots []ncxNavPoint
+	inMap, inText := false, false
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "navMap":
+				inMap = true
+			case "navPoint":
+				if !inMap {
+					continue
+				}
+				np := &ncxNavPoint{}
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "playOrder" {
+						fmt.Sscanf(attr.Value, "%d", &np.playOrder)
+					}
+				}
+				stack = append(stack, np)
+			case "content":
+				if len(stack) > 0 {
+					for _, attr := range t.Attr {
+						if attr.Name.Local == "src" {
+							stack[len(stack)-1].src = attr.Value
+						}
+					}
+				}
+			case "text":
+				inText = len(stack) > 0
+			}
+		case xml.CharData:
+			if inText {
+				stack[len(stack)-1].label += string(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "navMap":
+				inMap = false
+			case "text":
+				inText = false
+			case "navPoint":
+				if len(stack) == 0 {
+					continue
+				}
+				np := *stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				np.label = strings.Join(strings.Fields(np.label), " ")
+				if len(stack) > 0 {
+					parent := stack[len(stack)-1]
+					parent.children = append(parent.children, np)
+				} else {
+					roots = append(roots, np)
+				}
+			}
+		}
+	}
+	sortNavPoints(roots)
+	return roots
+}
+
+// sortNavPoints orders each level by playOrder, keeping document order
+// for ties and points without one.
+func sortNavPoints(points []ncxNavPoint) {
+	for i := 1; i < len(points); i++ {
+		for j := i; j > 0 && points[j].playOrder > 0 && points[j].playOrder < points[j-1].playOrder; j-- {
+			points[j], points[j-1] = points[j-1], points[j]
+		}
+	}
+	for i := range points {
+		sortNavPoints(points[i].children)
+	}
+}
+
+// renderNavPoints appends a nav <ol> for points to lines, rewriting each
+// src from the NCX directory to the package document directory, where the
+// nav document is written. It returns the number of entries rendered.
+func renderNavPoints(points []ncxNavPoint, ncxDir, opfPath, indent string, lines *[]string) int {
+	count := 0
+	*lines = append(*lines, indent+"<ol>")
+	for _, np := range points {
+		target, fragment, _ := strings.Cut(np.src, "#")
+		href := relativeHref(opfPath, path.Join(ncxDir, target))
+		if fragment != "" {
+			href += "#" + fragment
+		}
+		label := np.label
+		if label == "" {
+			label = fmt.Sprintf("Section %d", count+1)
+		}
+		link := fmt.Sprintf(`<a href="%s">%s</a>`, xmlEscape(href), xmlEscape(label))
+		count++
+		if len(np.children) == 0 {
+			*lines = append(*lines, indent+"  <li>"+link+"</li>")
+			continue
+		}
+		*lines = append(*lines, indent+"  <li>"+link)
+		count += renderNavPoints(np.children, ncxDir, opfPath, indent+"    ", lines)
+		*lines = append(*lines, indent+"  </li>")
+	}
+	*lines = append(*lines, indent+"</ol>")
+	return count
+}
+
+// documentTitle returns the trimmed text of an XHTML document's <title>.
+func documentTitle(data []byte) string {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	decoder.Strict = false
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "title" {
+			var text strings.Builder
+			for {
+				tok, err := decoder.Token()
+				if err != nil {
+					return ""
+				}
+				switch t := tok.(type) {
+				case xml.CharData:
+					text.Write(t)
+				case xml.EndElement:
+					if t.Name.Local == "title" {
+						return strings.Join(strings.Fields(text.String()), " ")
+					}
+				}
+			}
+		}
+	}
+}
+
+// xmlEscape escapes s for use in XML text and attribute values.
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,232 @@
+package doctor
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/adammathes/epubverify/pkg/epub"
+)
+
+// Unusual whitespace characters replaced by a plain space, and zero-width
+// characters removed, when RepairOptions.NormalizeWhitespace is set.
+var unusualSpaces = map[rune]bool{
+	'\u00a0': true, // no-break space
+	'\u2000': true, '\u2001': true, '\u2002': true, '\u2003': true,
+	'\u2004': true, '\u2005': true, '\u2006': true, '\u2007': true,
+	'\u2008': true, '\u2009': true, '\u200a': true,
+	'\u202f': true, // narrow no-break space
+	'\u205f': true, // medium mathematical space
+	'\u3000': true, // ideographic space
+}
+
+var zeroWidthChars = map[rune]bool{
+	'\u200b': true, // zero-width space
+	'\ufeff': true, // zero-width no-break space (BOM) inside text
+}
+
+var curlyToStraight = map[rune]rune{
+	'\u2018': '\'', '\u2019': '\'', '\u201a': '\'', '\u201b': '\'',
+	'\u201c': '"', '\u201d': '"', '\u201e': '"', '\u201f': '"',
+}
+
+// Block-level elements; text after one of these starts fresh when choosing
+// between opening and closing quotes.
+var typographyBlockElements = map[string]bool{
+	"p": true, "div": true, "li": true, "td": true, "th": true, "br": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "section": true, "body": true, "dd": true, "dt": true,
+}
+
+// Elements whose text content is left alone.
+var typographySkipElements = map[string]bool{
+	"script": true, "style": true, "pre": true, "code": true,
+}
+
+// fixTypography normalizes quotes and whitespace in the text content of
+// XHTML content documents, as selected by opts. Markup, attribute values,
+// comments, and the contents of script/style/pre/code are never touched.
+// Running it twice produces no further changes.
+func fixTypography(files map[string][]byte, ep *epub.EPUB, opts RepairOptions) []Fix {
+	if ep.Package == nil || !opts.typography() {
+		return nil
+	}
+
+	var fixes []Fix
+	for _, item := range ep.Package.Manifest {
+		if item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		data, ok := files[fullPath]
+		if !ok {
+			continue
+		}
+
+		out, n := normalizeTypography(string(data), opts)
+		if n == 0 {
+			continue
+		}
+		files[fullPath] = []byte(out)
+
+		var what []string
+		if opts.StraightQuotes {
+			what = append(what, "straightened quotes")
+		}
+		if opts.CurlyQuotes {
+			what = append(what, "curled quotes")
+		}
+		if opts.NormalizeWhitespace {
+			what = append(what, "normalized whitespace")
+		}
+		fixes = append(fixes, Fix{
+			CheckID:     "TYPOGRAPHY",
+			Description: fmt.Sprintf("Changed %d character(s) in text content (%s)", n, strings.Join(what, ", ")),
+			File:        fullPath,
+		})
+	}
+	return fixes
+}
+
+// normalizeTypography rewrites the text nodes of an XHTML document and
+// returns the new document and the number of characters changed.
+func normalizeTypography(doc string, opts RepairOptions) (string, int) {
+	var b strings.Builder
+	b.Grow(len(doc))
+	changed := 0
+	skipDepth := 0
+	prev := ' ' // last text rune seen, for choosing opening vs closing quotes
+
+	for i := 0; i < len(doc); {
+		if doc[i] == '<' {
+			end := markupEnd(doc, i)
+			tag := doc[i:end]
+			b.WriteString(tag)
+			name, closing, selfClosing := tagName(tag)
+			if typographyBlockElements[name] {
+				prev = ' '
+			}
+			if typographySkipElements[name] && !selfClosing {
+				if closing {
+					if skipDepth > 0 {
+						skipDepth--
+					}
+				} else {
+					skipDepth++
+				}
+			}
+			i = end
+			continue
+		}
+
+		// Leave entity and character references intact
+		if doc[i] == '&' {
+			if semi := strings.IndexByte(doc[i:], ';'); semi > 0 && semi < 12 {
+				b.WriteString(doc[i : i+semi+1])
+				i += semi + 1
+				prev = 'x'
+				continue
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(doc[i:])
+		i += size
+		if skipDepth > 0 {
+			b.WriteRune(r)
+			continue
+		}
+
+		out, n := normalizeRune(r, prev, opts)
+		changed += n
+		if out >= 0 {
+			b.WriteRune(out)
+			prev = out
+		}
+	}
+	return b.String(), changed
+}
+
+// normalizeRune maps a single text rune. It returns -1 to drop the rune,
+// and 1 as the change count if the rune was replaced or dropped.
+func normalizeRune(r, prev rune, opts RepairOptions) (rune, int) {
+	if opts.NormalizeWhitespace {
+		if unusualSpaces[r] {
+			return ' ', 1
+		}
+		if zeroWidthChars[r] {
+			return -1, 1
+		}
+	}
+	if opts.StraightQuotes {
+		if s, ok := curlyToStraight[r]; ok {
+			return s, 1
+		}
+	}
+	if opts.CurlyQuotes {
+		opening := unicode.IsSpace(prev) || strings.ContainsRune("([{\u2014\u2013-\u201c\u2018", prev)
+		switch r {
+		case '"':
+			if opening {
+				return '\u201c', 1
+			}
+			return '\u201d', 1
+		case '\'':
+			if opening {
+				return '\u2018', 1
+			}
+			return '\u2019', 1
+		}
+	}
+	return r, 0
+}
+
+// markupEnd returns the index just past the markup construct starting at
+// doc[i] == '<', handling comments, CDATA, and quoted attribute values.
+func markupEnd(doc string, i int) int {
+	for _, d := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}} {
+		if strings.HasPrefix(doc[i:], d[0]) {
+			if j := strings.Index(doc[i+len(d[0]):], d[1]); j >= 0 {
+				return i + len(d[0]) + j + len(d[1])
+			}
+			return len(doc)
+		}
+	}
+	var quote byte
+	for j := i + 1; j < len(doc); j++ {
+		c := doc[j]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return j + 1
+		}
+	}
+	return len(doc)
+}
+
+// tagName extracts the local element name from a start or end tag.
+func tagName(tag string) (name string, closing, selfClosing bool) {
+	if len(tag) < 2 || tag[1] == '!' || tag[1] == '?' {
+		return "", false, false
+	}
+	s := tag[1:]
+	if s[0] == '/' {
+		closing = true
+		s = s[1:]
+	}
+	selfClosing = strings.HasSuffix(tag, "/>")
+	end := strings.IndexAny(s, " \t\r\n/>")
+	if end < 0 {
+		end = len(s)
+	}
+	name = s[:end]
+	if colon := strings.IndexByte(name, ':'); colon >= 0 {
+		name = name[colon+1:]
+	}
+	return strings.ToLower(name), closing, selfClosing
+}
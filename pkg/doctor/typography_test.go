@@ -0,0 +1,70 @@
+package doctor
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeTypographyStraightQuotes(t *testing.T) {
+	in := "<p title=\"“attr”\">“Hello,” she said. It’s <code>‘x’</code></p>"
+	want := "<p title=\"“attr”\">\"Hello,\" she said. It's <code>‘x’</code></p>"
+
+	got, n := normalizeTypography(in, RepairOptions{StraightQuotes: true})
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if n != 3 {
+		t.Errorf("changed %d characters, want 3", n)
+	}
+
+	// Idempotent
+	again, n := normalizeTypography(got, RepairOptions{StraightQuotes: true})
+	if again != got || n != 0 {
+		t.Errorf("second pass changed %d characters: %q", n, again)
+	}
+}
+
+func TestNormalizeTypographyCurlyQuotes(t *testing.T) {
+	in := `<p>He said "it's fine" today.</p><p>"Next"</p><script>var s = "x";</script>`
+	want := "<p>He said “it’s fine” today.</p><p>“Next”</p><script>var s = \"x\";</script>"
+
+	got, _ := normalizeTypography(in, RepairOptions{CurlyQuotes: true})
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if again, n := normalizeTypography(got, RepairOptions{CurlyQuotes: true}); again != got || n != 0 {
+		t.Errorf("second pass changed %d characters", n)
+	}
+}
+
+func TestNormalizeTypographyWhitespace(t *testing.T) {
+	in := "<!-- a\u00a0b --><p>one\u00a0two\u2009three\u200bfour &amp; five</p>"
+	want := "<!-- a\u00a0b --><p>one two threefour &amp; five</p>"
+
+	got, n := normalizeTypography(in, RepairOptions{NormalizeWhitespace: true})
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if n != 3 {
+		t.Errorf("changed %d characters, want 3", n)
+	}
+}
+
+func TestDoctorTypographyOptIn(t *testing.T) {
+	input := createTestEPUB(t, defaultOpts())
+	output := filepath.Join(t.TempDir(), "fixed.epub")
+
+	if _, err := RepairWithOptions(input, output, RepairOptions{StraightQuotes: true, CurlyQuotes: true}); err == nil {
+		t.Error("expected an error when both quote styles are requested")
+	}
+
+	result, err := RepairWithOptions(input, output, RepairOptions{CurlyQuotes: true})
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	for _, fix := range result.Fixes {
+		if fix.CheckID != "TYPOGRAPHY" {
+			t.Errorf("unexpected fix on a valid book: %+v", fix)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package doctor
 
 import (
 	"archive/zip"
+	"bytes"
 	"io"
 	"os"
 )
@@ -9,15 +10,19 @@ import (
 // writeEPUB creates a new EPUB file from modified in-memory contents.
 // It ensures the mimetype entry is written first, stored (not compressed),
 // with no extra field — satisfying OCF-002 through OCF-005.
-func writeEPUB(path string, files map[string][]byte, originalZip *zip.ReadCloser) error {
+func writeEPUB(path string, files map[string][]byte, originalZip *zip.Reader) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	w := zip.NewWriter(f)
-	defer w.Close()
+	return writeEPUBTo(f, files, originalZip)
+}
+
+// writeEPUBTo is like writeEPUB but writes the archive to out.
+func writeEPUBTo(out io.Writer, files map[string][]byte, originalZip *zip.Reader) error {
+	w := zip.NewWriter(out)
 
 	// Step 1: Write mimetype first, stored, no extra field.
 	if mimedata, ok := files["mimetype"]; ok {
@@ -36,41 +41,85 @@ func writeEPUB(path string, files map[string][]byte, originalZip *zip.ReadCloser
 		}
 	}
 
-	// Step 2: Write all other files.
-	// Preserve original compression method and order from the original zip.
+	// Step 2: Write all other files in their original order. Files no fix
+	// changed are copied through still compressed, so their compression
+	// method, modified time and bytes stay as they were.
 	for _, original := range originalZip.File {
 		if original.Name == "mimetype" {
 			continue // Already written
 		}
 
-		header := original.FileHeader
-		// Use the modified content if available, otherwise copy original
-		if modified, ok := files[original.Name]; ok {
-			mw, err := w.CreateHeader(&header)
-			if err != nil {
-				return err
-			}
-			if _, err := mw.Write(modified); err != nil {
-				return err
-			}
-		} else {
-			// Copy original file unchanged
-			rc, err := original.Open()
-			if err != nil {
-				return err
-			}
-			mw, err := w.CreateHeader(&header)
+		modified, ok := files[original.Name]
+		if ok && modified == nil {
+			continue // Removed by a fix
+		}
+		if ok {
+			changed, err := contentChanged(original, modified)
 			if err != nil {
-				rc.Close()
 				return err
 			}
-			if _, err := io.Copy(mw, rc); err != nil {
-				rc.Close()
+			ok = changed
+		}
+		if !ok {
+			if err := w.Copy(original); err != nil {
 				return err
 			}
-			rc.Close()
+			continue
+		}
+
+		header := original.FileHeader
+		mw, err := w.CreateHeader(&header)
+		if err != nil {
+			return err
+		}
+		if _, err := mw.Write(modified); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	// Step 3: Write files created by fixes, such as a generated nav
+	// document, in name order after the originals.
+	inOriginal := make(map[string]bool)
+	for _, original := range originalZip.File {
+		inOriginal[original.Name] = true
+	}
+	var added []string
+	for name := range files {
+		if name != "mimetype" && !inOriginal[name] && files[name] != nil {
+			added = append(added, name)
+		}
+	}
+	sortStrings(added)
+	for _, name := range added {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		header.SetMode(0644)
+		mw, err := w.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := mw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+// contentChanged reports whether data differs from the uncompressed
+// contents of the original entry.
+func contentChanged(original *zip.File, data []byte) (bool, error) {
+	if original.UncompressedSize64 != uint64(len(data)) {
+		return true, nil
+	}
+	rc, err := original.Open()
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	// The sizes match, so read at most one byte more than data holds.
+	orig, err := io.ReadAll(io.LimitReader(rc, int64(len(data))+1))
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(orig, data), nil
 }
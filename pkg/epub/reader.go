@@ -2,13 +2,38 @@ package epub
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 )
 
+// MaxFileSize is the largest uncompressed entry ReadFile will load into
+// memory. Entries of any size, including zip64 ones, can still be listed
+// and inspected through Files.
+const MaxFileSize = 1 << 30
+
+// ErrFileTooLarge is returned by ReadFile for entries over MaxFileSize.
+var ErrFileTooLarge = errors.New("file exceeds maximum readable size")
+
+// ErrDecompressionLimit is returned by ReadFile for an entry that
+// decompresses to more than EPUB.MaxDecompressedBytes.
+var ErrDecompressionLimit = errors.New("entry exceeds decompression limit")
+
+// ErrUnsafePath is returned by Open and OpenFromReaderAt for archives with
+// an entry that is absolute or climbs out of the container root with "..".
+// Such an entry could overwrite files elsewhere if the book were unpacked.
+var ErrUnsafePath = errors.New("entry path escapes the container root")
+
 // Open opens an EPUB file and parses its structure.
 // The caller must call Close() when done.
 func Open(filepath string) (*EPUB, error) {
@@ -16,40 +41,258 @@ func Open(filepath string) (*EPUB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("opening epub: %w", err)
 	}
+	// zip.ReadCloser does not expose its file, so Raw opens its own
+	// handle, and only if a check reads the zip headers directly.
+	raw := &lazyFile{path: filepath}
+
+	ep, err := newEPUB(&zr.Reader, raw)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	ep.Path = filepath
+	ep.ZipFile = zr
+	ep.closer = raw
+	return ep, nil
+}
+
+// lazyFile is an io.ReaderAt over a file that is opened on first read.
+type lazyFile struct {
+	path string
+	once sync.Once
+	f    *os.File
+	err  error
+}
+
+func (l *lazyFile) ReadAt(p []byte, off int64) (int, error) {
+	l.once.Do(func() { l.f, l.err = os.Open(l.path) })
+	if l.err != nil {
+		return 0, l.err
+	}
+	return l.f.ReadAt(p, off)
+}
+
+// Close closes the file if it was opened.
+func (l *lazyFile) Close() error {
+	l.once.Do(func() {}) // no open after Close
+	if l.f != nil {
+		return l.f.Close()
+	}
+	return nil
+}
+
+// OpenFromBytes opens an EPUB held entirely in memory, as when it is handed
+// over from a browser or read from a network stream.
+func OpenFromBytes(data []byte) (*EPUB, error) {
+	return OpenFromReaderAt(bytes.NewReader(data), int64(len(data)))
+}
+
+// OpenDir opens an unpacked EPUB, a directory holding what would be the
+// contents of the archive. The files are packed into an in-memory zip,
+// mimetype first, so Files and ReadFile behave exactly as for a zipped
+// book. FromDir is set, since the zip structure itself is synthetic.
+//
+// Hidden files and directories, such as .git or an editor's swap files,
+// are left out, as they would be when the book is zipped for release.
+// A file larger than maxFileBytes fails with ErrDecompressionLimit before
+// anything is buffered; zero means MaxFileSize.
+func OpenDir(dir string, maxFileBytes int64) (*EPUB, error) {
+	if maxFileBytes <= 0 || maxFileBytes > MaxFileSize {
+		maxFileBytes = MaxFileSize
+	}
+	var names []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// Symlinks are followed; sockets, devices and the like are skipped.
+		fi, err := os.Stat(p)
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if fi.Size() > maxFileBytes {
+			return fmt.Errorf("%s is %d bytes, over the %d byte limit: %w", filepath.ToSlash(rel), fi.Size(), maxFileBytes, ErrDecompressionLimit)
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("opening epub directory: %w", err)
+	}
+	// WalkDir visits in lexical order; only mimetype needs moving.
+	sort.SliceStable(names, func(i, j int) bool {
+		return names[i] == "mimetype" && names[j] != "mimetype"
+	})
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, fmt.Errorf("opening epub directory: %w", err)
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return nil, fmt.Errorf("opening epub directory: %w", err)
+		}
+		if _, err := fw.Write(data); err != nil {
+			return nil, fmt.Errorf("opening epub directory: %w", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("opening epub directory: %w", err)
+	}
+
+	ep, err := OpenFromBytes(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	ep.Path = dir
+	ep.FromDir = true
+	ep.MaxDecompressedBytes = maxFileBytes
+	return ep, nil
+}
+
+// OpenFromReaderAt opens an EPUB from a random-access reader of the given size.
+func OpenFromReaderAt(ra io.ReaderAt, size int64) (*EPUB, error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, fmt.Errorf("opening epub: %w", err)
+	}
+	return newEPUB(zr, ra)
+}
 
+// newEPUB indexes the entries of zr, rejecting unsafe paths. ra is the
+// archive zr reads from.
+func newEPUB(zr *zip.Reader, ra io.ReaderAt) (*EPUB, error) {
 	ep := &EPUB{
-		Path:    filepath,
-		ZipFile: zr,
-		Files:   make(map[string]*zip.File),
+		Zip:   zr,
+		Files: make(map[string]*zip.File),
+		Raw:   ra,
 	}
 
 	for _, f := range zr.File {
+		if unsafePath(f.Name) {
+			return nil, fmt.Errorf("opening epub: entry %q: %w", f.Name, ErrUnsafePath)
+		}
 		ep.Files[f.Name] = f
 	}
 
 	return ep, nil
 }
 
-// Close releases the underlying zip reader.
+// unsafePath reports whether a zip entry name is absolute or resolves to a
+// location outside the archive root. Backslashes count as separators, as
+// some Windows tools write them and extractors there honour them.
+func unsafePath(name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") {
+		return true
+	}
+	// Drive-letter paths like "C:/evil" or a bare "C:" are absolute on
+	// Windows; "a:b.xhtml" is just an odd file name.
+	if len(name) >= 2 && name[1] == ':' && isASCIILetter(name[0]) && (len(name) == 2 || name[2] == '/') {
+		return true
+	}
+	clean := path.Clean(name)
+	return clean == ".." || strings.HasPrefix(clean, "../")
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// Close releases the underlying file, if any.
 func (ep *EPUB) Close() error {
+	var err error
 	if ep.ZipFile != nil {
-		return ep.ZipFile.Close()
+		err = ep.ZipFile.Close()
 	}
-	return nil
+	if ep.closer != nil {
+		if cerr := ep.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// SetContext makes ReadFile fail with ctx.Err() once ctx is done, so
+// loops over many entries stop promptly when the caller gives up.
+func (ep *EPUB) SetContext(ctx context.Context) {
+	ep.ctx = ctx
 }
 
 // ReadFile reads the contents of a file within the EPUB.
+//
+// Once an entry has hit MaxDecompressedBytes, ReadFile fails with the same
+// error for every entry, so loops over many entries stop promptly; see
+// DecompressionErr.
 func (ep *EPUB) ReadFile(name string) ([]byte, error) {
+	if ep.ctx != nil {
+		if err := ep.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if err := ep.DecompressionErr(); err != nil {
+		return nil, err
+	}
 	f, ok := ep.Files[name]
 	if !ok {
 		return nil, fmt.Errorf("file not found in epub: %s", name)
 	}
+	limit, limitErr := int64(MaxFileSize), ErrFileTooLarge
+	if ep.MaxDecompressedBytes > 0 && ep.MaxDecompressedBytes < limit {
+		limit, limitErr = ep.MaxDecompressedBytes, ErrDecompressionLimit
+	}
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, ep.sizeError(name, limit, limitErr)
+	}
 	rc, err := f.Open()
 	if err != nil {
 		return nil, fmt.Errorf("opening %s: %w", name, err)
 	}
 	defer rc.Close()
-	return io.ReadAll(rc)
+	// Guard against entries whose real size exceeds the declared one.
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, ep.sizeError(name, limit, limitErr)
+	}
+	return data, nil
+}
+
+// sizeError builds the error for an entry over limit, recording it for
+// DecompressionErr when it is the decompression limit that was hit.
+func (ep *EPUB) sizeError(name string, limit int64, limitErr error) error {
+	err := fmt.Errorf("reading %s: more than %d bytes uncompressed: %w", name, limit, limitErr)
+	if limitErr == ErrDecompressionLimit {
+		ep.decompressionErr.CompareAndSwap(nil, &err)
+	}
+	return err
+}
+
+// DecompressionErr returns the error from the first ReadFile call that hit
+// MaxDecompressedBytes, or nil if none has.
+func (ep *EPUB) DecompressionErr() error {
+	if err := ep.decompressionErr.Load(); err != nil {
+		return *err
+	}
+	return nil
 }
 
 // Container XML types
@@ -64,10 +307,15 @@ type rootFilesXML struct {
 }
 
 type rootFileXML struct {
-	FullPath  string `xml:"full-path,attr"`
-	MediaType string `xml:"media-type,attr"`
+	FullPath  string     `xml:"full-path,attr"`
+	MediaType string     `xml:"media-type,attr"`
+	Attrs     []xml.Attr `xml:",any,attr"`
 }
 
+// renditionNS is the namespace of the Multiple-Rendition selection
+// attributes on rootfile elements.
+const renditionNS = "http://www.idpf.org/2013/rendition"
+
 // ParseContainer parses META-INF/container.xml and sets RootfilePath.
 func (ep *EPUB) ParseContainer() error {
 	data, err := ep.ReadFile("META-INF/container.xml")
@@ -82,11 +330,22 @@ func (ep *EPUB) ParseContainer() error {
 	}
 
 	// Store all rootfiles
+	ep.AllRootfiles = nil
 	for _, rf := range c.RootFiles.RootFile {
-		ep.AllRootfiles = append(ep.AllRootfiles, Rootfile{
+		rootfile := Rootfile{
 			FullPath:  rf.FullPath,
 			MediaType: rf.MediaType,
-		})
+		}
+		for _, attr := range rf.Attrs {
+			// An undeclared rendition: prefix is left unresolved.
+			if attr.Name.Space == renditionNS || attr.Name.Space == "rendition" {
+				if rootfile.Rendition == nil {
+					rootfile.Rendition = make(map[string]string)
+				}
+				rootfile.Rendition[attr.Name.Local] = attr.Value
+			}
+		}
+		ep.AllRootfiles = append(ep.AllRootfiles, rootfile)
 	}
 
 	for _, rf := range c.RootFiles.RootFile {
@@ -144,9 +403,17 @@ func (ep *EPUB) ParseOPF() error {
 		p.Metadata = parseMetadata(data)
 	}
 
+	p.Metadata.Links = structInfo.links
+
 	// Parse rendition properties from metadata metas
 	modifiedCount := 0
 	for _, m := range structInfo.metas {
+		if m.refines == "" {
+			if p.Metadata.Meta == nil {
+				p.Metadata.Meta = make(map[string][]string)
+			}
+			p.Metadata.Meta[m.property] = append(p.Metadata.Meta[m.property], m.value)
+		}
 		switch m.property {
 		case "dcterms:modified":
 			p.Metadata.Modified = m.value
@@ -194,6 +461,7 @@ type opfStructInfo struct {
 	spineItems               []SpineItemref
 	metas                    []metaInfo
 	metaRefines              []MetaRefines
+	links                    []MetadataLink
 	guideRefs                []GuideReference
 	elementOrder             []string
 }
@@ -201,12 +469,16 @@ type opfStructInfo struct {
 type metaInfo struct {
 	property string
 	value    string
+	refines  string
 }
 
 // scanOPFStructure does a raw XML scan of the OPF to detect structural elements.
 func scanOPFStructure(data []byte) (*opfStructInfo, error) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
 	info := &opfStructInfo{}
+	// inMetadata is set inside the package metadata, so the links of a
+	// collection's own metadata are not taken for the package's.
+	inMetadata := false
 
 	for {
 		tok, err := decoder.Token()
@@ -217,6 +489,9 @@ func scanOPFStructure(data []byte) (*opfStructInfo, error) {
 			return nil, err
 		}
 
+		if ee, ok := tok.(xml.EndElement); ok && ee.Name.Local == "metadata" {
+			inMetadata = false
+		}
 		se, ok := tok.(xml.StartElement)
 		if !ok {
 			continue
@@ -237,8 +512,25 @@ func scanOPFStructure(data []byte) (*opfStructInfo, error) {
 				}
 			}
 		case "metadata":
+			inMetadata = !info.hasMetadata
 			info.hasMetadata = true
 			info.elementOrder = append(info.elementOrder, "metadata")
+		case "link":
+			var link MetadataLink
+			refines := ""
+			for _, attr := range se.Attr {
+				switch attr.Name.Local {
+				case "rel":
+					link.Rel = attr.Value
+				case "href":
+					link.Href = attr.Value
+				case "refines":
+					refines = attr.Value
+				}
+			}
+			if inMetadata && refines == "" {
+				info.links = append(info.links, link)
+			}
 		case "manifest":
 			info.hasManifest = true
 			info.elementOrder = append(info.elementOrder, "manifest")
@@ -300,7 +592,7 @@ func scanOPFStructure(data []byte) (*opfStructInfo, error) {
 				if cd, ok := inner.(xml.CharData); ok {
 					val = strings.TrimSpace(string(cd))
 				}
-				info.metas = append(info.metas, metaInfo{property: prop, value: val})
+				info.metas = append(info.metas, metaInfo{property: prop, value: val, refines: refines})
 				if refines != "" {
 					info.metaRefines = append(info.metaRefines, MetaRefines{
 						Refines:  refines,
@@ -370,6 +662,22 @@ func parseMetadata(data []byte) Metadata {
 				}
 				val := readElementText(decoder)
 				md.Creators = append(md.Creators, DCCreator{Value: val, Role: role})
+			case "meta":
+				var name, content string
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "name":
+						name = attr.Value
+					case "content":
+						content = attr.Value
+					}
+				}
+				if name != "" {
+					if md.NamedMeta == nil {
+						md.NamedMeta = make(map[string][]string)
+					}
+					md.NamedMeta[name] = append(md.NamedMeta[name], strings.TrimSpace(content))
+				}
 			}
 		case xml.EndElement:
 			if t.Name.Local == "metadata" {
@@ -1,6 +1,10 @@
 package epub
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -99,3 +103,253 @@ func TestParseOPF(t *testing.T) {
 		})
 	}
 }
+
+// buildZip64 returns an archive with more entries than a classic zip
+// directory can count, which forces archive/zip to write zip64 records.
+func buildZip64(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	mw, err := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw.Write([]byte("application/epub+zip"))
+	for i := 0; i < 0xffff; i++ {
+		if _, err := w.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("OEBPS/f%05d", i), Method: zip.Store}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cw, _ := w.Create("OEBPS/last.txt")
+	cw.Write([]byte("last"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenZip64(t *testing.T) {
+	data := buildZip64(t)
+	if !bytes.Contains(data, []byte{0x50, 0x4b, 0x06, 0x06}) {
+		t.Fatal("test archive has no zip64 end of central directory record")
+	}
+
+	ep, err := OpenFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Close()
+
+	if len(ep.Files) != 0xffff+2 {
+		t.Errorf("got %d files, want %d", len(ep.Files), 0xffff+2)
+	}
+	if ep.Zip.File[0].Name != "mimetype" {
+		t.Errorf("first entry = %q, want mimetype", ep.Zip.File[0].Name)
+	}
+	got, err := ep.ReadFile("OEBPS/last.txt")
+	if err != nil || string(got) != "last" {
+		t.Errorf("ReadFile(last.txt) = %q, %v", got, err)
+	}
+}
+
+func TestOpenZipFields(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	fw, _ := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	fw.Write([]byte("application/epub+zip"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "book.epub")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ep, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.Zip == nil || ep.ZipFile == nil || ep.Zip != &ep.ZipFile.Reader || len(ep.ZipFile.File) != 1 {
+		t.Errorf("Open should set both Zip and the deprecated ZipFile")
+	}
+	if err := ep.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+
+	ep, err = OpenFromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.Zip == nil || ep.ZipFile != nil {
+		t.Errorf("OpenFromBytes should set Zip only")
+	}
+}
+
+func TestReadFileTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	if _, err := w.CreateRaw(&zip.FileHeader{
+		Name:               "OEBPS/audio.mp3",
+		Method:             zip.Store,
+		UncompressedSize64: MaxFileSize + 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ep, err := OpenFromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ep.ReadFile("OEBPS/audio.mp3"); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("ReadFile error = %v, want ErrFileTooLarge", err)
+	}
+}
+
+func TestReadFileDecompressionLimit(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, size := range map[string]int{"mimetype": 20, "OEBPS/bomb.xhtml": 64 << 10} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(bytes.Repeat([]byte("a"), size))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ep, err := OpenFromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep.MaxDecompressedBytes = 1 << 10
+	if _, err := ep.ReadFile("mimetype"); err != nil {
+		t.Fatalf("ReadFile(mimetype) = %v before the cap was hit", err)
+	}
+	if _, err := ep.ReadFile("OEBPS/bomb.xhtml"); !errors.Is(err, ErrDecompressionLimit) {
+		t.Errorf("ReadFile error = %v, want ErrDecompressionLimit", err)
+	}
+	if !errors.Is(ep.DecompressionErr(), ErrDecompressionLimit) {
+		t.Errorf("DecompressionErr() = %v, want ErrDecompressionLimit", ep.DecompressionErr())
+	}
+	if _, err := ep.ReadFile("mimetype"); !errors.Is(err, ErrDecompressionLimit) {
+		t.Errorf("ReadFile after the cap was hit = %v, want ErrDecompressionLimit", err)
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"META-INF/container.xml": "<container/>",
+		"OEBPS/a.xhtml":          "a",
+		"mimetype":               "application/epub+zip",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ep, err := OpenDir(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Close()
+	if !ep.FromDir || ep.Path != dir {
+		t.Errorf("FromDir = %v, Path = %q", ep.FromDir, ep.Path)
+	}
+	if len(ep.Zip.File) != 3 || ep.Zip.File[0].Name != "mimetype" {
+		t.Fatalf("entries = %v, want 3 with mimetype first", ep.Zip.File)
+	}
+	if got, err := ep.ReadFile("OEBPS/a.xhtml"); err != nil || string(got) != "a" {
+		t.Errorf("ReadFile(OEBPS/a.xhtml) = %q, %v", got, err)
+	}
+
+	if _, err := OpenDir(filepath.Join(dir, "missing"), 0); err == nil {
+		t.Error("OpenDir of a missing directory should fail")
+	}
+}
+
+func TestOpenUnsafePath(t *testing.T) {
+	for _, name := range []string{"../../etc/evil", "/etc/evil", "OEBPS/../../evil", "..\\evil", "C:/evil", "d:\\evil", "C:"} {
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		for _, n := range []string{"mimetype", name} {
+			f, err := w.Create(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte("x"))
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenFromBytes(buf.Bytes()); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%s: OpenFromBytes error = %v, want ErrUnsafePath", name, err)
+		}
+	}
+
+	for _, name := range []string{"OEBPS/../mimetype2", "OEBPS/a..b.xhtml", "..hidden", "a:b.xhtml", "1:/x", "C:evil"} {
+		if unsafePath(name) {
+			t.Errorf("unsafePath(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestParseOPFMeta(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	cw, _ := w.Create("META-INF/container.xml")
+	cw.Write([]byte(`<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`))
+	ow, _ := w.Create("content.opf")
+	ow.Write([]byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:creator id="author">A. Writer</dc:creator>
+    <meta refines="#author" property="role">aut</meta>
+    <meta property="schema:accessMode">textual</meta>
+    <meta property="schema:accessMode">visual</meta>
+    <meta property="dcterms:modified">2025-01-01T00:00:00Z</meta>
+    <meta name="cover" content=" cover-img "/>
+  </metadata>
+  <manifest/>
+  <spine/>
+</package>`))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ep, err := OpenFromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ep.ParseContainer(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ep.ParseOPF(); err != nil {
+		t.Fatal(err)
+	}
+	meta := ep.Package.Metadata.Meta
+	if got := fmt.Sprint(meta["schema:accessMode"]); got != "[textual visual]" {
+		t.Errorf("schema:accessMode = %s, want [textual visual]", got)
+	}
+	if got := fmt.Sprint(meta["dcterms:modified"]); got != "[2025-01-01T00:00:00Z]" {
+		t.Errorf("dcterms:modified = %s", got)
+	}
+	if _, ok := meta["role"]; ok {
+		t.Error("refining meta should not be in Metadata.Meta")
+	}
+	if got := fmt.Sprint(ep.Package.Metadata.NamedMeta["cover"]); got != "[cover-img]" {
+		t.Errorf("NamedMeta[cover] = %s, want [cover-img]", got)
+	}
+}
@@ -1,12 +1,38 @@
 package epub
 
-import "archive/zip"
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"sync/atomic"
+)
 
 // EPUB represents a parsed EPUB file.
 type EPUB struct {
-	Path    string
+	Path  string
+	Zip   *zip.Reader          // the archive, however it was opened
+	Files map[string]*zip.File // path -> zip.File
+
+	// ZipFile is the archive as opened by Open, and Zip is &ZipFile.Reader.
+	// It is nil for OpenFromBytes, OpenFromReaderAt and OpenDir.
+	//
+	// Deprecated: use Zip, which every constructor sets.
 	ZipFile *zip.ReadCloser
-	Files   map[string]*zip.File // path -> zip.File
+
+	// Raw archive bytes, for checks that need to read zip headers directly
+	Raw io.ReaderAt
+
+	// FromDir is set by OpenDir: the archive was packed from a directory,
+	// so its zip structure says nothing about the book.
+	FromDir bool
+
+	// MaxDecompressedBytes caps how much ReadFile will decompress from a
+	// single entry, guarding against zip bombs. Zero means MaxFileSize.
+	MaxDecompressedBytes int64
+
+	closer           io.Closer
+	ctx              context.Context
+	decompressionErr atomic.Pointer[error]
 
 	// Parsed from container.xml
 	RootfilePath  string
@@ -27,6 +53,11 @@ type EPUB struct {
 type Rootfile struct {
 	FullPath  string
 	MediaType string
+
+	// Rendition holds the EPUB Multiple-Rendition selection attributes
+	// (rendition:media, rendition:layout, rendition:language,
+	// rendition:accessMode, rendition:label), keyed by local name.
+	Rendition map[string]string
 }
 
 // Package represents the OPF package document.
@@ -60,6 +91,24 @@ type Metadata struct {
 	Dates       []string
 	Sources     []string
 	Creators    []DCCreator
+
+	// Meta holds the values of <meta property="..."> elements that do not
+	// refine another element, keyed by property (e.g. "schema:accessMode").
+	Meta map[string][]string
+
+	// NamedMeta holds the content attribute of EPUB 2 style
+	// <meta name="..." content="..."> elements, keyed by name (e.g. "cover").
+	NamedMeta map[string][]string
+
+	// Links holds the <link> elements of the package metadata that do not
+	// refine another element, in document order.
+	Links []MetadataLink
+}
+
+// MetadataLink represents a <link> element in the package metadata.
+type MetadataLink struct {
+	Rel  string
+	Href string
 }
 
 // DCCreator represents a dc:creator element with optional opf:role.
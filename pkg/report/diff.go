@@ -0,0 +1,83 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReportDiff lists how the messages of one report changed in another.
+// Messages are matched by check ID, location and text, so a finding that
+// only moved to another line counts as unchanged. Repeated messages are
+// matched one for one.
+type ReportDiff struct {
+	Added     []Message // in after but not before
+	Removed   []Message // in before but not after
+	Unchanged []Message // in both, as they appear in after
+}
+
+// diffKey identifies a message for Diff.
+type diffKey struct {
+	checkID  string
+	location string
+	message  string
+}
+
+func keyOf(m Message) diffKey {
+	return diffKey{m.CheckID, m.Location, m.Message}
+}
+
+// Diff compares two reports, such as a doctor run's BeforeReport and
+// AfterReport or two builds of the same book. Each slice keeps the order
+// of the report it came from.
+func Diff(before, after *Report) *ReportDiff {
+	d := &ReportDiff{}
+
+	beforeMsgs := before.snapshot()
+	remaining := make(map[diffKey]int)
+	for _, m := range beforeMsgs {
+		remaining[keyOf(m)]++
+	}
+	for _, m := range after.snapshot() {
+		k := keyOf(m)
+		if remaining[k] > 0 {
+			remaining[k]--
+			d.Unchanged = append(d.Unchanged, m)
+		} else {
+			d.Added = append(d.Added, m)
+		}
+	}
+
+	// Whatever is left unmatched was removed; take the last occurrences so
+	// the earlier ones pair with the unchanged messages.
+	for i := len(beforeMsgs) - 1; i >= 0; i-- {
+		m := beforeMsgs[i]
+		k := keyOf(m)
+		if remaining[k] > 0 {
+			remaining[k]--
+			d.Removed = append(d.Removed, m)
+		}
+	}
+	for i, j := 0, len(d.Removed)-1; i < j; i, j = i+1, j-1 {
+		d.Removed[i], d.Removed[j] = d.Removed[j], d.Removed[i]
+	}
+	return d
+}
+
+// Empty reports whether nothing was added or removed.
+func (d *ReportDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// String returns the removed messages as "- " lines followed by the added
+// messages as "+ " lines, then a one-line summary.
+func (d *ReportDiff) String() string {
+	var b strings.Builder
+	for _, m := range d.Removed {
+		fmt.Fprintf(&b, "- %s\n", m)
+	}
+	for _, m := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", m)
+	}
+	fmt.Fprintf(&b, "%d added, %d removed, %d unchanged\n", len(d.Added), len(d.Removed), len(d.Unchanged))
+	return b.String()
+}
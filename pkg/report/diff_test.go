@@ -0,0 +1,43 @@
+package report
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	before := NewReport()
+	before.AddWithLocation(Error, "RSC-001", "missing file", "OEBPS/a.xhtml")
+	before.AddWithLocation(Warning, "HTM-002", "no title", "OEBPS/b.xhtml")
+	before.AddWithLocation(Warning, "HTM-002", "no title", "OEBPS/b.xhtml")
+	before.Add(Error, "OPF-004", "no dcterms:modified")
+
+	after := NewReport()
+	after.AddWithPosition(Warning, "HTM-002", "no title", "OEBPS/b.xhtml", 3, 1)
+	after.Add(Warning, "OPF-019", "bad modified date")
+
+	d := Diff(before, after)
+	if len(d.Added) != 1 || d.Added[0].CheckID != "OPF-019" {
+		t.Errorf("Added = %v, want OPF-019", d.Added)
+	}
+	if len(d.Unchanged) != 1 || d.Unchanged[0].Line != 3 {
+		t.Errorf("Unchanged = %v, want the HTM-002 from after", d.Unchanged)
+	}
+	if len(d.Removed) != 3 || d.Removed[0].CheckID != "RSC-001" || d.Removed[1].CheckID != "HTM-002" || d.Removed[2].CheckID != "OPF-004" {
+		t.Errorf("Removed = %v, want RSC-001, one HTM-002 and OPF-004 in order", d.Removed)
+	}
+	if d.Empty() {
+		t.Error("Empty() = true for a diff with changes")
+	}
+
+	want := `- ERROR(RSC-001): missing file [OEBPS/a.xhtml]
+- WARNING(HTM-002): no title [OEBPS/b.xhtml]
+- ERROR(OPF-004): no dcterms:modified
++ WARNING(OPF-019): bad modified date
+1 added, 3 removed, 1 unchanged
+`
+	if got := d.String(); got != want {
+		t.Errorf("String() =\n%s\nwant:\n%s", got, want)
+	}
+
+	if !Diff(after, after).Empty() {
+		t.Error("a report diffed with itself should be empty")
+	}
+}
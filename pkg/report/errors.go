@@ -0,0 +1,53 @@
+package report
+
+import "fmt"
+
+// ValidationError is returned by Report.Err when validation found FATAL
+// or ERROR messages. Use errors.As to reach it, or a single Message, from
+// an error chain.
+type ValidationError struct {
+	// Messages are the FATAL and ERROR messages, in report order.
+	Messages []Message
+}
+
+func (e *ValidationError) Error() string {
+	fatal, errs := 0, 0
+	for _, m := range e.Messages {
+		if m.Severity == Fatal {
+			fatal++
+		} else {
+			errs++
+		}
+	}
+	return fmt.Sprintf("epub validation failed (%d fatal, %d errors): %s", fatal, errs, e.Messages[0])
+}
+
+// Unwrap returns each message as an error, so errors.As can extract a
+// Message.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Messages))
+	for i, m := range e.Messages {
+		errs[i] = m
+	}
+	return errs
+}
+
+// Error lets a Message be used as an error; it returns String().
+func (m Message) Error() string {
+	return m.String()
+}
+
+// Err returns nil if the report is valid, and otherwise a *ValidationError
+// holding its FATAL and ERROR messages.
+func (r *Report) Err() error {
+	if r.IsValid() {
+		return nil
+	}
+	var msgs []Message
+	for _, m := range r.snapshot() {
+		if m.Severity == Fatal || m.Severity == Error {
+			msgs = append(msgs, m)
+		}
+	}
+	return &ValidationError{Messages: msgs}
+}
@@ -0,0 +1,36 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReportErr(t *testing.T) {
+	r := NewReport()
+	r.Add(Warning, "OPF-019", "bad modified date")
+	if err := r.Err(); err != nil {
+		t.Fatalf("report with only warnings: Err() = %v, want nil", err)
+	}
+
+	r.AddWithLocation(Error, "RSC-001", "missing file", "OEBPS/a.xhtml")
+	r.Add(Fatal, "OPF-011", "not well-formed")
+	err := fmt.Errorf("checking book: %w", r.Err())
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("errors.As(*ValidationError) failed for %v", err)
+	}
+	if len(verr.Messages) != 2 {
+		t.Errorf("ValidationError has %d messages, want 2: %v", len(verr.Messages), verr.Messages)
+	}
+	if !strings.Contains(err.Error(), "1 fatal, 1 errors") || !strings.Contains(err.Error(), "RSC-001") {
+		t.Errorf("unexpected error text %q", err.Error())
+	}
+
+	var m Message
+	if !errors.As(err, &m) || m.CheckID != "RSC-001" {
+		t.Errorf("errors.As(Message) = %v, want RSC-001", m)
+	}
+}
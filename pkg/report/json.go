@@ -8,20 +8,26 @@ import (
 // JSONOutput is the JSON structure written to output files.
 type JSONOutput struct {
 	Valid        bool      `json:"valid"`
+	Version      string    `json:"version,omitempty"`
+	Title        string    `json:"title,omitempty"`
 	Messages     []Message `json:"messages"`
 	FatalCount   int       `json:"fatal_count"`
 	ErrorCount   int       `json:"error_count"`
 	WarningCount int       `json:"warning_count"`
+	InfoCount    int       `json:"info_count"`
 }
 
 // WriteJSON writes the report in JSON format to w.
 func (r *Report) WriteJSON(w io.Writer) error {
 	out := JSONOutput{
 		Valid:        r.IsValid(),
-		Messages:     r.Messages,
+		Version:      r.Meta.Version,
+		Title:        r.Meta.Title,
+		Messages:     r.snapshot(),
 		FatalCount:   r.FatalCount(),
 		ErrorCount:   r.ErrorCount(),
 		WarningCount: r.WarningCount(),
+		InfoCount:    r.InfoCount(),
 	}
 	if out.Messages == nil {
 		out.Messages = []Message{}
@@ -30,3 +36,21 @@ func (r *Report) WriteJSON(w io.Writer) error {
 	enc.SetIndent("", "  ")
 	return enc.Encode(out)
 }
+
+// CheckIDsOutput is the compact JSON structure listing only the distinct
+// check IDs a book triggered.
+type CheckIDsOutput struct {
+	Valid    bool     `json:"valid"`
+	CheckIDs []string `json:"check_ids"`
+}
+
+// WriteCheckIDsJSON writes the sorted, distinct check IDs in JSON format to w.
+func (r *Report) WriteCheckIDsJSON(w io.Writer) error {
+	out := CheckIDsOutput{
+		Valid:    r.IsValid(),
+		CheckIDs: r.CheckIDs(),
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,93 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// JUnitTestSuite is the <testsuite> element written by WriteJUnitXML.
+type JUnitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is one check ID.
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Error     *JUnitProblem `xml:"error,omitempty"`
+	Failure   *JUnitProblem `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// JUnitProblem is a <failure> or <error> element.
+type JUnitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ToJUnit converts the report to a JUnit test suite with one test case per
+// distinct check ID. A check with FATAL messages records an <error>, one
+// with ERROR messages a <failure>; WARNING, INFO and USAGE messages go to
+// <system-out> and leave the test case passing.
+func (r *Report) ToJUnit(suiteName string) JUnitTestSuite {
+	suite := JUnitTestSuite{Name: suiteName}
+	msgs := r.snapshot()
+	for _, id := range r.CheckIDs() {
+		tc := JUnitTestCase{Name: id, ClassName: suiteName}
+		var failed, other []string
+		var firstFailed string
+		fatal := false
+		for _, m := range msgs {
+			if m.CheckID != id {
+				continue
+			}
+			if m.Severity == Fatal || m.Severity == Error {
+				if firstFailed == "" {
+					firstFailed = m.Message
+				}
+				fatal = fatal || m.Severity == Fatal
+				failed = append(failed, m.String())
+			} else {
+				other = append(other, m.String())
+			}
+		}
+		if len(failed) > 0 {
+			problem := &JUnitProblem{Message: firstFailed, Text: strings.Join(failed, "\n")}
+			if fatal {
+				problem.Type = string(Fatal)
+				tc.Error = problem
+				suite.Errors++
+			} else {
+				problem.Type = string(Error)
+				tc.Failure = problem
+				suite.Failures++
+			}
+		}
+		tc.SystemOut = strings.Join(other, "\n")
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suite.Tests = len(suite.TestCases)
+	return suite
+}
+
+// WriteJUnitXML writes the report as a JUnit XML <testsuite> named
+// suiteName to w, for CI systems that render JUnit results.
+func (r *Report) WriteJUnitXML(w io.Writer, suiteName string) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(r.ToJUnit(suiteName)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,45 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestWriteJUnitXML(t *testing.T) {
+	r := NewReport()
+	r.Add(Fatal, "OCF-006", "container.xml missing")
+	r.AddWithLocation(Error, "RSC-001", "missing file a", "OEBPS/a.xhtml")
+	r.AddWithLocation(Error, "RSC-001", "missing file b", "OEBPS/b.xhtml")
+	r.Add(Warning, "OPF-019", "bad modified date")
+
+	var buf bytes.Buffer
+	if err := r.WriteJUnitXML(&buf, "book.epub"); err != nil {
+		t.Fatal(err)
+	}
+	var suite JUnitTestSuite
+	if err := xml.Unmarshal(buf.Bytes(), &suite); err != nil {
+		t.Fatalf("invalid JUnit XML: %v\n%s", err, buf.String())
+	}
+
+	if suite.Name != "book.epub" || suite.Tests != 3 || suite.Failures != 1 || suite.Errors != 1 {
+		t.Errorf("suite = %q tests=%d failures=%d errors=%d, want book.epub 3/1/1",
+			suite.Name, suite.Tests, suite.Failures, suite.Errors)
+	}
+	for _, tc := range suite.TestCases {
+		switch tc.Name {
+		case "OCF-006":
+			if tc.Error == nil || tc.Failure != nil {
+				t.Errorf("OCF-006 should record an error: %+v", tc)
+			}
+		case "RSC-001":
+			if tc.Failure == nil || !bytes.Contains([]byte(tc.Failure.Text), []byte("OEBPS/b.xhtml")) {
+				t.Errorf("RSC-001 failure should list both messages: %+v", tc.Failure)
+			}
+		case "OPF-019":
+			if tc.Failure != nil || tc.Error != nil || tc.SystemOut == "" {
+				t.Errorf("OPF-019 warning should pass with system-out: %+v", tc)
+			}
+		}
+	}
+}
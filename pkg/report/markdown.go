@@ -0,0 +1,62 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MarkdownReport writes the report as GitHub-flavored Markdown for posting
+// as a pull request comment: a pass/fail heading, a line of counts, and a
+// table of messages with Severity, CheckID, File and Message columns.
+func (r *Report) MarkdownReport(w io.Writer) error {
+	msgs := r.snapshot()
+	var b strings.Builder
+	if r.IsValid() {
+		b.WriteString("### ✅ EPUB validation passed\n\n")
+	} else {
+		b.WriteString("### ❌ EPUB validation failed\n\n")
+	}
+	fmt.Fprintf(&b, "**%d fatal**, **%d errors**, %d warnings, %d messages in total\n",
+		r.FatalCount(), r.ErrorCount(), r.WarningCount(), len(msgs))
+
+	if len(msgs) > 0 {
+		b.WriteString("\n| Severity | CheckID | File | Message |\n")
+		b.WriteString("|---|---|---|---|\n")
+		for _, m := range msgs {
+			file := m.Location
+			if file != "" && m.Line > 0 {
+				file = fmt.Sprintf("%s:%d:%d", file, m.Line, m.Column)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n",
+				m.Severity, m.CheckID, markdownCode(file), markdownCell(m.Message))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell keeps s on one table row: newlines become spaces and pipes
+// are escaped so they don't end the cell.
+func markdownCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// markdownCode renders s as inline code, fenced with enough backticks to
+// hold any it contains. Empty strings stay empty.
+func markdownCode(s string) string {
+	s = markdownCell(s)
+	if s == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
@@ -0,0 +1,38 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownReport(t *testing.T) {
+	r := NewReport()
+	r.AddWithPosition(Error, "RSC-007", "Referenced resource\n'a|b.png' could not be found", "OEBPS/ch 1.xhtml", 4, 10)
+	r.Add(Warning, "OPF-082", "Identifier 'x' is listed twice")
+
+	var b strings.Builder
+	if err := r.MarkdownReport(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "### ❌ EPUB validation failed\n\n" +
+		"**0 fatal**, **1 errors**, 1 warnings, 2 messages in total\n\n" +
+		"| Severity | CheckID | File | Message |\n" +
+		"|---|---|---|---|\n" +
+		"| ERROR | RSC-007 | `OEBPS/ch 1.xhtml:4:10` | Referenced resource 'a\\|b.png' could not be found |\n" +
+		"| WARNING | OPF-082 |  | Identifier 'x' is listed twice |\n"
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	b.Reset()
+	if err := NewReport().MarkdownReport(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "### ✅ EPUB validation passed\n\n**0 fatal**, **0 errors**, 0 warnings, 0 messages in total\n" {
+		t.Errorf("valid report = %q", got)
+	}
+
+	if got := markdownCode("a`b"); got != "``a`b``" {
+		t.Errorf("markdownCode(a`b) = %q", got)
+	}
+}
@@ -1,6 +1,11 @@
 package report
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
 
 // Severity levels for validation messages.
 type Severity string
@@ -13,24 +18,79 @@ const (
 	Usage   Severity = "USAGE"
 )
 
+// severityRank orders severities from least to most serious.
+var severityRank = map[Severity]int{
+	Usage:   1,
+	Info:    2,
+	Warning: 3,
+	Error:   4,
+	Fatal:   5,
+}
+
+// AtLeast reports whether s is as serious as min. Every severity is at
+// least the empty severity.
+func (s Severity) AtLeast(min Severity) bool {
+	return severityRank[s] >= severityRank[min]
+}
+
 // Message represents a single validation finding.
 type Message struct {
 	Severity Severity `json:"severity"`
 	CheckID  string   `json:"check_id"`
 	Message  string   `json:"message"`
 	Location string   `json:"location,omitempty"`
+
+	// Line and Column give the 1-based position within Location of the
+	// markup that triggered the message, when the check knows it.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
 }
 
 func (m Message) String() string {
+	if m.Location != "" && m.Line > 0 {
+		return fmt.Sprintf("%s(%s): %s [%s:%d:%d]", m.Severity, m.CheckID, m.Message, m.Location, m.Line, m.Column)
+	}
 	if m.Location != "" {
 		return fmt.Sprintf("%s(%s): %s [%s]", m.Severity, m.CheckID, m.Message, m.Location)
 	}
 	return fmt.Sprintf("%s(%s): %s", m.Severity, m.CheckID, m.Message)
 }
 
+// Sink receives each message as soon as it is added to a report,
+// letting callers stream findings before validation finishes.
+type Sink func(Message)
+
+// Meta summarizes the publication a report describes. Fields are empty
+// when validation stopped before the package document was parsed.
+type Meta struct {
+	Version string `json:"version,omitempty"`
+	Title   string `json:"title,omitempty"`
+}
+
 // Report collects all messages from a validation run.
 type Report struct {
 	Messages []Message `json:"messages"`
+
+	// Meta is filled in by the validator once the package document is parsed.
+	Meta Meta `json:"-"`
+
+	// Sink, if set, is called with every message as it is added. It runs
+	// on the adding goroutine, so it must be safe for concurrent use if
+	// checks add concurrently.
+	Sink Sink `json:"-"`
+
+	// MinSeverity, if set, drops messages less serious than it instead of
+	// adding them. The zero value keeps everything.
+	MinSeverity Severity `json:"-"`
+
+	// EnabledChecks, if non-empty, keeps only messages with these check
+	// IDs. DisabledChecks drops messages with these check IDs.
+	EnabledChecks  []string `json:"-"`
+	DisabledChecks []string `json:"-"`
+
+	// mu guards Messages so checks may add, and callers count, while
+	// other goroutines are still adding.
+	mu sync.Mutex
 }
 
 // NewReport creates an empty report.
@@ -40,7 +100,7 @@ func NewReport() *Report {
 
 // Add appends a message to the report.
 func (r *Report) Add(sev Severity, checkID string, msg string) {
-	r.Messages = append(r.Messages, Message{
+	r.add(Message{
 		Severity: sev,
 		CheckID:  checkID,
 		Message:  msg,
@@ -49,7 +109,7 @@ func (r *Report) Add(sev Severity, checkID string, msg string) {
 
 // AddWithLocation appends a message with a location to the report.
 func (r *Report) AddWithLocation(sev Severity, checkID string, msg string, location string) {
-	r.Messages = append(r.Messages, Message{
+	r.add(Message{
 		Severity: sev,
 		CheckID:  checkID,
 		Message:  msg,
@@ -57,8 +117,71 @@ func (r *Report) AddWithLocation(sev Severity, checkID string, msg string, locat
 	})
 }
 
+// AddWithPosition appends a message with a location and a 1-based line and
+// column within it.
+func (r *Report) AddWithPosition(sev Severity, checkID string, msg string, location string, line, column int) {
+	r.add(Message{
+		Severity: sev,
+		CheckID:  checkID,
+		Message:  msg,
+		Location: location,
+		Line:     line,
+		Column:   column,
+	})
+}
+
+// AddMessage appends a fully built message, such as one with a position
+// from a custom check, applying the report's filters and Sink as Add does.
+func (r *Report) AddMessage(m Message) {
+	r.add(m)
+}
+
+func (r *Report) add(m Message) {
+	if !m.Severity.AtLeast(r.MinSeverity) {
+		return
+	}
+	if len(r.EnabledChecks) > 0 && !slices.Contains(r.EnabledChecks, m.CheckID) {
+		return
+	}
+	if slices.Contains(r.DisabledChecks, m.CheckID) {
+		return
+	}
+	r.mu.Lock()
+	r.Messages = append(r.Messages, m)
+	r.mu.Unlock()
+	if r.Sink != nil {
+		r.Sink(m)
+	}
+}
+
+// Sort orders the messages by severity (FATAL first), then location,
+// line, column and check ID, so identical inputs give identical output
+// whatever order the checks ran in. Messages that tie keep their order.
+func (r *Report) Sort() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.SliceStable(r.Messages, func(i, j int) bool {
+		a, b := r.Messages[i], r.Messages[j]
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] > severityRank[b.Severity]
+		}
+		if a.Location != b.Location {
+			return a.Location < b.Location
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.CheckID < b.CheckID
+	})
+}
+
 // FatalCount returns the number of FATAL messages.
 func (r *Report) FatalCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	n := 0
 	for _, m := range r.Messages {
 		if m.Severity == Fatal {
@@ -70,6 +193,8 @@ func (r *Report) FatalCount() int {
 
 // ErrorCount returns the number of ERROR messages.
 func (r *Report) ErrorCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	n := 0
 	for _, m := range r.Messages {
 		if m.Severity == Error {
@@ -81,6 +206,8 @@ func (r *Report) ErrorCount() int {
 
 // WarningCount returns the number of WARNING messages.
 func (r *Report) WarningCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	n := 0
 	for _, m := range r.Messages {
 		if m.Severity == Warning {
@@ -90,7 +217,71 @@ func (r *Report) WarningCount() int {
 	return n
 }
 
-// IsValid returns true if there are no FATAL or ERROR messages.
+// InfoCount returns the number of INFO messages.
+func (r *Report) InfoCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, m := range r.Messages {
+		if m.Severity == Info {
+			n++
+		}
+	}
+	return n
+}
+
+// IsValid returns true if there are no FATAL or ERROR messages. WARNING,
+// INFO and USAGE messages never make a report invalid.
 func (r *Report) IsValid() bool {
 	return r.FatalCount() == 0 && r.ErrorCount() == 0
 }
+
+// CheckIDs returns the distinct check IDs in the report, sorted.
+func (r *Report) CheckIDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := make(map[string]bool)
+	ids := []string{}
+	for _, m := range r.Messages {
+		if !seen[m.CheckID] {
+			seen[m.CheckID] = true
+			ids = append(ids, m.CheckID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// MessagesForFile returns the messages whose location is the given path
+// within the container, in the order they were reported.
+func (r *Report) MessagesForFile(path string) []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var msgs []Message
+	for _, m := range r.Messages {
+		if m.Location == path {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// MessagesByFile groups messages by location. Messages that apply to the
+// publication as a whole are keyed by the empty string.
+func (r *Report) MessagesByFile() map[string][]Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byFile := make(map[string][]Message)
+	for _, m := range r.Messages {
+		byFile[m.Location] = append(byFile[m.Location], m)
+	}
+	return byFile
+}
+
+// snapshot returns a copy of the messages taken under the lock, so writers
+// can iterate while checks are still adding.
+func (r *Report) snapshot() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.Messages...)
+}
@@ -0,0 +1,194 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckIDs(t *testing.T) {
+	r := NewReport()
+	if ids := r.CheckIDs(); len(ids) != 0 {
+		t.Errorf("empty report CheckIDs = %v, want none", ids)
+	}
+
+	r.Add(Warning, "OPF-024", "a")
+	r.Add(Error, "CSS-006", "b")
+	r.AddWithLocation(Error, "OPF-024", "c", "OEBPS/content.opf")
+	r.Add(Usage, "ACC-001", "d")
+
+	want := []string{"ACC-001", "CSS-006", "OPF-024"}
+	if got := r.CheckIDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckIDs = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteCheckIDsJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var out CheckIDsOutput
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Valid || !reflect.DeepEqual(out.CheckIDs, want) {
+		t.Errorf("WriteCheckIDsJSON = %+v, want invalid with %v", out, want)
+	}
+}
+
+func TestMessagesForFile(t *testing.T) {
+	r := NewReport()
+	r.Add(Error, "OPF-004", "missing modified")
+	r.AddWithLocation(Error, "RSC-001", "a", "OEBPS/ch3.xhtml")
+	r.AddWithLocation(Warning, "HTM-009", "b", "OEBPS/ch1.xhtml")
+	r.AddWithLocation(Error, "RSC-003", "c", "OEBPS/ch3.xhtml")
+
+	got := r.MessagesForFile("OEBPS/ch3.xhtml")
+	if len(got) != 2 || got[0].CheckID != "RSC-001" || got[1].CheckID != "RSC-003" {
+		t.Errorf("MessagesForFile(ch3) = %v", got)
+	}
+	if got := r.MessagesForFile("OEBPS/ch2.xhtml"); len(got) != 0 {
+		t.Errorf("MessagesForFile(ch2) = %v, want none", got)
+	}
+
+	byFile := r.MessagesByFile()
+	if len(byFile) != 3 || len(byFile["OEBPS/ch3.xhtml"]) != 2 || len(byFile[""]) != 1 {
+		t.Errorf("MessagesByFile = %v", byFile)
+	}
+}
+
+func TestMessagePosition(t *testing.T) {
+	r := NewReport()
+	r.AddWithLocation(Error, "RSC-001", "a", "OEBPS/ch1.xhtml")
+	r.AddWithPosition(Error, "HTM-010", "b", "OEBPS/ch1.xhtml", 2, 1)
+
+	if got, want := r.Messages[1].String(), "ERROR(HTM-010): b [OEBPS/ch1.xhtml:2:1]"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Messages []map[string]any `json:"messages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := out.Messages[0]["line"]; ok {
+		t.Errorf("message without a position should omit line: %v", out.Messages[0])
+	}
+	if out.Messages[1]["line"] != float64(2) || out.Messages[1]["column"] != float64(1) {
+		t.Errorf("message position = %v", out.Messages[1])
+	}
+}
+
+func TestMinSeverity(t *testing.T) {
+	var streamed int
+	r := &Report{MinSeverity: Error, Sink: func(Message) { streamed++ }}
+	r.Add(Fatal, "OCF-006", "a")
+	r.Add(Error, "RSC-001", "b")
+	r.Add(Warning, "OPF-019", "c")
+	r.Add(Info, "OPF-081", "d")
+	r.Add(Usage, "ACC-001", "e")
+
+	if len(r.Messages) != 2 || streamed != 2 {
+		t.Fatalf("kept %d messages and streamed %d, want 2 and 2: %v", len(r.Messages), streamed, r.Messages)
+	}
+	if r.WarningCount() != 0 || r.ErrorCount() != 1 || r.FatalCount() != 1 {
+		t.Errorf("counts = %d fatal, %d error, %d warning", r.FatalCount(), r.ErrorCount(), r.WarningCount())
+	}
+
+	all := NewReport()
+	all.Add(Usage, "ACC-001", "e")
+	if len(all.Messages) != 1 {
+		t.Error("zero MinSeverity should keep every message")
+	}
+}
+
+func TestInfoDoesNotAffectValidity(t *testing.T) {
+	r := NewReport()
+	r.Add(Info, "FXL-001", "fixed layout")
+	r.Add(Info, "OPF-081", "extra whitespace")
+	r.Add(Usage, "ACC-001", "usage")
+	if !r.IsValid() {
+		t.Error("a report with only INFO and USAGE messages should be valid")
+	}
+	if r.InfoCount() != 2 || r.WarningCount() != 0 {
+		t.Errorf("InfoCount() = %d, WarningCount() = %d, want 2 and 0", r.InfoCount(), r.WarningCount())
+	}
+
+	var b strings.Builder
+	if err := r.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"info_count": 2`) {
+		t.Errorf("JSON output should include info_count:\n%s", b.String())
+	}
+}
+
+func TestCheckFiltering(t *testing.T) {
+	r := &Report{DisabledChecks: []string{"RSC-006"}}
+	r.Add(Error, "RSC-006", "a")
+	r.Add(Error, "RSC-001", "b")
+	if got := r.CheckIDs(); !reflect.DeepEqual(got, []string{"RSC-001"}) {
+		t.Errorf("with RSC-006 disabled, CheckIDs = %v", got)
+	}
+
+	r = &Report{EnabledChecks: []string{"RSC-001", "RSC-006"}, DisabledChecks: []string{"RSC-006"}}
+	r.Add(Error, "RSC-006", "a")
+	r.Add(Error, "RSC-001", "b")
+	r.Add(Warning, "OPF-019", "c")
+	if got := r.CheckIDs(); !reflect.DeepEqual(got, []string{"RSC-001"}) {
+		t.Errorf("with allowlist and denylist, CheckIDs = %v", got)
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	r := NewReport()
+	var sunk atomic.Int64
+	r.Sink = func(Message) { sunk.Add(1) }
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.AddMessage(Message{Severity: Warning, CheckID: "HTM-002", Message: "no title", Location: "a.xhtml", Line: 1, Column: 1})
+				r.WarningCount()
+				r.Err()
+				r.WriteText(io.Discard)
+				Diff(r, r)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(r.Messages) != 800 || sunk.Load() != 800 {
+		t.Errorf("got %d messages and %d sink calls, want 800", len(r.Messages), sunk.Load())
+	}
+}
+
+func TestSort(t *testing.T) {
+	r := NewReport()
+	r.AddWithPosition(Warning, "HTM-002", "b", "OEBPS/b.xhtml", 9, 1)
+	r.AddWithLocation(Error, "RSC-001", "a", "OEBPS/b.xhtml")
+	r.AddWithPosition(Warning, "HTM-001", "c", "OEBPS/b.xhtml", 2, 5)
+	r.Add(Warning, "OPF-019", "d")
+	r.Add(Fatal, "OPF-011", "e")
+	r.AddWithLocation(Warning, "CSS-001", "f", "OEBPS/a.css")
+	r.Sort()
+
+	var got []string
+	for _, m := range r.Messages {
+		got = append(got, m.CheckID)
+	}
+	want := []string{"OPF-011", "RSC-001", "OPF-019", "CSS-001", "HTM-001", "HTM-002"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted order = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package report
+
+import "sort"
+
+// Remediation is an actionable next step for one check that fired in a report.
+type Remediation struct {
+	CheckID       string `json:"check_id"`
+	Count         int    `json:"count"`
+	Suggestion    string `json:"suggestion"`
+	DoctorFixable bool   `json:"doctor_fixable"`
+}
+
+type remediationInfo struct {
+	suggestion    string
+	doctorFixable bool
+}
+
+// remediations maps check IDs to a suggested fix and whether doctor mode
+// repairs them automatically. Keep it in step with the checks in
+// pkg/validate and the fix list in pkg/doctor.
+var remediations = map[string]remediationInfo{
+	// Container
+	"OCF-001": {"Add a 'mimetype' file containing 'application/epub+zip'.", true},
+	"OCF-002": {"Rezip the publication with 'mimetype' as the first entry.", true},
+	"OCF-003": {"Set the mimetype file content to exactly 'application/epub+zip'.", true},
+	"OCF-004": {"Rezip without extra fields on the mimetype entry (e.g. zip -X).", true},
+	"OCF-005": {"Store the mimetype entry uncompressed (zip -0).", true},
+	"OCF-006": {"Add META-INF/container.xml pointing at the package document.", false},
+	"OCF-009": {"Fix the rootfile full-path in container.xml to name the package document.", false},
+	"OCF-018": {"Shrink the largest entries listed (e.g. recompress images) to fit the size limit.", false},
+	"OCF-030": {"Rebuild the archive with entry paths relative to the container root.", false},
+	"OCF-031": {"Check the entry named; if it is genuine, raise MaxDecompressedBytes, otherwise rebuild the archive without it.", false},
+	"XML-002": {"Remove anything before the <?xml declaration.", true},
+
+	// Package document
+	"OPF-001": {"Add a dc:title element to the package metadata.", true},
+	"OPF-002": {"Add a dc:identifier element and reference it from unique-identifier.", true},
+	"OPF-003": {"Add a dc:language element with a BCP 47 tag such as 'en'.", true},
+	"OPF-004": {"Add <meta property=\"dcterms:modified\"> with a CCYY-MM-DDThh:mm:ssZ timestamp.", true},
+	"OPF-009": {"Point the spine itemref at an existing manifest item id, or remove it.", false},
+	"OPF-011": {"Fix the XML syntax of the package document.", false},
+	"OPF-017": {"Remove the duplicate spine itemref.", true},
+	"OPF-019": {"Use the CCYY-MM-DDThh:mm:ssZ format for dcterms:modified.", false},
+	"OPF-020": {"Use a well-formed BCP 47 language tag such as 'en' or 'en-US'.", true},
+	"OPF-022": {"Break the fallback cycle so each chain ends in a core media type.", false},
+	"OPF-024": {"Set the manifest media-type to match the file's actual format.", true},
+	"OPF-028": {"Keep a single dcterms:modified element.", true},
+	"OPF-033": {"Remove the fragment identifier from the manifest href.", true},
+	"OPF-036": {"Use W3CDTF dates (CCYY, CCYY-MM or CCYY-MM-DD) for dc:date.", true},
+	"OPF-038": {"Set the spine itemref linear attribute to 'yes' or 'no'.", true},
+	"OPF-039": {"Remove the EPUB 2 <guide> element and use landmarks in the nav document.", true},
+	"OPF-074": {"Replace the misspelled media-type with the standard one.", true},
+	"OPF-076": {"Write the language tag in canonical case (e.g. 'en-US').", true},
+	"OPF-060": {"Put the cover-image property (and <meta name=\"cover\"> for EPUB 2) on exactly one JPEG or PNG manifest item.", true},
+	"OPF-081": {"List each property once, separated by single spaces.", true},
+	"OPF-082": {"Remove the repeated dc:identifier element, keeping the one unique-identifier points to.", false},
+	"OPF-083": {"Rename the file to the extension of its real format and update references.", false},
+	"MED-001": {"Set the manifest media-type to match the image's actual format.", true},
+	"MED-015": {"Add <meta property=\"media:duration\" refines=\"#overlay-id\"> for each media overlay.", false},
+	"MED-016": {"Set the total media:duration to the sum of the media overlay durations.", false},
+	"MED-014": {"Set the audio clip's clipEnd to a time after its clipBegin.", false},
+
+	// Resources
+	"RSC-001": {"Add the missing file to the container or fix the reference.", false},
+	"RSC-002": {"Declare the file in the manifest, or remove it from the container.", true},
+	"RSC-003": {"Add the missing id to the target document or fix the fragment.", false},
+	"RSC-017": {"Link to the resource from the content, or remove it from the package.", false},
+	"RSC-020": {"Package the remote resource in the EPUB and refer to it by a relative path.", false},
+
+	// Content documents
+	"HTM-001": {"Fix the XHTML so it is well-formed XML.", false},
+	"HTM-002": {"Add a <title> element to the document head.", true},
+	"HTM-003": {"Remove the empty href or point it at a real target.", true},
+	"HTM-004": {"Replace obsolete elements with CSS-styled modern markup.", true},
+	"HTM-005": {"Add the 'scripted' property to the manifest item.", true},
+	"HTM-006": {"Add the 'svg' property to the manifest item.", true},
+	"HTM-007": {"Add the 'mathml' property to the manifest item.", true},
+	"HTM-009": {"Remove the <base> element and use relative links.", true},
+	"HTM-010": {"Use the HTML5 doctype <!DOCTYPE html>.", true},
+	"HTM-011": {"Use the HTML5 doctype <!DOCTYPE html>.", true},
+	"HTM-017": {"Replace named HTML entities with the characters or numeric references.", false},
+	"HTM-020": {"Remove the processing instruction.", true},
+	"HTM-026": {"Give lang and xml:lang the same value.", true},
+	"HTM-040": {"Move the data: URI content into its own file in the package and reference that file.", false},
+	"HTM-041": {"Remove the 'scripted' property from the manifest item.", false},
+
+	// Navigation
+	"NAV-001": {"Add a navigation document with a toc nav and declare it with properties=\"nav\".", true},
+	"NAV-003": {"Fix the table of contents link to point at an existing document.", false},
+	"NAV-022": {"Reorder the table of contents entries, or the spine itemrefs, so they match.", false},
+
+	// Styles and encoding
+	"CSS-005": {"Inline the imported stylesheet or link it from the document.", true},
+	"CSS-020": {"Drop fixed positioning and off-screen text-indent; reflowable reading systems paginate content themselves.", false},
+	"ENC-001": {"Save the document as UTF-8 and update its encoding declaration.", true},
+	"ENC-002": {"Save the document as UTF-8 instead of UTF-16.", true},
+	"ENC-010": {"Make the XML declaration's encoding match how the file is saved, or save it as UTF-8.", true},
+	"ENC-011": {"Remove the UTF-8 byte order mark from the start of the file.", true},
+
+	// Fixed layout
+	"FXL-009": {"Declare <meta property=\"rendition:layout\">pre-paginated</meta> in the package metadata.", true},
+	"FXL-010": {"Add a viewBox=\"0 0 width height\" to the root svg element of the fixed-layout page.", false},
+	"FXL-020": {"Keep one page-spread, layout, orientation, spread or flow value per spine itemref.", false},
+
+	// Fonts
+	"FONT-004": {"Use the font/* media type (font/otf, font/ttf, font/woff).", true},
+
+	// Accessibility
+	"ACC-002": {"Add an alt attribute to the image (alt=\"\" if decorative).", false},
+	"ACC-003": {"Declare the document language with lang and xml:lang on <html>.", true},
+	"ACC-017": {"Give the svg a <title> child, or role=\"img\" and aria-label (role=\"presentation\" if decorative).", false},
+	"ACC-030": {"Use the next heading level down (h2 after h1) and style it with CSS.", false},
+
+	// EPUB 2
+	"E2-016": {"Number the NCX playOrder values 1, 2, 3... in reading order, one per target.", false},
+	"E2-017": {"Set the NCX docTitle text to the book's dc:title.", false},
+	"E2-018": {"Add the NCX target to the manifest, or point the navPoint at a manifest item.", false},
+}
+
+// Remediation returns one entry per check ID in the report, sorted by check
+// ID, with a suggested fix and whether doctor mode can repair it. Checks
+// without a specific suggestion get a generic one.
+func (r *Report) Remediation() []Remediation {
+	counts := make(map[string]int)
+	for _, m := range r.snapshot() {
+		counts[m.CheckID]++
+	}
+
+	out := []Remediation{}
+	for id, n := range counts {
+		info, ok := remediations[id]
+		if !ok {
+			info.suggestion = "Review the reported messages and correct the content by hand."
+		}
+		out = append(out, Remediation{
+			CheckID:       id,
+			Count:         n,
+			Suggestion:    info.suggestion,
+			DoctorFixable: info.doctorFixable,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CheckID < out[j].CheckID })
+	return out
+}
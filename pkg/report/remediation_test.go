@@ -0,0 +1,34 @@
+package report
+
+import "testing"
+
+func TestRemediation(t *testing.T) {
+	r := NewReport()
+	r.Add(Error, "OPF-004", "missing modified")
+	r.Add(Error, "RSC-001", "missing file a")
+	r.Add(Error, "RSC-001", "missing file b")
+	r.Add(Warning, "ZZZ-999", "unknown check")
+
+	got := r.Remediation()
+	if len(got) != 3 {
+		t.Fatalf("got %d remediations, want 3: %+v", len(got), got)
+	}
+
+	want := []struct {
+		id      string
+		count   int
+		fixable bool
+	}{
+		{"OPF-004", 1, true},
+		{"RSC-001", 2, false},
+		{"ZZZ-999", 1, false},
+	}
+	for i, w := range want {
+		if got[i].CheckID != w.id || got[i].Count != w.count || got[i].DoctorFixable != w.fixable {
+			t.Errorf("remediation %d = %+v, want %s count %d fixable %v", i, got[i], w.id, w.count, w.fixable)
+		}
+		if got[i].Suggestion == "" {
+			t.Errorf("remediation for %s has no suggestion", got[i].CheckID)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SARIF 2.1.0 structures, limited to the parts code-scanning tools
+// such as GitHub read: one run, its rules and its results.
+
+// SARIFLog is the top-level SARIF document.
+type SARIFLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+// SARIFRun is a single invocation of the validator.
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+// SARIFTool describes the tool that produced a run.
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+// SARIFDriver names the tool and lists the rules its results refer to.
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+// SARIFRule describes one check ID.
+type SARIFRule struct {
+	ID               string    `json:"id"`
+	ShortDescription SARIFText `json:"shortDescription"`
+	Help             SARIFText `json:"help"`
+}
+
+// SARIFText is a SARIF message object.
+type SARIFText struct {
+	Text string `json:"text"`
+}
+
+// SARIFResult is one finding.
+type SARIFResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   SARIFText       `json:"message"`
+	Locations []SARIFLocation `json:"locations,omitempty"`
+}
+
+// SARIFLocation points a result at a file.
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+// SARIFPhysicalLocation holds the artifact a result applies to and, when
+// known, the region within it.
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           *SARIFRegion          `json:"region,omitempty"`
+}
+
+// SARIFRegion is a 1-based line and column within an artifact.
+type SARIFRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// SARIFArtifactLocation is the path of a file within the EPUB container.
+type SARIFArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// ToSARIF converts the report to a SARIF 2.1.0 log. Check IDs become rule
+// ids; FATAL and ERROR map to level "error", WARNING to "warning", and
+// INFO and USAGE to "note".
+func (r *Report) ToSARIF() SARIFLog {
+	rules := []SARIFRule{}
+	for _, id := range r.CheckIDs() {
+		help := "Review the reported messages and correct the content by hand."
+		short := "epubverify check " + id
+		if info, ok := remediations[id]; ok {
+			help = info.suggestion
+			short = info.suggestion
+		}
+		rules = append(rules, SARIFRule{
+			ID:               id,
+			ShortDescription: SARIFText{Text: short},
+			Help:             SARIFText{Text: help},
+		})
+	}
+	ruleIndex := make(map[string]int)
+	for i, rule := range rules {
+		ruleIndex[rule.ID] = i
+	}
+
+	results := []SARIFResult{}
+	for _, m := range r.snapshot() {
+		res := SARIFResult{
+			RuleID:    m.CheckID,
+			RuleIndex: ruleIndex[m.CheckID],
+			Level:     sarifLevel(m.Severity),
+			Message:   SARIFText{Text: m.Message},
+		}
+		if m.Location != "" {
+			res.Locations = []SARIFLocation{{
+				PhysicalLocation: SARIFPhysicalLocation{
+					ArtifactLocation: SARIFArtifactLocation{URI: m.Location},
+				},
+			}}
+			if m.Line > 0 {
+				res.Locations[0].PhysicalLocation.Region = &SARIFRegion{StartLine: m.Line, StartColumn: m.Column}
+			}
+		}
+		results = append(results, res)
+	}
+
+	return SARIFLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []SARIFRun{{
+			Tool: SARIFTool{Driver: SARIFDriver{
+				Name:           "epubverify",
+				InformationURI: "https://github.com/adammathes/epubverify",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+// WriteSARIF writes the report as a SARIF 2.1.0 log to w.
+func (r *Report) WriteSARIF(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.ToSARIF())
+}
+
+func sarifLevel(sev Severity) string {
+	switch sev {
+	case Fatal, Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
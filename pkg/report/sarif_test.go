@@ -0,0 +1,52 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	r := NewReport()
+	r.AddWithLocation(Error, "RSC-001", "missing file", "OEBPS/chapter1.xhtml")
+	r.Add(Warning, "OPF-019", "bad modified date")
+	r.Add(Info, "OPF-081", "extra whitespace")
+
+	var buf bytes.Buffer
+	if err := r.WriteSARIF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var log SARIFLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log header: %+v", log)
+	}
+
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(run.Tool.Driver.Rules))
+	}
+	for _, rule := range run.Tool.Driver.Rules {
+		if rule.ShortDescription.Text == "" {
+			t.Errorf("rule %s has no description", rule.ID)
+		}
+	}
+
+	wantLevels := []string{"error", "warning", "note"}
+	for i, res := range run.Results {
+		if res.Level != wantLevels[i] {
+			t.Errorf("result %d level = %q, want %q", i, res.Level, wantLevels[i])
+		}
+		if run.Tool.Driver.Rules[res.RuleIndex].ID != res.RuleID {
+			t.Errorf("result %d ruleIndex %d does not point at %s", i, res.RuleIndex, res.RuleID)
+		}
+	}
+	if got := run.Results[0].Locations; len(got) != 1 || got[0].PhysicalLocation.ArtifactLocation.URI != "OEBPS/chapter1.xhtml" {
+		t.Errorf("result 0 locations = %+v", got)
+	}
+	if len(run.Results[1].Locations) != 0 {
+		t.Errorf("publication-wide result should have no locations: %+v", run.Results[1].Locations)
+	}
+}
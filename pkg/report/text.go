@@ -7,7 +7,7 @@ import (
 
 // WriteText writes human-readable validation output to w.
 func (r *Report) WriteText(w io.Writer) {
-	for _, m := range r.Messages {
+	for _, m := range r.snapshot() {
 		fmt.Fprintln(w, m.String())
 	}
 	if r.IsValid() {
@@ -17,3 +17,108 @@ func (r *Report) WriteText(w io.Writer) {
 			r.ErrorCount(), r.WarningCount(), r.FatalCount())
 	}
 }
+
+// TextGrouping selects how TextReport groups messages.
+type TextGrouping int
+
+const (
+	// GroupByFile lists messages under the file they were reported in.
+	// Messages without a location come first, under "(publication)".
+	GroupByFile TextGrouping = iota
+	// GroupByCheck lists messages under their check ID with a count, so
+	// repeated findings are easy to spot.
+	GroupByCheck
+)
+
+// TextOptions controls TextReport.
+type TextOptions struct {
+	GroupBy TextGrouping
+	// NoColor disables the ANSI colors on severity prefixes, for output
+	// that is not a terminal.
+	NoColor bool
+}
+
+// severityColor is the ANSI color for each severity prefix.
+var severityColor = map[Severity]string{
+	Fatal:   "\033[1;31m",
+	Error:   "\033[31m",
+	Warning: "\033[33m",
+	Info:    "\033[2;36m", // muted: context, not a problem
+	Usage:   "\033[2m",
+}
+
+// TextReport writes the messages grouped by file or by check ID, each
+// group in order of its first message, followed by a summary line of
+// counts per severity.
+func (r *Report) TextReport(w io.Writer, opts TextOptions) {
+	prefix := func(s Severity) string {
+		if opts.NoColor {
+			return string(s)
+		}
+		return severityColor[s] + string(s) + "\033[0m"
+	}
+
+	var keys []string
+	groups := make(map[string][]Message)
+	for _, m := range r.snapshot() {
+		k := m.CheckID
+		if opts.GroupBy == GroupByFile {
+			k = m.Location
+		}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], m)
+	}
+	if opts.GroupBy == GroupByFile {
+		// The unlocated group goes first, whenever it was first seen.
+		for i, k := range keys {
+			if k == "" {
+				copy(keys[1:i+1], keys[:i])
+				keys[0] = ""
+				break
+			}
+		}
+	}
+
+	for i, k := range keys {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		msgs := groups[k]
+		if opts.GroupBy == GroupByFile {
+			if k == "" {
+				k = "(publication)"
+			}
+			fmt.Fprintln(w, k)
+		} else {
+			fmt.Fprintf(w, "%s (%d)\n", k, len(msgs))
+		}
+		for _, m := range msgs {
+			var where string
+			switch {
+			case opts.GroupBy == GroupByFile && m.Line > 0:
+				where = fmt.Sprintf(" [%d:%d]", m.Line, m.Column)
+			case opts.GroupBy == GroupByCheck && m.Location != "" && m.Line > 0:
+				where = fmt.Sprintf(" [%s:%d:%d]", m.Location, m.Line, m.Column)
+			case opts.GroupBy == GroupByCheck && m.Location != "":
+				where = fmt.Sprintf(" [%s]", m.Location)
+			}
+			if opts.GroupBy == GroupByFile {
+				fmt.Fprintf(w, "  %s(%s): %s%s\n", prefix(m.Severity), m.CheckID, m.Message, where)
+			} else {
+				fmt.Fprintf(w, "  %s: %s%s\n", prefix(m.Severity), m.Message, where)
+			}
+		}
+	}
+
+	counts := make(map[Severity]int)
+	for _, m := range r.snapshot() {
+		counts[m.Severity]++
+	}
+	if len(keys) > 0 {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d fatal, %d errors, %d warnings, %d info, %d usage\n",
+		counts[Fatal], counts[Error], counts[Warning], counts[Info], counts[Usage])
+}
@@ -0,0 +1,62 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextReport(t *testing.T) {
+	r := NewReport()
+	r.AddWithPosition(Error, "RSC-007", "missing image", "OEBPS/a.xhtml", 4, 10)
+	r.Add(Error, "OPF-004", "no dcterms:modified")
+	r.AddWithLocation(Warning, "CSS-008", "unused font", "OEBPS/style.css")
+	r.AddWithLocation(Error, "RSC-007", "missing link", "OEBPS/a.xhtml")
+
+	var b strings.Builder
+	r.TextReport(&b, TextOptions{NoColor: true})
+	want := `(publication)
+  ERROR(OPF-004): no dcterms:modified
+
+OEBPS/a.xhtml
+  ERROR(RSC-007): missing image [4:10]
+  ERROR(RSC-007): missing link
+
+OEBPS/style.css
+  WARNING(CSS-008): unused font
+
+0 fatal, 3 errors, 1 warnings, 0 info, 0 usage
+`
+	if got := b.String(); got != want {
+		t.Errorf("by file:\n%s\nwant:\n%s", got, want)
+	}
+
+	b.Reset()
+	r.TextReport(&b, TextOptions{GroupBy: GroupByCheck, NoColor: true})
+	want = `RSC-007 (2)
+  ERROR: missing image [OEBPS/a.xhtml:4:10]
+  ERROR: missing link [OEBPS/a.xhtml]
+
+OPF-004 (1)
+  ERROR: no dcterms:modified
+
+CSS-008 (1)
+  WARNING: unused font [OEBPS/style.css]
+
+0 fatal, 3 errors, 1 warnings, 0 info, 0 usage
+`
+	if got := b.String(); got != want {
+		t.Errorf("by check:\n%s\nwant:\n%s", got, want)
+	}
+
+	b.Reset()
+	r.TextReport(&b, TextOptions{})
+	if !strings.Contains(b.String(), "\033[31mERROR\033[0m(OPF-004)") {
+		t.Errorf("colored output should wrap the severity in ANSI codes:\n%s", b.String())
+	}
+
+	b.Reset()
+	NewReport().TextReport(&b, TextOptions{})
+	if got := b.String(); got != "0 fatal, 0 errors, 0 warnings, 0 info, 0 usage\n" {
+		t.Errorf("empty report = %q", got)
+	}
+}
@@ -4,24 +4,21 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"net/url"
+	"path"
 	"strings"
 
 	"github.com/adammathes/epubverify/pkg/epub"
 	"github.com/adammathes/epubverify/pkg/report"
 )
 
-// checkAccessibility runs accessibility checks (ACC-001 through ACC-010).
+// checkAccessibility runs accessibility checks (ACC-001 through ACC-017,
+// ACC-030, and NAV-021).
 func checkAccessibility(ep *epub.EPUB, r *report.Report) {
 	if ep.Package == nil || ep.Package.Version < "3.0" {
 		return
 	}
 
-	// Collect all meta properties for accessibility metadata checks
-	metaProps := make(map[string]bool)
-	for _, mr := range ep.Package.MetaRefines {
-		metaProps[mr.Property] = true
-	}
-	// Also check non-refines metas from the raw OPF scan
 	// The accessibility metadata uses schema.org properties
 	a11yMeta := collectAccessibilityMeta(ep)
 
@@ -31,9 +28,14 @@ func checkAccessibility(ep *epub.EPUB, r *report.Report) {
 			"EPUB publication should include accessibility metadata (schema.org properties)")
 	}
 
+	// Distributors such as school platforms require accessMode,
+	// accessibilitySummary, accessibilityFeature and accessibilityHazard,
+	// so a missing one is a warning; accessModeSufficient is only
+	// recommended.
+
 	// ACC-005: schema:accessMode
 	if !a11yMeta.hasAccessMode {
-		r.Add(report.Usage, "ACC-005",
+		r.Add(report.Warning, "ACC-005",
 			"EPUB should declare schema:accessMode metadata")
 	}
 
@@ -45,25 +47,28 @@ func checkAccessibility(ep *epub.EPUB, r *report.Report) {
 
 	// ACC-007: schema:accessibilitySummary
 	if !a11yMeta.hasAccessibilitySummary {
-		r.Add(report.Usage, "ACC-007",
+		r.Add(report.Warning, "ACC-007",
 			"EPUB should declare schema:accessibilitySummary metadata")
 	}
 
 	// ACC-008: schema:accessibilityFeature
 	if !a11yMeta.hasAccessibilityFeature {
-		r.Add(report.Usage, "ACC-008",
+		r.Add(report.Warning, "ACC-008",
 			"EPUB should declare schema:accessibilityFeature metadata")
 	}
 
 	// ACC-009: schema:accessibilityHazard
 	if !a11yMeta.hasAccessibilityHazard {
-		r.Add(report.Usage, "ACC-009",
+		r.Add(report.Warning, "ACC-009",
 			"EPUB should declare schema:accessibilityHazard metadata")
 	}
 
 	// ACC-002: img elements should have alt text
 	checkImgAltText(ep, r)
 
+	// ACC-017: inline svg images should have a text alternative
+	checkSVGTextAlternative(ep, r)
+
 	// ACC-003: html element should declare language
 	checkHTMLLangPresent(ep, r)
 
@@ -72,6 +77,18 @@ func checkAccessibility(ep *epub.EPUB, r *report.Report) {
 
 	// ACC-010: landmarks navigation should be present
 	checkLandmarksNavPresent(ep, r)
+
+	// ACC-015: aria-labelledby/aria-describedby/headers/for must reference existing ids
+	checkIDRefsResolve(ep, r)
+
+	// ACC-030: heading levels should not skip (h1 followed by h3)
+	checkHeadingLevels(ep, r)
+
+	// ACC-016: declared accessibility features and conformance must be backed by the content
+	checkAccessibilityClaims(ep, r)
+
+	// NAV-021: every linear spine document should be reachable from the toc
+	checkSpineReachableFromToc(ep, r)
 }
 
 type accessibilityMeta struct {
@@ -83,44 +100,30 @@ type accessibilityMeta struct {
 	hasAccessibilityHazard    bool
 }
 
+// collectAccessibilityMeta records which schema.org accessibility
+// properties the package metadata declares. Properties are matched
+// exactly, so schema:accessModeSufficient does not count as
+// schema:accessMode.
 func collectAccessibilityMeta(ep *epub.EPUB) accessibilityMeta {
 	meta := accessibilityMeta{}
-
-	// Read the raw OPF data and search for schema.org accessibility properties
-	if ep.RootfilePath == "" {
-		return meta
-	}
-	data, err := ep.ReadFile(ep.RootfilePath)
-	if err != nil {
-		return meta
-	}
-
-	content := string(data)
-	// Look for meta elements with schema.org properties
-	if strings.Contains(content, "schema:accessMode") || strings.Contains(content, "accessMode") {
-		meta.hasAccessMode = true
-		meta.hasAny = true
-	}
-	if strings.Contains(content, "schema:accessModeSufficient") || strings.Contains(content, "accessModeSufficient") {
-		meta.hasAccessModeSufficient = true
-		meta.hasAny = true
-	}
-	if strings.Contains(content, "schema:accessibilitySummary") || strings.Contains(content, "accessibilitySummary") {
-		meta.hasAccessibilitySummary = true
-		meta.hasAny = true
+	has := func(property string) bool {
+		return len(ep.Package.Metadata.Meta[property]) > 0
 	}
-	if strings.Contains(content, "schema:accessibilityFeature") || strings.Contains(content, "accessibilityFeature") {
-		meta.hasAccessibilityFeature = true
-		meta.hasAny = true
-	}
-	if strings.Contains(content, "schema:accessibilityHazard") || strings.Contains(content, "accessibilityHazard") {
-		meta.hasAccessibilityHazard = true
-		meta.hasAny = true
+	meta.hasAccessMode = has("schema:accessMode")
+	meta.hasAccessModeSufficient = has("schema:accessModeSufficient")
+	meta.hasAccessibilitySummary = has("schema:accessibilitySummary")
+	meta.hasAccessibilityFeature = has("schema:accessibilityFeature")
+	meta.hasAccessibilityHazard = has("schema:accessibilityHazard")
+	for property := range ep.Package.Metadata.Meta {
+		if strings.HasPrefix(property, "schema:access") {
+			meta.hasAny = true
+		}
 	}
 	return meta
 }
 
-// ACC-002: img elements should have an alt attribute
+// ACC-002: img elements should have an alt attribute. alt="" and
+// role="presentation" (or "none") mark an image as decorative.
 func checkImgAltText(ep *epub.EPUB, r *report.Report) {
 	for _, item := range ep.Package.Manifest {
 		if item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" {
@@ -141,15 +144,8 @@ func checkImgAltText(ep *epub.EPUB, r *report.Report) {
 			if !ok || se.Name.Local != "img" {
 				continue
 			}
-			hasAlt := false
-			for _, attr := range se.Attr {
-				if attr.Name.Local == "alt" {
-					hasAlt = true
-					break
-				}
-			}
-			if !hasAlt {
-				r.AddWithLocation(report.Usage, "ACC-002",
+			if !imgHasAlt(se) {
+				r.AddWithLocation(report.Warning, "ACC-002",
 					"Image element is missing 'alt' attribute for accessibility",
 					fullPath)
 			}
@@ -157,6 +153,90 @@ func checkImgAltText(ep *epub.EPUB, r *report.Report) {
 	}
 }
 
+// imgHasAlt reports whether an img element has an alt attribute or is
+// marked decorative with a presentational role.
+func imgHasAlt(se xml.StartElement) bool {
+	for _, attr := range se.Attr {
+		if attr.Name.Local == "alt" || (attr.Name.Local == "role" && isPresentationalRole(attr.Value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPresentationalRole reports whether an ARIA role marks an element as
+// decorative.
+func isPresentationalRole(role string) bool {
+	role = strings.TrimSpace(role)
+	return role == "presentation" || role == "none"
+}
+
+// ACC-017: an inline svg element is an image to assistive technology, so
+// it needs a <title> child or role="img" with aria-label or
+// aria-labelledby, unless it is marked decorative with
+// role="presentation" or aria-hidden="true". Nested svg elements are
+// covered by their outermost svg.
+func checkSVGTextAlternative(ep *epub.EPUB, r *report.Report) {
+	for _, item := range ep.Package.Manifest {
+		if item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		decoder := xml.NewDecoder(strings.NewReader(string(data)))
+		depth := 0 // depth within the outermost svg, 0 when outside
+		labelled := false
+		for {
+			tok, err := decoder.Token()
+			if err != nil {
+				break
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if depth > 0 {
+					depth++
+					if depth == 2 && t.Name.Local == "title" {
+						labelled = true
+					}
+					continue
+				}
+				if t.Name.Local != "svg" {
+					continue
+				}
+				depth = 1
+				var role, label string
+				hidden := false
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "role":
+						role = strings.TrimSpace(attr.Value)
+					case "aria-label", "aria-labelledby":
+						if strings.TrimSpace(attr.Value) != "" {
+							label = attr.Value
+						}
+					case "aria-hidden":
+						hidden = strings.TrimSpace(attr.Value) == "true"
+					}
+				}
+				labelled = hidden || isPresentationalRole(role) || (role == "img" && label != "")
+			case xml.EndElement:
+				if depth == 0 {
+					continue
+				}
+				depth--
+				if depth == 0 && !labelled {
+					r.AddWithLocation(report.Warning, "ACC-017",
+						"Inline svg element has no text alternative: add a <title> child, or role=\"img\" with aria-label (role=\"presentation\" if decorative)",
+						fullPath)
+				}
+			}
+		}
+	}
+}
+
 // ACC-003: html element should declare language
 func checkHTMLLangPresent(ep *epub.EPUB, r *report.Report) {
 	for _, item := range ep.Package.Manifest {
@@ -299,3 +379,334 @@ func navDocHasLandmarks(data []byte) bool {
 	}
 	return false
 }
+
+// Attributes whose value is a space-separated list of ids in the same document
+var idRefAttrs = map[string]bool{
+	"aria-labelledby":  true,
+	"aria-describedby": true,
+	"headers":          true,
+	"for":              true,
+}
+
+// ACC-015: id-referencing attributes must point to ids in the same document
+func checkIDRefsResolve(ep *epub.EPUB, r *report.Report) {
+	for _, item := range ep.Package.Manifest {
+		if item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		checkDocumentIDRefs(data, fullPath, r)
+	}
+}
+
+func checkDocumentIDRefs(data []byte, location string, r *report.Report) {
+	ids := collectIDs(data)
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range se.Attr {
+			if attr.Name.Space != "" || !idRefAttrs[attr.Name.Local] {
+				continue
+			}
+			for _, ref := range strings.Fields(attr.Value) {
+				if !ids[ref] {
+					r.AddWithLocation(report.Warning, "ACC-015",
+						fmt.Sprintf("Element <%s> attribute '%s' references id '%s' which does not exist in this document", se.Name.Local, attr.Name.Local, ref),
+						location)
+				}
+			}
+		}
+	}
+}
+
+// ACC-030: heading levels should increase one at a time so assistive
+// technology can present the document outline.
+func checkHeadingLevels(ep *epub.EPUB, r *report.Report) {
+	for _, item := range ep.Package.Manifest {
+		if item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" {
+			continue
+		}
+		if hasProperty(item.Properties, "nav") {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		checkDocumentHeadingLevels(data, fullPath, r)
+	}
+}
+
+// checkDocumentHeadingLevels walks h1-h6 in document order. A sectioning
+// element (section, article, aside, nav) starts from the level of the
+// heading before it, and the level reverts when it closes, so a later
+// sibling is compared with the enclosing context. The first heading of a
+// document may be at any level, as split chapters often start at h2.
+func checkDocumentHeadingLevels(data []byte, location string, r *report.Report) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	level := 0
+	var saved []int
+	for {
+		offset := decoder.InputOffset()
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "section", "article", "aside", "nav":
+				saved = append(saved, level)
+				continue
+			}
+			n := headingLevel(t.Name.Local)
+			if n == 0 {
+				continue
+			}
+			text := headingText(decoder)
+			if level > 0 && n > level+1 {
+				line, col := lineColumn(data, int(offset))
+				r.AddWithPosition(report.Warning, "ACC-030",
+					fmt.Sprintf("Heading level skips from h%d to h%d at '%s'", level, n, text),
+					location, line, col)
+			}
+			level = n
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "section", "article", "aside", "nav":
+				if len(saved) > 0 {
+					level = saved[len(saved)-1]
+					saved = saved[:len(saved)-1]
+				}
+			}
+		}
+	}
+}
+
+// headingLevel returns 1-6 for h1-h6 and 0 for any other element.
+func headingLevel(name string) int {
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+		return int(name[1] - '0')
+	}
+	return 0
+}
+
+// headingText consumes tokens up to the end of the heading just opened and
+// returns its text with whitespace collapsed.
+func headingText(decoder *xml.Decoder) string {
+	var text strings.Builder
+	depth := 1
+	for depth > 0 {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+	return strings.Join(strings.Fields(text.String()), " ")
+}
+
+// accessibilityClaims holds the schema:accessibilityFeature values and
+// dcterms:conformsTo references declared in the package metadata.
+type accessibilityClaims struct {
+	features   []string
+	conformsTo []string
+}
+
+func collectAccessibilityClaims(ep *epub.EPUB) accessibilityClaims {
+	md := ep.Package.Metadata
+	claims := accessibilityClaims{
+		features:   md.Meta["schema:accessibilityFeature"],
+		conformsTo: append([]string(nil), md.Meta["dcterms:conformsTo"]...),
+	}
+	for _, link := range md.Links {
+		if containsToken(link.Rel, "dcterms:conformsTo") {
+			claims.conformsTo = append(claims.conformsTo, link.Href)
+		}
+	}
+	return claims
+}
+
+// accessibilityEvidence summarizes what the content documents actually
+// provide, for comparison against declared accessibility claims.
+type accessibilityEvidence struct {
+	images       int
+	imagesNoAlt  int
+	firstNoAlt   string
+	headings     int
+	descriptions bool
+	mathml       bool
+	pageList     bool
+}
+
+func collectAccessibilityEvidence(ep *epub.EPUB) accessibilityEvidence {
+	var ev accessibilityEvidence
+	for _, item := range ep.Package.Manifest {
+		if item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		if hasProperty(item.Properties, "nav") && navDocHasPageList(data) {
+			ev.pageList = true
+		}
+		decoder := xml.NewDecoder(strings.NewReader(string(data)))
+		for {
+			tok, err := decoder.Token()
+			if err != nil {
+				break
+			}
+			se, ok := tok.(xml.StartElement)
+			if !ok {
+				continue
+			}
+			switch se.Name.Local {
+			case "img":
+				ev.images++
+				if !imgHasAlt(se) {
+					ev.imagesNoAlt++
+					if ev.firstNoAlt == "" {
+						ev.firstNoAlt = fullPath
+					}
+				}
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				ev.headings++
+			case "math":
+				ev.mathml = true
+			case "details":
+				ev.descriptions = true
+			}
+			for _, attr := range se.Attr {
+				if attr.Name.Local == "aria-describedby" || attr.Name.Local == "aria-details" {
+					ev.descriptions = true
+				}
+			}
+		}
+	}
+	return ev
+}
+
+// ACC-016: schema:accessibilityFeature values and EPUB Accessibility
+// conformance claims that the content contradicts
+func checkAccessibilityClaims(ep *epub.EPUB, r *report.Report) {
+	claims := collectAccessibilityClaims(ep)
+	if len(claims.features) == 0 && len(claims.conformsTo) == 0 {
+		return
+	}
+	ev := collectAccessibilityEvidence(ep)
+
+	for _, feature := range claims.features {
+		var evidence string
+		switch feature {
+		case "alternativeText":
+			if ev.imagesNoAlt > 0 {
+				evidence = fmt.Sprintf("%d of %d images have no alt attribute (first in '%s')", ev.imagesNoAlt, ev.images, ev.firstNoAlt)
+			}
+		case "longDescription":
+			if !ev.descriptions {
+				evidence = "no content uses aria-describedby, aria-details or <details>"
+			}
+		case "structuralNavigation":
+			if ev.headings == 0 {
+				evidence = "no content document contains h1-h6 headings"
+			}
+		case "printPageNumbers", "pageNavigation":
+			if !ev.pageList {
+				evidence = "the navigation document has no page-list"
+			}
+		case "MathML":
+			if !ev.mathml {
+				evidence = "no content document contains MathML"
+			}
+		}
+		if evidence != "" {
+			r.Add(report.Warning, "ACC-016",
+				fmt.Sprintf("Accessibility feature '%s' is declared but %s", feature, evidence))
+		}
+	}
+
+	for _, ref := range claims.conformsTo {
+		if !strings.Contains(ref, "epub/a11y") && !strings.Contains(ref, "epub-a11y") {
+			continue
+		}
+		if ev.imagesNoAlt > 0 {
+			r.Add(report.Warning, "ACC-016",
+				fmt.Sprintf("Publication claims conformance to '%s' but %d of %d images have no alt attribute (first in '%s')", ref, ev.imagesNoAlt, ev.images, ev.firstNoAlt))
+		}
+	}
+}
+
+// NAV-021: linear spine documents that no toc entry links to can only be
+// reached by paging, which is hard for users navigating by structure.
+func checkSpineReachableFromToc(ep *epub.EPUB, r *report.Report) {
+	var navPath string
+	for _, item := range ep.Package.Manifest {
+		if hasProperty(item.Properties, "nav") && item.Href != "\x00MISSING" {
+			navPath = ep.ResolveHref(item.Href)
+			break
+		}
+	}
+	if navPath == "" {
+		return
+	}
+	data, err := ep.ReadFile(navPath)
+	if err != nil {
+		return
+	}
+	navInfo := parseNavDocument(ep, data, navPath)
+	if navInfo.tocCount == 0 {
+		return
+	}
+
+	linked := make(map[string]bool)
+	for _, link := range navInfo.tocLinks {
+		u, err := url.Parse(link.href)
+		if err != nil || u.Scheme != "" || u.Path == "" {
+			continue
+		}
+		linked[resolvePath(path.Dir(navPath), u.Path)] = true
+	}
+
+	manifestByID := make(map[string]epub.ManifestItem)
+	for _, item := range ep.Package.Manifest {
+		manifestByID[item.ID] = item
+	}
+	for _, ref := range ep.Package.Spine {
+		if ref.Linear == "no" {
+			continue
+		}
+		item, ok := manifestByID[ref.IDRef]
+		if !ok || item.Href == "\x00MISSING" || item.MediaType != "application/xhtml+xml" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		if fullPath == navPath || linked[fullPath] {
+			continue
+		}
+		r.AddWithLocation(report.Warning, "NAV-021",
+			fmt.Sprintf("Linear spine document '%s' is not reachable from the table of contents", item.Href),
+			fullPath)
+	}
+}
@@ -0,0 +1,214 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/adammathes/epubverify/pkg/report"
+)
+
+func TestCheckDocumentIDRefs(t *testing.T) {
+	xhtml := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Test</title></head>
+<body>
+  <h2 id="title">Figure</h2>
+  <figure aria-labelledby="title" aria-describedby="caption missing-desc">
+    <label for="name">Name</label><input id="name"/>
+    <table><tr><th id="h1">A</th></tr><tr><td headers="h1 h2">1</td></tr></table>
+  </figure>
+</body>
+</html>`
+
+	r := report.NewReport()
+	checkDocumentIDRefs([]byte(xhtml), "test.xhtml", r)
+
+	var missing []string
+	for _, m := range r.Messages {
+		if m.CheckID != "ACC-015" {
+			continue
+		}
+		for _, id := range []string{"caption", "missing-desc", "h2", "title", "name", "h1"} {
+			if strings.Contains(m.Message, "'"+id+"'") {
+				missing = append(missing, id)
+			}
+		}
+	}
+	want := "caption missing-desc h2"
+	if got := strings.Join(missing, " "); got != want {
+		t.Errorf("ACC-015 flagged ids %q, want %q", got, want)
+	}
+}
+
+func TestCheckAccessibilityClaims(t *testing.T) {
+	opf := strings.Replace(testOPF(""), "</metadata>",
+		`    <meta property="schema:accessibilityFeature">alternativeText</meta>
+    <meta property="schema:accessibilityFeature">MathML</meta>
+    <meta property="schema:accessibilityFeature">structuralNavigation</meta>
+    <meta property="schema:accessibilityFeature" refines="#uid">longDescription</meta>
+    <link rel="dcterms:conformsTo" href="http://www.idpf.org/epub/a11y/accessibility-20170105.html#wcag-aa"/>
+  </metadata>`, 1)
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en"><head><title>Chapter 1</title></head>
+<body><h1>Chapter 1</h1><p><img src="a.png"/><img src="b.png" alt=""/><img src="c.png" role="none"/></p></body></html>`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf":    opf,
+		"OEBPS/chapter1.xhtml": chapter,
+	})
+
+	r, err := ValidateWithOptions(path, Options{Accessibility: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "ACC-016" {
+			got = append(got, m.Message)
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 ACC-016 messages (alternativeText, MathML, conformsTo), got %q", got)
+	}
+	for _, m := range got {
+		if strings.Contains(m, "structuralNavigation") {
+			t.Errorf("structuralNavigation is backed by headings but was flagged: %s", m)
+		}
+		// A refining meta is not a claim about the publication
+		if strings.Contains(m, "longDescription") {
+			t.Errorf("refining meta was taken as a claim: %s", m)
+		}
+		// role="none" marks an image decorative, as it does for ACC-002
+		if strings.Contains(m, "images have no alt") && !strings.Contains(m, "1 of 3 images") {
+			t.Errorf("only the unmarked image should count as missing alt: %s", m)
+		}
+	}
+}
+
+func TestCheckSpineReachableFromToc(t *testing.T) {
+	opf := strings.Replace(testOPF(`    <item id="ch2" href="chapter2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="notes" href="notes.xhtml" media-type="application/xhtml+xml"/>
+`), `<itemref idref="ch1"/>`, `<itemref idref="ch1"/>
+    <itemref idref="ch2"/>
+    <itemref idref="notes" linear="no"/>`, 1)
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf":    opf,
+		"OEBPS/chapter2.xhtml": testChapterXHTML,
+		"OEBPS/notes.xhtml":    testChapterXHTML,
+	})
+
+	r, err := ValidateWithOptions(path, Options{Accessibility: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "NAV-021" {
+			got = append(got, m.Location)
+		}
+	}
+	if len(got) != 1 || got[0] != "OEBPS/chapter2.xhtml" {
+		t.Errorf("expected NAV-021 only for chapter2.xhtml, got %v", got)
+	}
+}
+
+func TestImageTextAlternatives(t *testing.T) {
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">
+<head><title>Chapter 1</title></head>
+<body>
+<img src="a.png"/>
+<img src="b.png" alt=""/>
+<img src="c.png" role="presentation"/>
+<svg xmlns="http://www.w3.org/2000/svg"><circle r="1"/></svg>
+<svg xmlns="http://www.w3.org/2000/svg"><title>Chart</title><svg><circle r="1"/></svg></svg>
+<svg xmlns="http://www.w3.org/2000/svg" role="img" aria-label="Chart"><circle r="1"/></svg>
+<svg xmlns="http://www.w3.org/2000/svg" role="img"><g><title>Not a direct child</title></g></svg>
+<svg xmlns="http://www.w3.org/2000/svg" role="presentation"><circle r="1"/></svg>
+</body>
+</html>`
+	path := writeTestEPUB(t, map[string]string{"OEBPS/chapter1.xhtml": chapter})
+	r, err := ValidateWithOptions(path, Options{Accessibility: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, m := range r.Messages {
+		if m.CheckID == "ACC-002" || m.CheckID == "ACC-017" {
+			if m.Severity != report.Warning {
+				t.Errorf("%s severity = %s, want WARNING", m.CheckID, m.Severity)
+			}
+			counts[m.CheckID]++
+		}
+	}
+	if counts["ACC-002"] != 1 || counts["ACC-017"] != 2 {
+		t.Errorf("got %d ACC-002 and %d ACC-017, want 1 and 2", counts["ACC-002"], counts["ACC-017"])
+	}
+}
+
+func TestAccessibilityMetadataExactProperties(t *testing.T) {
+	opf := strings.Replace(testOPF(""), "</metadata>",
+		`    <meta property="schema:accessModeSufficient">textual</meta>
+    <meta property="schema:accessibilityFeature">structuralNavigation</meta>
+  </metadata>`, 1)
+	path := writeTestEPUB(t, map[string]string{"OEBPS/content.opf": opf})
+	r, err := ValidateWithOptions(path, Options{Accessibility: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]bool{
+		"ACC-001": false, // some metadata is present
+		"ACC-005": true,  // accessModeSufficient is not accessMode
+		"ACC-006": false,
+		"ACC-007": true,
+		"ACC-008": false,
+		"ACC-009": true,
+	} {
+		if got := hasCheck(r, id); got != want {
+			t.Errorf("%s reported = %v, want %v", id, got, want)
+		}
+	}
+	for _, m := range r.Messages {
+		switch m.CheckID {
+		case "ACC-005", "ACC-007", "ACC-009":
+			if m.Severity != report.Warning {
+				t.Errorf("%s severity = %s, want WARNING", m.CheckID, m.Severity)
+			}
+		}
+	}
+}
+
+func TestCheckDocumentHeadingLevels(t *testing.T) {
+	xhtml := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Test</title></head>
+<body>
+  <h1>Book</h1>
+  <section>
+    <h2>Part</h2>
+    <section><h3>Chapter</h3></section>
+  </section>
+  <h2>Next part</h2>
+  <h4>Too <em>deep</em></h4>
+  <section><h4>Also deep</h4></section>
+</body>
+</html>`
+
+	r := report.NewReport()
+	checkDocumentHeadingLevels([]byte(xhtml), "test.xhtml", r)
+
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "ACC-030" {
+			got = append(got, fmt.Sprintf("%d: %s", m.Line, m.Message))
+		}
+	}
+	want := []string{
+		"11: Heading level skips from h2 to h4 at 'Too deep'",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ACC-030 messages:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -8,14 +8,20 @@ import (
 	"net/url"
 	"path"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/adammathes/epubverify/pkg/epub"
 	"github.com/adammathes/epubverify/pkg/report"
 )
 
 // checkContentWithSkips validates XHTML content documents, skipping files with known encoding issues.
-func checkContentWithSkips(ep *epub.EPUB, r *report.Report, skipFiles map[string]bool) {
+// With concurrency above 1 the documents, and the SVG documents in the
+// spine, are checked by that many workers; each document's messages are
+// buffered and added to r in manifest order, so the report matches a
+// serial run.
+func checkContentWithSkips(ep *epub.EPUB, r *report.Report, skipFiles map[string]bool, opts Options) {
 	if ep.Package == nil {
 		return
 	}
@@ -30,6 +36,7 @@ func checkContentWithSkips(ep *epub.EPUB, r *report.Report, skipFiles map[string
 
 	isFXL := ep.Package.RenditionLayout == "pre-paginated"
 
+	var docs []epub.ManifestItem
 	for _, item := range ep.Package.Manifest {
 		if item.Href == "\x00MISSING" {
 			continue
@@ -37,144 +44,224 @@ func checkContentWithSkips(ep *epub.EPUB, r *report.Report, skipFiles map[string
 		if item.MediaType != "application/xhtml+xml" {
 			continue
 		}
-
-		fullPath := ep.ResolveHref(item.Href)
-
 		// Skip files with encoding errors
-		if skipFiles[fullPath] {
+		if skipFiles[ep.ResolveHref(item.Href)] {
 			continue
 		}
+		docs = append(docs, item)
+	}
 
-		data, err := ep.ReadFile(fullPath)
-		if err != nil {
-			continue // Missing file reported by RSC-001
-		}
+	// HTM-034: SVG content documents in the spine must have an svg root
+	svgDocs := spineSVGDocuments(ep)
 
-		isNav := hasProperty(item.Properties, "nav")
+	// Each job checks one XHTML document, then one spine SVG document;
+	// the scripting found in each XHTML document is kept for HTM-039.
+	jobs := len(docs) + len(svgDocs)
+	scripting := make([][]string, len(docs))
+	check := func(i int, r *report.Report) {
+		if i < len(docs) {
+			scripting[i] = checkContentDocument(ep, docs[i], manifestPaths, isFXL, opts, r)
+		} else {
+			checkSVGContentRoot(ep, svgDocs[i-len(docs)], r)
+		}
+	}
 
-		// HTM-001: XHTML must be well-formed XML
-		// Skip nav docs - NAV-011 handles them
-		if !isNav {
-			if !checkXHTMLWellFormed(data, fullPath, r) {
-				continue // Can't check further if not well-formed
+	concurrency := opts.Concurrency
+	if concurrency <= 1 {
+		for i := 0; i < jobs; i++ {
+			check(i, r)
+		}
+	} else {
+		results := make([]*report.Report, jobs)
+		next := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < concurrency && w < jobs; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range next {
+					results[i] = report.NewReport()
+					check(i, results[i])
+				}
+			}()
+		}
+		for i := 0; i < jobs; i++ {
+			next <- i
+		}
+		close(next)
+		wg.Wait()
+		for _, res := range results {
+			for _, m := range res.Messages {
+				r.AddMessage(m)
 			}
 		}
+	}
 
-		// HTM-002: content should have title (WARNING)
-		checkContentHasTitle(data, fullPath, r)
-
-		// HTM-003: empty href attributes
-		checkEmptyHrefAttributes(data, fullPath, r)
+	// HTM-039: list the documents that run script
+	checkScriptedContent(docs, scripting, r)
+}
 
-		// HTM-004: no obsolete elements
-		checkNoObsoleteElements(data, fullPath, r)
+// checkContentDocument runs the per-file content checks on one XHTML
+// manifest item. It returns the kinds of scripting the document uses, as
+// listed by ScriptingKinds, for HTM-039.
+func checkContentDocument(ep *epub.EPUB, item epub.ManifestItem, manifestPaths map[string]bool, isFXL bool, opts Options, r *report.Report) (scripting []string) {
+	fullPath := ep.ResolveHref(item.Href)
+	data, err := ep.ReadFile(fullPath)
+	if err != nil {
+		return nil // Missing file reported by RSC-001
+	}
+	scripting = ScriptingKinds(data)
 
-		// HTM-009: base element not allowed
-		checkNoBaseElement(data, fullPath, r)
+	isNav := hasProperty(item.Properties, "nav")
 
-		// HTM-010/HTM-011/HTM-012: DOCTYPE and namespace checks (EPUB 3 only)
-		if ep.Package.Version >= "3.0" {
-			if !checkDoctypeHTML5(data, fullPath, r) {
-				checkDoctype(data, fullPath, r)
-			}
+	// HTM-001: XHTML must be well-formed XML
+	// Skip nav docs - NAV-011 handles them
+	if !isNav {
+		if !checkXHTMLWellFormed(data, fullPath, r) {
+			return scripting // Can't check further if not well-formed
 		}
-		checkXHTMLNamespace(data, fullPath, r)
+	}
 
-		// HTM-005/HTM-006/HTM-007: property declarations
-		if ep.Package.Version >= "3.0" {
-			checkPropertyDeclarations(ep, data, fullPath, item, r)
-		}
+	// HTM-002: content should have title (WARNING)
+	checkContentHasTitle(data, fullPath, r)
 
-		// HTM-015: epub:type values must be valid (EPUB 3 only)
-		if ep.Package.Version >= "3.0" {
-			checkEpubTypeValid(data, fullPath, r)
-		}
+	// HTM-003: empty href attributes
+	checkEmptyHrefAttributes(data, fullPath, r)
 
-		// HTM-020: no processing instructions
-		checkNoProcessingInstructions(data, fullPath, r)
+	// HTM-004: no obsolete elements
+	checkNoObsoleteElements(data, fullPath, r)
 
-		// HTM-021: position:absolute warning
-		checkNoPositionAbsolute(data, fullPath, r)
+	// Relative references resolve against the document's directory, or
+	// against its <base href>; find that once for the checks below.
+	baseDir := contentBaseDir(data, fullPath)
 
-		// HTM-013/HTM-014: FXL viewport checks
-		if isFXL && ep.Package.Version >= "3.0" {
-			// Skip nav document from FXL viewport checks
-			if !hasProperty(item.Properties, "nav") {
-				checkFXLViewport(data, fullPath, r)
-			}
-		}
+	// HTM-009: base element not allowed, noting how its href changes
+	// what relative references resolve to
+	checkNoBaseElement(data, fullPath, baseDir, r)
 
-		// RSC-003: fragment identifiers must resolve (skip nav - handled by NAV checks)
-		if !isNav {
-			checkFragmentIdentifiers(ep, data, fullPath, r)
+	// HTM-010/HTM-011/HTM-012: DOCTYPE and namespace checks (EPUB 3 only)
+	if ep.Package.Version >= "3.0" {
+		if !checkDoctypeHTML5(data, fullPath, r) {
+			checkDoctype(data, fullPath, r)
 		}
+	}
+	checkXHTMLNamespace(data, fullPath, r)
+
+	// HTM-005/HTM-006/HTM-007/HTM-041: property declarations
+	if ep.Package.Version >= "3.0" {
+		checkPropertyDeclarations(ep, data, fullPath, item, r)
+	}
+
+	// HTM-015: epub:type values must be valid (EPUB 3 only)
+	if ep.Package.Version >= "3.0" {
+		checkEpubTypeValid(data, fullPath, r)
+	}
+
+	// HTM-020: no processing instructions
+	checkNoProcessingInstructions(data, fullPath, r)
 
-		// RSC-004: no remote resources (img src with http://)
-		// RSC-008: no remote stylesheets
-		checkNoRemoteResources(ep, data, fullPath, item, r)
+	// HTM-021: position:absolute warning
+	checkNoPositionAbsolute(data, fullPath, r)
 
-		// HTM-008 / RSC-007: check internal links and resource references
-		// Skip nav document - its links are checked by NAV-003/006/007
-		if !isNav {
-			checkContentReferences(ep, data, fullPath, item.Href, manifestPaths, r)
+	// HTM-013/HTM-014: FXL viewport checks
+	if isFXL && ep.Package.Version >= "3.0" {
+		// Skip nav document from FXL viewport checks
+		if !hasProperty(item.Properties, "nav") {
+			checkFXLViewport(data, fullPath, r)
 		}
+	}
 
-		// HTM-016: unique IDs within content document
-		checkUniqueIDs(data, fullPath, r)
+	// RSC-003: fragment identifiers must resolve (skip nav - handled by NAV checks)
+	if !isNav {
+		checkFragmentIdentifiers(ep, data, fullPath, baseDir, r)
+	}
 
-		// HTM-018: single body element
-		checkSingleBody(data, fullPath, r)
+	// RSC-004: no remote resources (img src with http://)
+	// RSC-008: no remote stylesheets
+	checkNoRemoteResources(ep, data, fullPath, item, r)
 
-		// HTM-019: html root element
-		hasHTMLRoot := checkHTMLRootElement(data, fullPath, r)
+	// HTM-008 / RSC-007: check internal links and resource references
+	// Skip nav document - its links are checked by NAV-003/006/007
+	if !isNav {
+		checkContentReferences(ep, data, fullPath, baseDir, item.Href, manifestPaths, r)
+	}
 
-		// HTM-022: object data references must resolve
-		if !isNav {
-			checkObjectReferences(ep, data, fullPath, r)
-		}
+	// HTM-016: unique IDs within content document
+	checkUniqueIDs(data, fullPath, r)
 
-		// HTM-023: no parent directory links that escape container
-		if !isNav {
-			checkNoParentDirLinks(ep, data, fullPath, r)
-		}
+	// HTM-018: single body element
+	checkSingleBody(data, fullPath, r)
 
-		// HTM-024: content documents must have a head element (skip if no html root)
-		if hasHTMLRoot {
-			checkContentHasHead(data, fullPath, r)
-		}
+	// HTM-019: html root element
+	hasHTMLRoot := checkHTMLRootElement(data, fullPath, r)
 
-		// HTM-025: embed element references must exist
-		if !isNav {
-			checkEmbedReferences(ep, data, fullPath, r)
-		}
+	// HTM-034: html root element must be in the XHTML namespace
+	if hasHTMLRoot {
+		checkHTMLRootNamespace(data, fullPath, r)
+	}
 
-		// HTM-026: lang and xml:lang must match
-		checkLangXMLLangMatch(data, fullPath, r)
+	// HTM-022: object data references must resolve
+	if !isNav {
+		checkObjectReferences(ep, data, fullPath, baseDir, r)
+	}
 
-		// HTM-027: video poster must exist
-		if ep.Package.Version >= "3.0" && !isNav {
-			checkVideoPosterExists(ep, data, fullPath, r)
-		}
+	// HTM-023: no parent directory links that escape container
+	if !isNav {
+		checkNoParentDirLinks(ep, data, fullPath, baseDir, r)
+	}
 
-		// HTM-028: audio src must exist
-		if ep.Package.Version >= "3.0" && !isNav {
-			checkAudioSrcExists(ep, data, fullPath, r)
-		}
+	// HTM-024: content documents must have a head element (skip if no html root)
+	if hasHTMLRoot {
+		checkContentHasHead(data, fullPath, r)
+	}
+
+	// HTM-025: embed element references must exist
+	if !isNav {
+		checkEmbedReferences(ep, data, fullPath, baseDir, r)
+	}
 
-		// HTM-030: img src must not be empty
-		checkImgSrcNotEmpty(data, fullPath, r)
+	// HTM-026: lang and xml:lang must match
+	checkLangXMLLangMatch(data, fullPath, r)
 
-		// HTM-031: SSML namespace check
-		if ep.Package.Version >= "3.0" {
-			checkSSMLNamespace(data, fullPath, r)
-		}
+	// HTM-027: video poster must exist
+	if ep.Package.Version >= "3.0" && !isNav {
+		checkVideoPosterExists(ep, data, fullPath, baseDir, r)
+	}
+
+	// HTM-028: audio src must exist
+	if ep.Package.Version >= "3.0" && !isNav {
+		checkAudioSrcExists(ep, data, fullPath, baseDir, r)
+	}
+
+	// HTM-030: img src must not be empty
+	checkImgSrcNotEmpty(data, fullPath, r)
+
+	// HTM-031: SSML namespace check
+	if ep.Package.Version >= "3.0" {
+		checkSSMLNamespace(data, fullPath, r)
+	}
 
-		// HTM-032: style element CSS syntax
-		checkStyleElementValid(data, fullPath, r)
+	// HTM-032: style element CSS syntax
+	checkStyleElementValid(data, fullPath, r)
 
-		// HTM-033: no RDF elements in content
-		checkNoRDFElements(data, fullPath, r)
+	// HTM-033: no RDF elements in content
+	checkNoRDFElements(data, fullPath, r)
+
+	// HTM-035: content must not be generated entirely by script
+	if !isNav {
+		checkScriptOnlyBody(data, fullPath, r)
+	}
+
+	// HTM-040: large data: URIs belong in their own file
+	checkLargeDataURIs(data, fullPath, opts.MaxDataURIBytes, r)
+
+	// HTM-038/HTM-042/HTM-043: epub:switch needs an epub:default fallback and
+	// an epub:case, and is deprecated
+	// OPF-077: manifest 'switch' property declared but no epub:switch used
+	if ep.Package.Version >= "3.0" {
+		checkEpubSwitch(data, fullPath, item, r)
 	}
+	return scripting
 }
 
 // HTM-001: check that XHTML is well-formed XML
@@ -305,9 +392,10 @@ func checkDoctype(data []byte, location string, r *report.Report) {
 	// EPUB 3 should use HTML5 DOCTYPE: <!DOCTYPE html> (case insensitive)
 	// It should NOT have PUBLIC or SYSTEM identifiers
 	if strings.Contains(doctype, "PUBLIC") || strings.Contains(doctype, "SYSTEM") {
-		r.AddWithLocation(report.Error, "HTM-011",
+		line, col := lineColumn(data, idx)
+		r.AddWithPosition(report.Error, "HTM-011",
 			"Irregular DOCTYPE: EPUB 3 content documents should use <!DOCTYPE html>",
-			location)
+			location, line, col)
 	}
 }
 
@@ -335,9 +423,11 @@ func checkXHTMLNamespace(data []byte, location string, r *report.Report) {
 }
 
 // HTM-005/HTM-006/HTM-007: check for script/SVG/MathML and undeclared properties
+// HTM-041: 'scripted' declared on a document with no script or form
 func checkPropertyDeclarations(ep *epub.EPUB, data []byte, location string, item epub.ManifestItem, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
 	hasScript := false
+	hasForm := false
 	hasSVG := false
 	hasMathML := false
 
@@ -351,9 +441,19 @@ func checkPropertyDeclarations(ep *epub.EPUB, data []byte, location string, item
 			continue
 		}
 
-		if se.Name.Local == "script" {
+		// Inline handlers and javascript: URLs run script as much as a
+		// <script> element does; data blocks such as JSON-LD do not.
+		if isExecutableScript(se) {
 			hasScript = true
 		}
+		for _, attr := range se.Attr {
+			if scriptingAttrKind(attr) != "" {
+				hasScript = true
+			}
+		}
+		if se.Name.Local == "form" {
+			hasForm = true
+		}
 		if se.Name.Local == "svg" || se.Name.Space == "http://www.w3.org/2000/svg" {
 			hasSVG = true
 		}
@@ -367,6 +467,12 @@ func checkPropertyDeclarations(ep *epub.EPUB, data []byte, location string, item
 			"Property 'scripted' should be declared in the manifest for scripted content",
 			location)
 	}
+	// Forms make a document scripted too, so they justify the property.
+	if !hasScript && !hasForm && hasProperty(item.Properties, "scripted") {
+		r.AddWithLocation(report.Warning, "HTM-041",
+			"Property 'scripted' is declared in the manifest but the content document has no script or form",
+			location)
+	}
 	if hasSVG && !hasProperty(item.Properties, "svg") {
 		r.AddWithLocation(report.Error, "HTM-006",
 			"Property 'svg' should be declared in the manifest for content with inline SVG",
@@ -379,6 +485,98 @@ func checkPropertyDeclarations(ep *epub.EPUB, data []byte, location string, item
 	}
 }
 
+// executableScriptTypes are the <script> type values browsers run as
+// script; anything else, such as application/ld+json, is a data block.
+// An absent or empty type also means JavaScript.
+var executableScriptTypes = map[string]bool{
+	"text/javascript":          true,
+	"application/javascript":   true,
+	"application/x-javascript": true,
+	"text/ecmascript":          true,
+	"application/ecmascript":   true,
+	"module":                   true,
+}
+
+// isExecutableScript reports whether se is a <script> element a browser
+// would run, rather than a data block.
+func isExecutableScript(se xml.StartElement) bool {
+	if se.Name.Local != "script" {
+		return false
+	}
+	typ := ""
+	for _, attr := range se.Attr {
+		if attr.Name.Local == "type" {
+			typ, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(attr.Value)), ";")
+		}
+	}
+	return typ == "" || executableScriptTypes[strings.TrimSpace(typ)]
+}
+
+// ScriptingKinds lists the kinds of scripting in a content document, in
+// the order first found: "script" for an executable <script> element,
+// "event handler" for inline handlers such as onclick, and
+// "javascript: URL" for links and sources using that scheme.
+func ScriptingKinds(data []byte) []string {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	var kinds []string
+	add := func(kind string) {
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if isExecutableScript(se) {
+			add("script")
+		}
+		for _, attr := range se.Attr {
+			if kind := scriptingAttrKind(attr); kind != "" {
+				add(kind)
+			}
+		}
+	}
+	return kinds
+}
+
+// scriptingAttrKind returns "event handler" for an inline handler such as
+// onclick, "javascript: URL" for a link or source using that scheme, and
+// "" for any other attribute.
+func scriptingAttrKind(attr xml.Attr) string {
+	name := strings.ToLower(attr.Name.Local)
+	switch {
+	case attr.Name.Space == "" && len(name) > 2 && strings.HasPrefix(name, "on"):
+		return "event handler"
+	case name == "href" || name == "src" || name == "action" || name == "formaction":
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(attr.Value)), "javascript:") {
+			return "javascript: URL"
+		}
+	}
+	return ""
+}
+
+// HTM-039: some storefronts reject books that run script, so name every
+// content document that does and how. This is context, not a problem.
+func checkScriptedContent(docs []epub.ManifestItem, scripting [][]string, r *report.Report) {
+	var scripted []string
+	for i, item := range docs {
+		if kinds := scripting[i]; len(kinds) > 0 {
+			scripted = append(scripted, fmt.Sprintf("%s (%s)", item.Href, strings.Join(kinds, ", ")))
+		}
+	}
+	if len(scripted) > 0 {
+		r.Add(report.Info, "HTM-039",
+			fmt.Sprintf("Publication contains scripted content in %d content documents: %s", len(scripted), strings.Join(scripted, "; ")))
+	}
+}
+
 // HTM-013/HTM-014: Fixed-layout viewport checks
 func checkFXLViewport(data []byte, location string, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
@@ -445,9 +643,8 @@ func checkFXLViewport(data []byte, location string, r *report.Report) {
 }
 
 // RSC-003: fragment identifiers must resolve
-func checkFragmentIdentifiers(ep *epub.EPUB, data []byte, fullPath string, r *report.Report) {
+func checkFragmentIdentifiers(ep *epub.EPUB, data []byte, fullPath, itemDir string, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
-	itemDir := path.Dir(fullPath)
 
 	// Collect all id attributes in the document for self-references
 	ids := collectIDs(data)
@@ -463,7 +660,7 @@ func checkFragmentIdentifiers(ep *epub.EPUB, data []byte, fullPath string, r *re
 			continue
 		}
 
-		if se.Name.Local == "a" {
+		if se.Name.Local == "a" || se.Name.Local == "area" {
 			for _, attr := range se.Attr {
 				if attr.Name.Local == "href" {
 					checkFragmentRef(ep, attr.Value, itemDir, fullPath, ids, r)
@@ -594,9 +791,8 @@ func isRemoteURL(s string) bool {
 }
 
 // checkContentReferences finds href/src attributes in XHTML and validates them.
-func checkContentReferences(ep *epub.EPUB, data []byte, fullPath, itemHref string, manifestPaths map[string]bool, r *report.Report) {
+func checkContentReferences(ep *epub.EPUB, data []byte, fullPath, itemDir, itemHref string, manifestPaths map[string]bool, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
-	itemDir := path.Dir(fullPath)
 
 	for {
 		tok, err := decoder.Token()
@@ -609,8 +805,8 @@ func checkContentReferences(ep *epub.EPUB, data []byte, fullPath, itemHref strin
 			continue
 		}
 
-		// Check <a href="..."> for internal links
-		if se.Name.Local == "a" {
+		// Check <a href="..."> and image map <area href="..."> for internal links
+		if se.Name.Local == "a" || se.Name.Local == "area" {
 			for _, attr := range se.Attr {
 				if attr.Name.Local == "href" {
 					checkHyperlink(ep, attr.Value, itemDir, fullPath, r)
@@ -626,6 +822,15 @@ func checkContentReferences(ep *epub.EPUB, data []byte, fullPath, itemHref strin
 				}
 			}
 		}
+
+		// Check inline SVG <image href="..."> and <image xlink:href="...">
+		if se.Name.Local == "image" {
+			for _, attr := range se.Attr {
+				if attr.Name.Local == "href" {
+					checkResourceRef(ep, attr.Value, itemDir, fullPath, manifestPaths, r)
+				}
+			}
+		}
 	}
 }
 
@@ -766,10 +971,186 @@ func checkHTMLRootElement(data []byte, location string, r *report.Report) bool {
 	}
 }
 
+// HTM-034: the html root must be in the XHTML namespace. HTM-019 covers
+// a non-html root and HTM-012 a foreign namespace; this catches a missing one.
+func checkHTMLRootNamespace(data []byte, location string, r *report.Report) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			if se.Name.Space == "" {
+				r.AddWithLocation(report.Error, "HTM-034",
+					"The root element 'html' is not in the XHTML namespace (http://www.w3.org/1999/xhtml)",
+					location)
+			}
+			return
+		}
+	}
+}
+
+// Elements that carry visible content without needing script
+var staticContentElements = map[string]bool{
+	"img": true, "svg": true, "video": true, "audio": true,
+	"object": true, "embed": true, "math": true, "iframe": true,
+}
+
+// defaultMaxDataURIBytes is the decoded size above which HTM-040 flags a
+// data: URI, when Options.MaxDataURIBytes is unset.
+const defaultMaxDataURIBytes = 64 << 10
+
+// HTM-040: a large image inlined as a data: URI makes the whole document
+// slower to load and parse, and it cannot be cached or shared between
+// documents the way a packaged file can.
+func checkLargeDataURIs(data []byte, location string, limit int, r *report.Report) {
+	if limit <= 0 {
+		limit = defaultMaxDataURIBytes
+	}
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		offset := decoder.InputOffset()
+		tok, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range se.Attr {
+			if size := dataURISize(attr.Value); size > limit {
+				line, col := lineColumn(data, int(offset))
+				r.AddWithPosition(report.Warning, "HTM-040",
+					fmt.Sprintf("Element '%s' embeds a data: URI of about %d KB in its '%s' attribute; package it as a separate file instead",
+						se.Name.Local, (size+512)/1024, attr.Name.Local),
+					location, line, col)
+			}
+		}
+	}
+}
+
+// dataURISize returns the approximate decoded size in bytes of a data:
+// URI, or -1 if value is not one.
+func dataURISize(value string) int {
+	value = strings.TrimSpace(value)
+	if len(value) < 5 || !strings.EqualFold(value[:5], "data:") {
+		return -1
+	}
+	header, payload, ok := strings.Cut(value[5:], ",")
+	if !ok {
+		return -1
+	}
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		payload = strings.TrimRight(strings.Join(strings.Fields(payload), ""), "=")
+		return len(payload) * 3 / 4
+	}
+	// Each %XX escape decodes to a single byte.
+	return len(payload) - 2*strings.Count(payload, "%")
+}
+
+// HTM-035: a body with script but no text or static media renders blank
+// when the reading system has scripting disabled. A <noscript> fallback
+// with content counts as static content.
+func checkScriptOnlyBody(data []byte, location string, r *report.Report) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	inBody := false
+	scriptDepth := 0
+	hasScript := false
+	hasContent := false
+
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "body":
+				inBody = true
+			case !inBody:
+			case t.Name.Local == "script":
+				// Data blocks such as JSON-LD render nothing either way.
+				hasScript = hasScript || isExecutableScript(t)
+				scriptDepth++
+			case staticContentElements[t.Name.Local]:
+				hasContent = true
+			}
+		case xml.EndElement:
+			if t.Name.Local == "script" && scriptDepth > 0 {
+				scriptDepth--
+			}
+			if t.Name.Local == "body" {
+				inBody = false
+			}
+		case xml.CharData:
+			if inBody && scriptDepth == 0 && strings.TrimSpace(string(t)) != "" {
+				hasContent = true
+			}
+		}
+	}
+
+	if hasScript && !hasContent {
+		r.AddWithLocation(report.Warning, "HTM-035",
+			"Content document body has only script-generated content and will render blank when scripting is disabled; add a static fallback",
+			location)
+	}
+}
+
+// HTM-034: SVG content documents referenced from the spine must have an
+// svg root element in the SVG namespace.
+func checkSVGContentRoot(ep *epub.EPUB, item epub.ManifestItem, r *report.Report) {
+	fullPath := ep.ResolveHref(item.Href)
+	data, err := ep.ReadFile(fullPath)
+	if err != nil {
+		return
+	}
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if se.Name.Local != "svg" || se.Name.Space != "http://www.w3.org/2000/svg" {
+			root := se.Name.Local
+			if se.Name.Space != "" {
+				root = fmt.Sprintf("%s (namespace '%s')", se.Name.Local, se.Name.Space)
+			}
+			r.AddWithLocation(report.Error, "HTM-034",
+				fmt.Sprintf("SVG content document must have an 'svg' root element in the SVG namespace, but found '%s'", root),
+				fullPath)
+		}
+		return
+	}
+}
+
+// spineSVGDocuments returns the SVG manifest items referenced from the
+// spine, in spine order.
+func spineSVGDocuments(ep *epub.EPUB) []epub.ManifestItem {
+	manifestByID := make(map[string]epub.ManifestItem)
+	for _, item := range ep.Package.Manifest {
+		manifestByID[item.ID] = item
+	}
+	var svgDocs []epub.ManifestItem
+	for _, ref := range ep.Package.Spine {
+		item, ok := manifestByID[ref.IDRef]
+		if !ok || item.MediaType != "image/svg+xml" || item.Href == "\x00MISSING" {
+			continue
+		}
+		svgDocs = append(svgDocs, item)
+	}
+	return svgDocs
+}
+
 // HTM-022: object data references must exist
-func checkObjectReferences(ep *epub.EPUB, data []byte, fullPath string, r *report.Report) {
+func checkObjectReferences(ep *epub.EPUB, data []byte, fullPath, itemDir string, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
-	itemDir := path.Dir(fullPath)
 
 	for {
 		tok, err := decoder.Token()
@@ -824,7 +1205,7 @@ func checkEmptyHrefAttributes(data []byte, location string, r *report.Report) {
 }
 
 // HTM-009: base element should not be used in EPUB content documents
-func checkNoBaseElement(data []byte, location string, r *report.Report) {
+func checkNoBaseElement(data []byte, location, baseDir string, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
 	for {
 		tok, err := decoder.Token()
@@ -833,15 +1214,93 @@ func checkNoBaseElement(data []byte, location string, r *report.Report) {
 		}
 		if se, ok := tok.(xml.StartElement); ok {
 			if se.Name.Local == "base" {
-				r.AddWithLocation(report.Warning, "HTM-009",
-					"The 'base' element is not allowed in EPUB content documents",
-					location)
+				msg := "The 'base' element is not allowed in EPUB content documents"
+				if effect := baseHrefEffect(data, location, baseDir); effect != "" {
+					msg += "; " + effect
+				}
+				r.AddWithLocation(report.Warning, "HTM-009", msg, location)
 				return
 			}
 		}
 	}
 }
 
+// baseHref returns the href of the document's <base> element, if any.
+func baseHref(data []byte) (string, bool) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return "", false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "body" {
+				return "", false
+			}
+			if t.Name.Local == "base" {
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "href" {
+						return attr.Value, true
+					}
+				}
+			}
+		}
+	}
+}
+
+// contentBaseDir returns the directory relative references in a content
+// document resolve against: the document's own directory, or the directory
+// named by a relative <base href> if one is declared. Remote or absolute
+// base URLs are ignored here and described by HTM-009.
+func contentBaseDir(data []byte, fullPath string) string {
+	dir := path.Dir(fullPath)
+	href, ok := baseHref(data)
+	if !ok || href == "" {
+		return dir
+	}
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(u.Path, "/") {
+		return dir
+	}
+	base := resolvePath(dir, u.Path)
+	if !strings.HasSuffix(u.Path, "/") {
+		base = path.Dir(base)
+	}
+	return base
+}
+
+// baseHrefEffect describes how the document's <base href> changes what
+// every relative reference points to: a remote or absolute base takes
+// them out of the publication entirely. It returns "" if the base leaves
+// references resolving against the document's own directory.
+func baseHrefEffect(data []byte, location, baseDir string) string {
+	href, ok := baseHref(data)
+	if !ok || href == "" {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err == nil && (u.Scheme != "" || u.Host != "" || strings.HasPrefix(u.Path, "/")) {
+		return fmt.Sprintf("base URL '%s' points outside the publication, so relative references in this document will not resolve to its resources", href)
+	}
+	if baseDir != path.Dir(location) {
+		return fmt.Sprintf("base URL '%s' makes relative references resolve against '%s' instead of the document's directory", href, baseDir)
+	}
+	return ""
+}
+
+// lineColumn converts a byte offset in data to a 1-based line and column.
+// Columns count bytes, as xml.Decoder.InputPos does.
+func lineColumn(data []byte, offset int) (line, column int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
 // HTM-010: EPUB 3 content documents must use HTML5 DOCTYPE or no DOCTYPE.
 // Returns true if a non-HTML5 DOCTYPE was detected (to skip HTM-011 which overlaps).
 func checkDoctypeHTML5(data []byte, location string, r *report.Report) bool {
@@ -858,9 +1317,10 @@ func checkDoctypeHTML5(data []byte, location string, r *report.Report) bool {
 	// HTML5 DOCTYPE is just <!DOCTYPE html> (case-insensitive, optionally with system)
 	// If it contains XHTML DTD identifiers, it's wrong
 	if strings.Contains(doctype, "XHTML") || strings.Contains(doctype, "DTD") {
-		r.AddWithLocation(report.Error, "HTM-010",
+		line, col := lineColumn(data, idx)
+		r.AddWithPosition(report.Error, "HTM-010",
 			"Irregular DOCTYPE: EPUB 3 content documents must use the HTML5 DOCTYPE (<!DOCTYPE html>) or no DOCTYPE",
-			location)
+			location, line, col)
 		return true
 	}
 	return false
@@ -979,9 +1439,8 @@ func checkNoPositionAbsolute(data []byte, location string, r *report.Report) {
 }
 
 // HTM-023: links must not escape the container via parent directory traversal
-func checkNoParentDirLinks(ep *epub.EPUB, data []byte, fullPath string, r *report.Report) {
+func checkNoParentDirLinks(ep *epub.EPUB, data []byte, fullPath, itemDir string, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
-	itemDir := path.Dir(fullPath)
 
 	for {
 		tok, err := decoder.Token()
@@ -1041,9 +1500,8 @@ func checkContentHasHead(data []byte, location string, r *report.Report) {
 }
 
 // HTM-025: embed element src must reference existing resource
-func checkEmbedReferences(ep *epub.EPUB, data []byte, location string, r *report.Report) {
+func checkEmbedReferences(ep *epub.EPUB, data []byte, location, contentDir string, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
-	contentDir := path.Dir(location)
 	for {
 		tok, err := decoder.Token()
 		if err != nil {
@@ -1106,9 +1564,8 @@ func checkLangXMLLangMatch(data []byte, location string, r *report.Report) {
 }
 
 // HTM-027: video poster attribute must reference existing resource
-func checkVideoPosterExists(ep *epub.EPUB, data []byte, location string, r *report.Report) {
+func checkVideoPosterExists(ep *epub.EPUB, data []byte, location, contentDir string, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
-	contentDir := path.Dir(location)
 	for {
 		tok, err := decoder.Token()
 		if err != nil {
@@ -1138,9 +1595,8 @@ func checkVideoPosterExists(ep *epub.EPUB, data []byte, location string, r *repo
 }
 
 // HTM-028: audio src must reference existing resource
-func checkAudioSrcExists(ep *epub.EPUB, data []byte, location string, r *report.Report) {
+func checkAudioSrcExists(ep *epub.EPUB, data []byte, location, contentDir string, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
-	contentDir := path.Dir(location)
 	for {
 		tok, err := decoder.Token()
 		if err != nil {
@@ -1281,3 +1737,72 @@ func checkNoRDFElements(data []byte, location string, r *report.Report) {
 		}
 	}
 }
+
+// HTM-038: each epub:switch must have an epub:default fallback. HTM-042: a
+// switch without any epub:case only ever shows its fallback. HTM-043:
+// epub:switch is deprecated in EPUB 3.1+. OPF-077: the manifest 'switch'
+// property should only be declared on documents that contain an epub:switch.
+func checkEpubSwitch(data []byte, location string, item epub.ManifestItem, r *report.Report) {
+	const opsNS = "http://www.idpf.org/2007/ops"
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+
+	type switchState struct {
+		hasCase    bool
+		hasDefault bool
+	}
+	var open []*switchState
+	switches := 0
+
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != opsNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "switch":
+				switches++
+				open = append(open, &switchState{})
+			case "case":
+				if len(open) > 0 {
+					open[len(open)-1].hasCase = true
+				}
+			case "default":
+				if len(open) > 0 {
+					open[len(open)-1].hasDefault = true
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Space != opsNS || t.Name.Local != "switch" || len(open) == 0 {
+				continue
+			}
+			sw := open[len(open)-1]
+			open = open[:len(open)-1]
+			if !sw.hasDefault {
+				r.AddWithLocation(report.Error, "HTM-038",
+					"The epub:switch element must contain an epub:default fallback",
+					location)
+			}
+			if !sw.hasCase {
+				r.AddWithLocation(report.Warning, "HTM-042",
+					"The epub:switch element has no epub:case children",
+					location)
+			}
+		}
+	}
+
+	if switches > 0 {
+		r.AddWithLocation(report.Warning, "HTM-043",
+			fmt.Sprintf("The epub:switch element is deprecated (%d found); use HTML fallbacks instead", switches),
+			location)
+	}
+	if switches == 0 && hasProperty(item.Properties, "switch") {
+		r.AddWithLocation(report.Warning, "OPF-077",
+			fmt.Sprintf("Manifest item '%s' declares the 'switch' property but contains no epub:switch element", item.Href),
+			location)
+	}
+}
@@ -1,8 +1,10 @@
 package validate
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/adammathes/epubverify/pkg/epub"
 	"github.com/adammathes/epubverify/pkg/report"
 )
 
@@ -70,3 +72,400 @@ func TestCheckEpubTypeValid_InvalidEpubType(t *testing.T) {
 		t.Error("invalid epub:type value should trigger HTM-015")
 	}
 }
+
+func TestCheckEpubSwitch(t *testing.T) {
+	xhtml := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>Test</title></head>
+<body>
+  <epub:switch id="s1">
+    <epub:case required-namespace="http://www.w3.org/1998/Math/MathML"><p>math</p></epub:case>
+  </epub:switch>
+</body>
+</html>`
+
+	r := report.NewReport()
+	checkEpubSwitch([]byte(xhtml), "test.xhtml", epub.ManifestItem{Href: "test.xhtml", Properties: "switch"}, r)
+
+	var ids []string
+	for _, m := range r.Messages {
+		ids = append(ids, m.CheckID)
+	}
+	if got := strings.Join(ids, " "); got != "HTM-038 HTM-043" {
+		t.Errorf("got %v, want a missing-default HTM-038 and a deprecation HTM-043", r.Messages)
+	}
+	if r.Messages[0].Severity != report.Error {
+		t.Errorf("HTM-038 severity = %s, want ERROR", r.Messages[0].Severity)
+	}
+
+	noCase := strings.Replace(xhtml, `<epub:case required-namespace="http://www.w3.org/1998/Math/MathML"><p>math</p></epub:case>`, `<epub:default><p>text</p></epub:default>`, 1)
+	r = report.NewReport()
+	checkEpubSwitch([]byte(noCase), "test.xhtml", epub.ManifestItem{Href: "test.xhtml", Properties: "switch"}, r)
+	if len(r.Messages) != 2 || r.Messages[0].CheckID != "HTM-042" || r.Messages[1].CheckID != "HTM-043" {
+		t.Errorf("expected HTM-042 for a switch without epub:case, then HTM-043, got %v", r.Messages)
+	}
+
+	plain := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Test</title></head><body><p>Hi</p></body></html>`
+	r = report.NewReport()
+	checkEpubSwitch([]byte(plain), "test.xhtml", epub.ManifestItem{Href: "test.xhtml", Properties: "switch"}, r)
+	if len(r.Messages) != 1 || r.Messages[0].CheckID != "OPF-077" {
+		t.Errorf("expected only OPF-077 for an unused switch property, got %v", r.Messages)
+	}
+}
+
+func TestCheckHTMLRootNamespace(t *testing.T) {
+	r := report.NewReport()
+	checkHTMLRootNamespace([]byte(`<?xml version="1.0"?><html><head><title>T</title></head><body/></html>`), "test.xhtml", r)
+	if len(r.Messages) != 1 || r.Messages[0].CheckID != "HTM-034" {
+		t.Errorf("expected HTM-034 for html without namespace, got %v", r.Messages)
+	}
+
+	r = report.NewReport()
+	checkHTMLRootNamespace([]byte(`<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><body/></html>`), "test.xhtml", r)
+	if len(r.Messages) != 0 {
+		t.Errorf("expected no messages for namespaced html, got %v", r.Messages)
+	}
+}
+
+func TestSVGContentRoot(t *testing.T) {
+	opf := strings.Replace(testOPF(`    <item id="page" href="page.svg" media-type="image/svg+xml"/>
+`), `<itemref idref="ch1"/>`, `<itemref idref="ch1"/>
+    <itemref idref="page"/>`, 1)
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": opf,
+		"OEBPS/page.svg":    `<?xml version="1.0"?><div xmlns="http://www.w3.org/1999/xhtml">not svg</div>`,
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "HTM-034") {
+		t.Error("expected HTM-034 for a div-rooted SVG content document")
+	}
+}
+
+func TestCheckScriptOnlyBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"script only", `<body><div id="app"></div><script src="app.js"></script></body>`, true},
+		{"inline script only", `<body><script>document.write("hi")</script></body>`, true},
+		{"text fallback", `<body><p>Static text</p><script src="app.js"></script></body>`, false},
+		{"noscript fallback", `<body><script src="app.js"></script><noscript><p>Enable scripts</p></noscript></body>`, false},
+		{"image fallback", `<body><img src="a.png" alt=""/><script src="app.js"></script></body>`, false},
+		{"no script", `<body><div></div></body>`, false},
+		{"data block only", `<body><script type="application/ld+json">{"@type": "Book"}</script></body>`, false},
+	}
+	for _, tt := range tests {
+		xhtml := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>T</title><script src="head.js"></script></head>` + tt.body + `</html>`
+		r := report.NewReport()
+		checkScriptOnlyBody([]byte(xhtml), "test.xhtml", r)
+		if got := len(r.Messages) > 0; got != tt.want {
+			t.Errorf("%s: HTM-035 = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScriptingKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"none", `<p>Static</p>`, ""},
+		{"script", `<script src="app.js"></script>`, "script"},
+		{"module", `<script type="module">go()</script>`, "script"},
+		{"data block", `<script type="application/ld+json">{}</script>`, ""},
+		{"handler", `<p onclick="go()">Tap</p>`, "event handler"},
+		{"javascript URL", `<a href=" JavaScript:go()">Go</a>`, "javascript: URL"},
+		{"all", `<a href="javascript:go()" onmouseover="x()">Go</a><script>x()</script>`, "javascript: URL, event handler, script"},
+		{"not a handler", `<details open="open"><summary>S</summary></details>`, ""},
+	}
+	for _, tt := range tests {
+		xhtml := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>T</title></head><body>` + tt.body + `</body></html>`
+		if got := strings.Join(ScriptingKinds([]byte(xhtml)), ", "); got != tt.want {
+			t.Errorf("%s: ScriptingKinds = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScriptedPropertyDeclarations(t *testing.T) {
+	tests := []struct {
+		name  string
+		props string
+		body  string
+		want  string
+	}{
+		{"handler without property", "", `<p onclick="go()">Tap</p>`, "HTM-005"},
+		{"javascript URL without property", "", `<a href="javascript:go()">Go</a>`, "HTM-005"},
+		{"script with property", "scripted", `<script>go()</script>`, ""},
+		{"form with property", "scripted", `<form action="x"><input type="text"/></form>`, ""},
+		{"property without script", "scripted", `<p>Static</p>`, "HTM-041"},
+		{"neither", "", `<p>Static</p>`, ""},
+		{"data block without property", "", `<script type="application/ld+json">{"@type": "Book"}</script>`, ""},
+		{"property with only a data block", "scripted", `<script type="application/ld+json">{"@type": "Book"}</script>`, "HTM-041"},
+	}
+	for _, tt := range tests {
+		xhtml := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>T</title></head><body>` + tt.body + `</body></html>`
+		r := report.NewReport()
+		checkPropertyDeclarations(nil, []byte(xhtml), "test.xhtml", epub.ManifestItem{ID: "c", Properties: tt.props}, r)
+		var got string
+		if len(r.Messages) > 0 {
+			got = r.Messages[0].CheckID
+		}
+		if got != tt.want || len(r.Messages) > 1 {
+			t.Errorf("%s: got %v, want %q", tt.name, r.Messages, tt.want)
+		}
+	}
+}
+
+func TestScriptedContent(t *testing.T) {
+	chapter := strings.Replace(testChapterXHTML, "</body>", `<button onclick="flip()">Flip</button></body>`, 1)
+	path := writeTestEPUB(t, map[string]string{"OEBPS/chapter1.xhtml": chapter})
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, m := range r.Messages {
+		if m.CheckID == "HTM-039" {
+			found = true
+			if m.Severity != report.Info || !strings.Contains(m.Message, "chapter1.xhtml (event handler)") {
+				t.Errorf("HTM-039 = %s", m)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected HTM-039 for an inline event handler, got %v", r.Messages)
+	}
+
+	r, err = Validate(writeTestEPUB(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "HTM-039") {
+		t.Error("a book without script should not report HTM-039")
+	}
+}
+
+func TestCheckNoBaseElement(t *testing.T) {
+	tests := []struct {
+		name   string
+		head   string
+		want   bool
+		effect string
+	}{
+		{"no base", ``, false, ""},
+		{"base to own directory", `<base href="./"/>`, true, ""},
+		{"base to subdirectory", `<base href="images/"/>`, true, "instead of the document's directory"},
+		{"remote base", `<base href="https://example.com/book/"/>`, true, "points outside the publication"},
+	}
+	for _, tt := range tests {
+		xhtml := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>T</title>` + tt.head + `</head><body><p>x</p></body></html>`
+		r := report.NewReport()
+		data := []byte(xhtml)
+		checkNoBaseElement(data, "OEBPS/ch1.xhtml", contentBaseDir(data, "OEBPS/ch1.xhtml"), r)
+		if got := hasCheck(r, "HTM-009"); got != tt.want || len(r.Messages) > 1 {
+			t.Errorf("%s: messages = %v, want HTM-009 %v", tt.name, r.Messages, tt.want)
+		}
+		if !tt.want || len(r.Messages) == 0 {
+			continue
+		}
+		msg := r.Messages[0].Message
+		if tt.effect == "" && strings.Contains(msg, ";") || !strings.Contains(msg, tt.effect) {
+			t.Errorf("%s: HTM-009 message = %q, want detail %q", tt.name, msg, tt.effect)
+		}
+	}
+}
+
+func TestContentReferencesResolveAgainstBase(t *testing.T) {
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>T</title><base href="images/"/></head>
+<body><p><img src="pic.png" alt="pic"/></p></body></html>`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf":    testOPF(`    <item id="pic" href="images/pic.png" media-type="image/png"/>` + "\n"),
+		"OEBPS/chapter1.xhtml": chapter,
+		"OEBPS/images/pic.png": "\x89PNG\r\n\x1a\n",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "RSC-001") {
+		t.Error("image should resolve against the declared base, got RSC-001")
+	}
+	var base []string
+	for _, m := range r.Messages {
+		if m.CheckID == "HTM-009" || m.CheckID == "HTM-036" {
+			base = append(base, m.CheckID)
+		}
+	}
+	if len(base) != 1 || base[0] != "HTM-009" {
+		t.Errorf("expected a single HTM-009 for the base element, got %v", base)
+	}
+}
+
+func TestDoctypePosition(t *testing.T) {
+	xhtml := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.1//EN" "http://www.w3.org/TR/xhtml11/DTD/xhtml11.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>T</title></head><body/></html>`
+	r := report.NewReport()
+	if !checkDoctypeHTML5([]byte(xhtml), "OEBPS/ch1.xhtml", r) {
+		t.Fatal("expected HTM-010 for an XHTML 1.1 DOCTYPE")
+	}
+	if m := r.Messages[0]; m.Line != 2 || m.Column != 1 {
+		t.Errorf("HTM-010 at line %d column %d, want 2:1", m.Line, m.Column)
+	}
+}
+
+func TestBrokenInternalLinks(t *testing.T) {
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter 1</title></head>
+<body>
+<p id="top"><a href="#top">Top</a> <a href="chapter2.xhtml#sec1">Ok</a></p>
+<p><a href="chapter2.xhtml#sec2">Dead anchor</a> <a href="chapter5.xhtml">Dead file</a></p>
+<p><a href="https://example.com/#nowhere">Web</a> <a href="mailto:a@example.com">Mail</a></p>
+<img src="map.png" alt="Map" usemap="#m"/>
+<map name="m"><area shape="rect" coords="0,0,10,10" href="#missing" alt="Area"/></map>
+</body>
+</html>`
+	chapter2 := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter 2</title></head>
+<body><h1 id="sec1">One</h1></body>
+</html>`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="ch2" href="chapter2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="map" href="map.png" media-type="image/png"/>
+`),
+		"OEBPS/chapter1.xhtml": chapter,
+		"OEBPS/chapter2.xhtml": chapter2,
+		"OEBPS/map.png":        "\x89PNG\r\n\x1a\n",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "RSC-003" || m.CheckID == "HTM-008" {
+			got = append(got, m.CheckID+": "+m.Message)
+		}
+	}
+	want := []string{"chapter2.xhtml#sec2", "'#missing'", "chapter5.xhtml"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d broken link messages, want %d: %q", len(got), len(want), got)
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if strings.Contains(g, w) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no broken link message mentions %s: %q", w, got)
+		}
+	}
+}
+
+func TestSVGImageReferences(t *testing.T) {
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter 1</title></head>
+<body>
+<img src="images/fig3.png" alt="Figure 3"/>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10">
+  <image xlink:href="images/cover.png" width="10" height="10"/>
+  <image href="images/missing.jpg" width="10" height="10"/>
+  <image xlink:href="data:image/png;base64,iVBORw0KGgo=" width="1" height="1"/>
+  <image href="https://example.com/remote.png" width="1" height="1"/>
+</svg>
+</body>
+</html>`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": strings.Replace(testOPF(`    <item id="cover" href="images/cover.png" media-type="image/png"/>
+`), `<item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>`,
+			`<item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml" properties="svg remote-resources"/>`, 1),
+		"OEBPS/chapter1.xhtml":   chapter,
+		"OEBPS/images/cover.png": "\x89PNG\r\n\x1a\n",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "RSC-007" {
+			got = append(got, m.Message)
+		}
+	}
+	if len(got) != 2 || !strings.Contains(got[0], "images/fig3.png") || !strings.Contains(got[1], "images/missing.jpg") {
+		t.Errorf("expected RSC-007 for fig3.png and missing.jpg only, got %q", got)
+	}
+}
+
+func TestLargeDataURIs(t *testing.T) {
+	payload := strings.Repeat("AAAA", 2048) // 6 KB decoded
+	chapter := strings.Replace(testChapterXHTML, "</body>", `<img src="data:image/png;base64,`+payload+`" alt="x"/></body>`, 1)
+	path := writeTestEPUB(t, map[string]string{"OEBPS/chapter1.xhtml": chapter})
+
+	r, err := ValidateWithOptions(path, Options{MaxDataURIBytes: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, m := range r.Messages {
+		if m.CheckID == "HTM-040" {
+			found = true
+			if m.Severity != report.Warning || !strings.Contains(m.Message, "'img'") || !strings.Contains(m.Message, "6 KB") {
+				t.Errorf("HTM-040 = %s", m)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected HTM-040 for a 6 KB data: URI over a 4 KB limit, got %v", r.Messages)
+	}
+
+	r, err = Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "HTM-040") {
+		t.Error("a 6 KB data: URI is under the default limit and should not report HTM-040")
+	}
+}
+
+func TestDataURISize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"images/a.png", -1},
+		{"data:image/png;base64,AAAA", 3},
+		{"DATA:image/png;base64,AAA=", 2},
+		{"data:text/plain,a%20b", 3},
+		{"data:nocomma", -1},
+	}
+	for _, tt := range tests {
+		if got := dataURISize(tt.value); got != tt.want {
+			t.Errorf("dataURISize(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -12,7 +12,7 @@ import (
 )
 
 // checkCSS validates CSS files referenced in the manifest.
-func checkCSS(ep *epub.EPUB, r *report.Report) {
+func checkCSS(ep *epub.EPUB, r *report.Report, opts Options) {
 	if ep.Package == nil {
 		return
 	}
@@ -62,6 +62,24 @@ func checkCSS(ep *epub.EPUB, r *report.Report) {
 
 		// CSS-008: CSS-referenced resources must be in manifest
 		checkCSSResourceInManifest(ep, cssContent, fullPath, manifestHrefs, r)
+
+		// CSS-010: other url() references (list-style-image, border-image, etc.) must exist
+		checkCSSOtherURLsExist(ep, cssContent, fullPath, r)
+
+		// CSS-020: discouraged declarations in reflowable books (strict only)
+		if opts.Strict && ep.Package.RenditionLayout != "pre-paginated" {
+			discouraged := opts.DiscouragedCSS
+			if discouraged == nil {
+				discouraged = defaultDiscouragedCSS
+			}
+			checkCSSDiscouraged(cssContent, fullPath, discouraged, r)
+		}
+	}
+
+	// FONT-003: embedded fonts should be referenced by an @font-face rule
+	// (quality or strict only; epubcheck does not report it)
+	if opts.Quality || opts.Strict {
+		checkFontsReferenced(ep, r)
 	}
 }
 
@@ -196,6 +214,48 @@ func checkCSSValidProperties(css string, location string, r *report.Report) {
 	}
 }
 
+// defaultDiscouragedCSS lists declarations that reflowable reading
+// systems ignore or mishandle: fixed positioning, and large negative
+// text-indent used to hide text off screen.
+var defaultDiscouragedCSS = []string{
+	"position: fixed",
+	"text-indent: -999",
+}
+
+// CSS-020: declarations matching an entry in discouraged, as described
+// for Options.DiscouragedCSS, are reported with their selector.
+func checkCSSDiscouraged(css string, location string, discouraged []string, r *report.Report) {
+	commentRe := regexp.MustCompile(`/\*[\s\S]*?\*/`)
+	css = commentRe.ReplaceAllString(css, "")
+	ruleRe := regexp.MustCompile(`([^{}]+)\{([^{}]*)\}`)
+
+	for _, rule := range ruleRe.FindAllStringSubmatch(css, -1) {
+		selector := strings.Join(strings.Fields(rule[1]), " ")
+		if strings.HasPrefix(selector, "@") {
+			continue // @font-face, @page
+		}
+		for _, decl := range strings.Split(rule[2], ";") {
+			prop, value, ok := strings.Cut(decl, ":")
+			if !ok {
+				continue
+			}
+			prop = strings.ToLower(strings.TrimSpace(prop))
+			value = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important")))
+			for _, entry := range discouraged {
+				wantProp, wantValue, _ := strings.Cut(entry, ":")
+				if prop != strings.ToLower(strings.TrimSpace(wantProp)) ||
+					!strings.HasPrefix(value, strings.ToLower(strings.TrimSpace(wantValue))) {
+					continue
+				}
+				r.AddWithLocation(report.Warning, "CSS-020",
+					fmt.Sprintf("'%s: %s' in '%s' is discouraged in reflowable EPUBs", prop, value, selector),
+					location)
+				break
+			}
+		}
+	}
+}
+
 // CSS-003: @font-face rules must include a src descriptor
 func checkCSSFontFaceHasSrc(css string, location string, r *report.Report) {
 	fontFaceRe := regexp.MustCompile(`@font-face\s*\{([^}]*)\}`)
@@ -274,7 +334,8 @@ func checkCSSRemoteFonts(ep *epub.EPUB, css string, location string, item epub.M
 	}
 }
 
-// CSS-006: font file sources must exist
+// CSS-006: font file sources must exist. local() sources name installed
+// fonts and data: URIs embed the font, so neither refers to a file.
 func checkCSSFontFileExists(ep *epub.EPUB, css string, location string, r *report.Report) {
 	fontFaceRe := regexp.MustCompile(`@font-face\s*\{([^}]*)\}`)
 	urlRe := regexp.MustCompile(`url\(['"]?([^'")\s]+)['"]?\)`)
@@ -289,8 +350,11 @@ func checkCSSFontFileExists(ep *epub.EPUB, css string, location string, r *repor
 			if isRemoteURL(href) {
 				continue // Handled by CSS-004
 			}
+			if strings.HasPrefix(href, "data:") {
+				continue
+			}
 			parsed, err := url.Parse(href)
-			if err != nil {
+			if err != nil || parsed.Path == "" {
 				continue
 			}
 			target := resolvePath(cssDir, parsed.Path)
@@ -327,6 +391,46 @@ func checkCSSBackgroundImageExists(ep *epub.EPUB, css string, location string, r
 	}
 }
 
+// CSS-010: url() references not covered by CSS-006 (@font-face) or CSS-007
+// (background) must resolve to a file in the container. This catches
+// list-style-image, border-image, cursor, content and similar properties.
+func checkCSSOtherURLsExist(ep *epub.EPUB, css string, location string, r *report.Report) {
+	fontFaceRe := regexp.MustCompile(`@font-face\s*\{([^}]*)\}`)
+	bgRe := regexp.MustCompile(`background(?:-image)?\s*:\s*url\(['"]?([^'")\s]+)['"]?\)`)
+	urlRe := regexp.MustCompile(`url\(['"]?([^'")\s]+)['"]?\)`)
+	cssDir := path.Dir(location)
+
+	covered := append(fontFaceRe.FindAllStringIndex(css, -1), bgRe.FindAllStringIndex(css, -1)...)
+	isCovered := func(pos int) bool {
+		for _, c := range covered {
+			if pos >= c[0] && pos < c[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, m := range urlRe.FindAllStringSubmatchIndex(css, -1) {
+		if isCovered(m[0]) {
+			continue
+		}
+		href := css[m[2]:m[3]]
+		if isRemoteURL(href) || strings.HasPrefix(href, "data:") || strings.HasPrefix(href, "#") {
+			continue
+		}
+		parsed, err := url.Parse(href)
+		if err != nil || parsed.Path == "" {
+			continue
+		}
+		target := resolvePath(cssDir, parsed.Path)
+		if _, exists := ep.Files[target]; !exists {
+			r.AddWithLocation(report.Warning, "CSS-010",
+				fmt.Sprintf("Referenced resource '%s' (resolved to '%s') could not be found in the container", href, target),
+				location)
+		}
+	}
+}
+
 // CSS-008: CSS-referenced resources must be declared in the OPF manifest
 func checkCSSResourceInManifest(ep *epub.EPUB, css string, location string, manifestHrefs map[string]bool, r *report.Report) {
 	bgRe := regexp.MustCompile(`url\(['"]?([^'")\s]+)['"]?\)`)
@@ -335,11 +439,11 @@ func checkCSSResourceInManifest(ep *epub.EPUB, css string, location string, mani
 	matches := bgRe.FindAllStringSubmatch(css, -1)
 	for _, match := range matches {
 		href := match[1]
-		if isRemoteURL(href) {
+		if isRemoteURL(href) || strings.HasPrefix(href, "data:") {
 			continue
 		}
 		parsed, err := url.Parse(href)
-		if err != nil {
+		if err != nil || parsed.Path == "" {
 			continue
 		}
 		target := resolvePath(cssDir, parsed.Path)
@@ -352,3 +456,53 @@ func checkCSSResourceInManifest(ep *epub.EPUB, css string, location string, mani
 		}
 	}
 }
+
+// fontFaceBlockRe matches an @font-face rule, capturing its declarations.
+var fontFaceBlockRe = regexp.MustCompile(`@font-face\s*\{([^}]*)\}`)
+
+// FONT-003: manifest font items should be referenced by at least one
+// @font-face src, either in a stylesheet or an inline style element.
+func checkFontsReferenced(ep *epub.EPUB, r *report.Report) {
+	referenced := make(map[string]bool)
+	for _, item := range ep.Package.Manifest {
+		if item.Href == "\x00MISSING" {
+			continue
+		}
+		if item.MediaType != "text/css" && item.MediaType != "application/xhtml+xml" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		dir := path.Dir(fullPath)
+		for _, match := range fontFaceBlockRe.FindAllStringSubmatch(string(data), -1) {
+			for _, u := range cssURLRe.FindAllStringSubmatch(match[1], -1) {
+				if isRemoteURL(u[1]) {
+					continue
+				}
+				parsed, err := url.Parse(u[1])
+				if err != nil {
+					continue
+				}
+				referenced[resolvePath(dir, parsed.Path)] = true
+			}
+		}
+	}
+
+	for _, item := range ep.Package.Manifest {
+		if item.Href == "\x00MISSING" || !isFontMediaType(item.MediaType) {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		if _, exists := ep.Files[fullPath]; !exists {
+			continue // Missing file handled by RSC-009
+		}
+		if !referenced[fullPath] {
+			r.AddWithLocation(report.Warning, "FONT-003",
+				fmt.Sprintf("Font '%s' is declared in the manifest but not referenced by any @font-face rule", item.Href),
+				fullPath)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFontsReferenced(t *testing.T) {
+	css := `@font-face { font-family: "Used"; src: url(../fonts/used.otf); }
+body { font-family: "Used"; }`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="css" href="css/style.css" media-type="text/css"/>
+    <item id="used" href="fonts/used.otf" media-type="font/otf"/>
+    <item id="unused" href="fonts/unused.otf" media-type="font/otf"/>
+`),
+		"OEBPS/css/style.css":    css,
+		"OEBPS/fonts/used.otf":   "OTTO",
+		"OEBPS/fonts/unused.otf": "OTTO",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "FONT-003") {
+		t.Error("FONT-003 should only be reported with Quality or Strict")
+	}
+
+	r, err = ValidateWithOptions(path, Options{Quality: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flagged []string
+	for _, m := range r.Messages {
+		if m.CheckID == "FONT-003" {
+			flagged = append(flagged, m.Location)
+		}
+	}
+	if len(flagged) != 1 || flagged[0] != "OEBPS/fonts/unused.otf" {
+		t.Errorf("FONT-003 flagged %v, want only OEBPS/fonts/unused.otf", flagged)
+	}
+}
+
+func TestCSSOtherURLsExist(t *testing.T) {
+	css := `@font-face { font-family: "F"; src: url(../fonts/missing.otf); }
+body { background-image: url(../images/missing-bg.png); }
+ul { list-style-image: url(../images/bullet.png); }
+ol { list-style-image: url("../images/missing-bullet.png"); }
+p { border-image: url(data:image/png;base64,AAAA) 30; filter: url(#blur); }`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="css" href="css/style.css" media-type="text/css"/>
+    <item id="bullet" href="images/bullet.png" media-type="image/png"/>
+`),
+		"OEBPS/css/style.css":     css,
+		"OEBPS/images/bullet.png": "\x89PNG\r\n\x1a\n",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "FONT-003") {
+		t.Error("FONT-003 should only be reported with Quality or Strict")
+	}
+
+	r, err = ValidateWithOptions(path, Options{Quality: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flagged []string
+	for _, m := range r.Messages {
+		if m.CheckID == "CSS-010" {
+			flagged = append(flagged, m.Message)
+		}
+	}
+	if len(flagged) != 1 || !strings.Contains(flagged[0], "OEBPS/images/missing-bullet.png") {
+		t.Errorf("CSS-010 flagged %v, want only missing-bullet.png", flagged)
+	}
+}
+
+func TestCSSFontFaceSources(t *testing.T) {
+	css := `@font-face { font-family: "A"; src: local("A Regular"), url(../fonts/a.otf) format("opentype"); }
+@font-face { font-family: "B"; src: url("../fonts/missing.woff") format("woff"); }
+@font-face { font-family: "C"; src: url(data:font/woff2;base64,d09GMgABAAAAAA) format("woff2"); }
+@font-face { font-family: "D"; src: url(../fonts/unlisted.otf); }
+body { font-family: "A", "B", "C", "D"; }`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="css" href="css/style.css" media-type="text/css"/>
+    <item id="a" href="fonts/a.otf" media-type="font/otf"/>
+`),
+		"OEBPS/css/style.css":      css,
+		"OEBPS/fonts/a.otf":        "OTTO",
+		"OEBPS/fonts/unlisted.otf": "OTTO",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var missing, unlisted []string
+	for _, m := range r.Messages {
+		switch m.CheckID {
+		case "CSS-006":
+			missing = append(missing, m.Message)
+		case "CSS-008":
+			unlisted = append(unlisted, m.Message)
+		}
+	}
+	if len(missing) != 1 || !strings.Contains(missing[0], "missing.woff") {
+		t.Errorf("CSS-006 flagged %v, want only missing.woff", missing)
+	}
+	if len(unlisted) != 1 || !strings.Contains(unlisted[0], "unlisted.otf") {
+		t.Errorf("CSS-008 flagged %v, want only unlisted.otf", unlisted)
+	}
+}
+
+func TestCSSDiscouraged(t *testing.T) {
+	css := `/* header { position: fixed; } */
+.banner { position: fixed !important; top: 0; }
+.hanging { text-indent: -1em; }
+h1.logo { text-indent: -9999px; }
+@media screen { .note { POSITION: Fixed } }`
+	files := map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="css" href="css/style.css" media-type="text/css"/>
+`),
+		"OEBPS/css/style.css": css,
+	}
+
+	flagged := func(opts Options) []string {
+		t.Helper()
+		r, err := ValidateWithOptions(writeTestEPUB(t, files), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msgs []string
+		for _, m := range r.Messages {
+			if m.CheckID == "CSS-020" {
+				msgs = append(msgs, m.Message)
+			}
+		}
+		return msgs
+	}
+
+	if got := flagged(Options{}); len(got) != 0 {
+		t.Errorf("CSS-020 without Strict: %v", got)
+	}
+	got := flagged(Options{Strict: true})
+	want := []string{
+		"'position: fixed' in '.banner' is discouraged in reflowable EPUBs",
+		"'text-indent: -9999px' in 'h1.logo' is discouraged in reflowable EPUBs",
+		"'position: fixed' in '.note' is discouraged in reflowable EPUBs",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CSS-020 = %q, want %q", got, want)
+	}
+	got = flagged(Options{Strict: true, DiscouragedCSS: []string{"top"}})
+	if len(got) != 1 || !strings.Contains(got[0], "'top: 0'") {
+		t.Errorf("CSS-020 with DiscouragedCSS [top] = %q", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/adammathes/epubverify/pkg/epub"
 	"github.com/adammathes/epubverify/pkg/report"
@@ -14,6 +15,19 @@ import (
 var utf16LEBOM = []byte{0xff, 0xfe}
 var utf16BEBOM = []byte{0xfe, 0xff}
 
+// UTF-8 byte order mark
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// XML media types whose files may carry an XML declaration
+var xmlMediaTypes = map[string]bool{
+	"application/xhtml+xml":         true,
+	"image/svg+xml":                 true,
+	"application/x-dtbncx+xml":      true,
+	"application/smil+xml":          true,
+	"application/oebps-package+xml": true,
+	"application/pls+xml":           true,
+}
+
 // checkEncoding validates encoding of content documents.
 // Returns a set of full paths that have encoding errors (should be skipped by content checks).
 func checkEncoding(ep *epub.EPUB, r *report.Report) map[string]bool {
@@ -22,7 +36,8 @@ func checkEncoding(ep *epub.EPUB, r *report.Report) map[string]bool {
 		return badEncoding
 	}
 
-	xmlEncodingRe := regexp.MustCompile(`<\?xml[^?]*encoding=["']([^"']+)["']`)
+	// ENC-010: declared encoding must match the bytes
+	mismatched := checkEncodingDeclarations(ep, r)
 
 	for _, item := range ep.Package.Manifest {
 		if item.MediaType != "application/xhtml+xml" {
@@ -48,6 +63,10 @@ func checkEncoding(ep *epub.EPUB, r *report.Report) map[string]bool {
 
 		// ENC-001: check XML encoding declaration
 		// Look for encoding attribute in XML declaration
+		if mismatched[fullPath] {
+			badEncoding[fullPath] = true
+			continue // ENC-010 already describes the UTF-16 declaration
+		}
 		header := string(data[:min(200, len(data))])
 		if matches := xmlEncodingRe.FindStringSubmatch(header); len(matches) > 1 {
 			enc := strings.ToUpper(matches[1])
@@ -59,5 +78,218 @@ func checkEncoding(ep *epub.EPUB, r *report.Report) map[string]bool {
 			}
 		}
 	}
+
+	// XML-002: the XML declaration must be the first thing in the file
+	checkXMLDeclarationAtStart(ep, r)
+
+	// ENC-011: UTF-8 byte order marks in XML and CSS files
+	checkUTF8BOM(ep, r)
+
 	return badEncoding
 }
+
+// ENC-011: a UTF-8 byte order mark is allowed but unnecessary, and some
+// reading systems mishandle it at the start of the package document or
+// content. Only XML and CSS files are checked; binary resources such as
+// fonts and images are left alone.
+func checkUTF8BOM(ep *epub.EPUB, r *report.Report) {
+	paths := []string{"META-INF/container.xml"}
+	if ep.RootfilePath != "" {
+		paths = append(paths, ep.RootfilePath)
+	}
+	for _, item := range ep.Package.Manifest {
+		if item.Href == "\x00MISSING" || (!xmlMediaTypes[item.MediaType] && item.MediaType != "text/css") {
+			continue
+		}
+		paths = append(paths, ep.ResolveHref(item.Href))
+	}
+
+	seen := make(map[string]bool)
+	for _, fullPath := range paths {
+		if seen[fullPath] {
+			continue
+		}
+		seen[fullPath] = true
+		data, err := ep.ReadFile(fullPath)
+		if err != nil || !bytes.HasPrefix(data, utf8BOM) {
+			continue
+		}
+		r.AddWithLocation(report.Warning, "ENC-011",
+			"File starts with a UTF-8 byte order mark, which some reading systems mishandle; remove it",
+			fullPath)
+	}
+}
+
+// xmlEncodingRe matches the encoding attribute of an XML declaration.
+var xmlEncodingRe = regexp.MustCompile(`<\?xml[^?]*encoding=["']([^"']+)["']`)
+
+// ENC-010: the encoding named in the XML declaration must agree with the
+// encoding of the bytes: a UTF-16 byte order mark or zero-interleaved
+// "<?", a UTF-8 byte order mark, or otherwise UTF-8 if the bytes are valid
+// UTF-8. A missing declaration means UTF-8. Declarations of other
+// encodings are only checked against a byte order mark, since their bytes
+// cannot be told apart. Content documents with a UTF-16 byte order mark
+// or a non-UTF declaration are left to ENC-002 and ENC-001. Returns the
+// paths that were reported.
+func checkEncodingDeclarations(ep *epub.EPUB, r *report.Report) map[string]bool {
+	mismatched := make(map[string]bool)
+	paths := []string{"META-INF/container.xml"}
+	if ep.RootfilePath != "" {
+		paths = append(paths, ep.RootfilePath)
+	}
+	contentDocs := make(map[string]bool)
+	for _, item := range ep.Package.Manifest {
+		if item.Href == "\x00MISSING" || !xmlMediaTypes[item.MediaType] {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		paths = append(paths, fullPath)
+		if item.MediaType == "application/xhtml+xml" {
+			contentDocs[fullPath] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, fullPath := range paths {
+		if seen[fullPath] {
+			continue
+		}
+		seen[fullPath] = true
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		hasUTF16BOM := bytes.HasPrefix(data, utf16LEBOM) || bytes.HasPrefix(data, utf16BEBOM)
+		if contentDocs[fullPath] && hasUTF16BOM {
+			continue // ENC-002
+		}
+		declared, actual := declaredEncoding(data), sniffEncoding(data)
+		if !isUTFEncoding(declared) {
+			if contentDocs[fullPath] {
+				continue // ENC-001
+			}
+			if !hasUTF16BOM && !bytes.HasPrefix(data, utf8BOM) {
+				continue // an 8-bit encoding; only a byte order mark refutes it
+			}
+		} else if encodingFamily(declared) == actual {
+			continue
+		}
+		label := declared
+		if label == "" {
+			label = "none (UTF-8 by default)"
+		}
+		if actual == "" {
+			actual = "not valid UTF-8"
+		}
+		r.AddWithLocation(report.Error, "ENC-010",
+			fmt.Sprintf("Declared encoding %s does not match the file's actual encoding (%s)", label, actual),
+			fullPath)
+		mismatched[fullPath] = true
+	}
+	return mismatched
+}
+
+// declaredEncoding returns the encoding in the XML declaration, or "".
+// NUL bytes are dropped first so a UTF-16 declaration can be read too.
+func declaredEncoding(data []byte) string {
+	header := data[:min(400, len(data))]
+	header = bytes.ReplaceAll(header, []byte{0}, nil)
+	if m := xmlEncodingRe.FindSubmatch(header); m != nil {
+		return strings.TrimSpace(string(m[1]))
+	}
+	return ""
+}
+
+// sniffEncoding returns "UTF-16" or "UTF-8" as detected from the bytes,
+// or "" if they are neither.
+func sniffEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, utf16LEBOM), bytes.HasPrefix(data, utf16BEBOM),
+		bytes.HasPrefix(data, []byte{0, '<', 0, '?'}), bytes.HasPrefix(data, []byte{'<', 0, '?', 0}):
+		return "UTF-16"
+	case bytes.HasPrefix(data, utf8BOM), utf8.Valid(data):
+		return "UTF-8"
+	}
+	return ""
+}
+
+// encodingFamily maps a declared encoding name to "UTF-8" or "UTF-16",
+// treating a missing declaration as UTF-8. Other names are returned
+// upper-cased.
+func encodingFamily(name string) string {
+	name = strings.ToUpper(name)
+	switch {
+	case name == "" || name == "UTF-8" || name == "UTF8":
+		return "UTF-8"
+	case strings.HasPrefix(name, "UTF-16"):
+		return "UTF-16"
+	}
+	return name
+}
+
+// isUTFEncoding reports whether a declared encoding is UTF-8 or UTF-16,
+// including a missing declaration.
+func isUTFEncoding(name string) bool {
+	f := encodingFamily(name)
+	return f == "UTF-8" || f == "UTF-16"
+}
+
+// XML-002: whitespace or comments before <?xml make the document
+// not well-formed; an XML declaration is only allowed at offset 0
+// (after an optional BOM).
+func checkXMLDeclarationAtStart(ep *epub.EPUB, r *report.Report) {
+	paths := []string{"META-INF/container.xml"}
+	if ep.RootfilePath != "" {
+		paths = append(paths, ep.RootfilePath)
+	}
+	for _, item := range ep.Package.Manifest {
+		if item.Href == "\x00MISSING" || !xmlMediaTypes[item.MediaType] {
+			continue
+		}
+		paths = append(paths, ep.ResolveHref(item.Href))
+	}
+
+	seen := make(map[string]bool)
+	for _, fullPath := range paths {
+		if seen[fullPath] {
+			continue
+		}
+		seen[fullPath] = true
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		if leading := leadingBeforeXMLDeclaration(data); leading != "" {
+			if len(leading) > 40 {
+				leading = leading[:40] + "..."
+			}
+			r.AddWithLocation(report.Error, "XML-002",
+				fmt.Sprintf("XML declaration must be at the start of the file, but is preceded by %q", leading),
+				fullPath)
+		}
+	}
+}
+
+// leadingBeforeXMLDeclaration returns the whitespace and comments found
+// before an XML declaration, or "" if the declaration (if any) is first.
+func leadingBeforeXMLDeclaration(data []byte) string {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	rest := data
+	for {
+		trimmed := bytes.TrimLeft(rest, " \t\r\n")
+		if !bytes.HasPrefix(trimmed, []byte("<!--")) {
+			rest = trimmed
+			break
+		}
+		end := bytes.Index(trimmed, []byte("-->"))
+		if end < 0 {
+			return ""
+		}
+		rest = trimmed[end+3:]
+	}
+	if len(rest) == len(data) || !bytes.HasPrefix(rest, []byte("<?xml")) ||
+		len(rest) < 6 || !strings.ContainsRune(" \t\r\n", rune(rest[5])) {
+		return ""
+	}
+	return string(data[:len(data)-len(rest)])
+}
@@ -0,0 +1,132 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adammathes/epubverify/pkg/report"
+)
+
+func TestLeadingBeforeXMLDeclaration(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"declaration first", `<?xml version="1.0"?><html/>`, ""},
+		{"BOM then declaration", "\xef\xbb\xbf" + `<?xml version="1.0"?><html/>`, ""},
+		{"no declaration", "\n<html/>", ""},
+		{"leading newline", "\n" + `<?xml version="1.0"?><html/>`, "\n"},
+		{"BOM then spaces", "\xef\xbb\xbf  " + `<?xml version="1.0"?><html/>`, "  "},
+		{"leading comment", `<!-- generated --><?xml version="1.0"?><html/>`, "<!-- generated -->"},
+	}
+	for _, tt := range tests {
+		if got := leadingBeforeXMLDeclaration([]byte(tt.data)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckXMLDeclarationAtStart(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/chapter1.xhtml": "  " + testChapterXHTML,
+	})
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, m := range r.Messages {
+		if m.CheckID == "XML-002" {
+			found = true
+			if m.Location != "OEBPS/chapter1.xhtml" {
+				t.Errorf("XML-002 location = %q, want OEBPS/chapter1.xhtml", m.Location)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected XML-002 for whitespace before the XML declaration")
+	}
+}
+
+func TestEncodingDeclarationMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		chapter string
+		want    bool
+	}{
+		{"no declaration, UTF-8", strings.Replace(testChapterXHTML, `<?xml version="1.0" encoding="UTF-8"?>`, "", 1), false},
+		{"declared UTF-8", testChapterXHTML, false},
+		{"declared UTF-16, UTF-8 bytes", strings.Replace(testChapterXHTML, "UTF-8", "UTF-16", 1), true},
+		{"no declaration, Latin-1 bytes", strings.Replace(testChapterXHTML, `<?xml version="1.0" encoding="UTF-8"?>`, "", 1) + "<!-- caf\xe9 -->", true},
+	}
+	for _, tt := range tests {
+		path := writeTestEPUB(t, map[string]string{"OEBPS/chapter1.xhtml": tt.chapter})
+		r, err := Validate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasCheck(r, "ENC-010"); got != tt.want {
+			t.Errorf("%s: ENC-010 = %v, want %v", tt.name, got, tt.want)
+		}
+		if hasCheck(r, "ENC-001") {
+			t.Errorf("%s: unexpected ENC-001 alongside ENC-010", tt.name)
+		}
+	}
+}
+
+func TestEncodingDeclarationLeftToOtherChecks(t *testing.T) {
+	// Plain ASCII is valid ISO-8859-1, so only ENC-001 applies.
+	latin1 := strings.Replace(testChapterXHTML, "UTF-8", "ISO-8859-1", 1)
+	// A UTF-16 byte order mark is reported once, by ENC-002.
+	utf16 := []byte{0xff, 0xfe}
+	for _, c := range testChapterXHTML {
+		utf16 = append(utf16, byte(c), byte(c>>8))
+	}
+	tests := []struct {
+		name    string
+		chapter string
+		want    string
+	}{
+		{"ASCII declared ISO-8859-1", latin1, "ENC-001"},
+		{"UTF-16 byte order mark", string(utf16), "ENC-002"},
+	}
+	for _, tt := range tests {
+		path := writeTestEPUB(t, map[string]string{"OEBPS/chapter1.xhtml": tt.chapter})
+		r, err := Validate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, m := range r.Messages {
+			if strings.HasPrefix(m.CheckID, "ENC-") {
+				ids = append(ids, m.CheckID)
+			}
+		}
+		if len(ids) != 1 || ids[0] != tt.want {
+			t.Errorf("%s: encoding messages = %v, want [%s]", tt.name, ids, tt.want)
+		}
+	}
+}
+
+func TestCheckUTF8BOM(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/chapter1.xhtml": "\xef\xbb\xbf" + testChapterXHTML,
+	})
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locations []string
+	for _, m := range r.Messages {
+		if m.CheckID == "ENC-011" {
+			if m.Severity != report.Warning {
+				t.Errorf("ENC-011 severity = %s, want WARNING", m.Severity)
+			}
+			locations = append(locations, m.Location)
+		}
+	}
+	if len(locations) != 1 || locations[0] != "OEBPS/chapter1.xhtml" {
+		t.Errorf("ENC-011 locations = %v, want [OEBPS/chapter1.xhtml]", locations)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/adammathes/epubverify/pkg/epub"
@@ -79,6 +80,15 @@ func checkEPUB2(ep *epub.EPUB, r *report.Report) {
 
 	// E2-015: NCX depth metadata must match actual depth
 	checkNCXDepthValid(data, r)
+
+	// E2-016: playOrder values must be unique per target and sequential
+	checkNCXPlayOrder(data, r)
+
+	// E2-017: NCX docTitle should match dc:title
+	checkNCXDocTitle(ep, data, r)
+
+	// E2-018: navPoint content src must be a manifest item
+	checkNCXContentInManifest(ep, data, ncxFullPath, r)
 }
 
 // E2-004: EPUB 2 spine must have toc attribute
@@ -508,3 +518,151 @@ func checkNCXDepthValid(data []byte, r *report.Report) {
 			fmt.Sprintf("NCX declared depth '%s' does not match actual navigation depth '%d'", declaredDepth, actualDepth))
 	}
 }
+
+// E2-016: reading systems step through the NCX by playOrder, so each value
+// should name a single target and the values should run 1, 2, 3... with
+// no gaps. navPoints that point at the same content may share a value.
+func checkNCXPlayOrder(data []byte, r *report.Report) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	target := make(map[int]string) // playOrder -> src of its first navPoint
+	// open holds the playOrder of each enclosing point still waiting for
+	// its content element; 0 once it has one or if it has no playOrder.
+	var open []int
+
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "navPoint", "pageTarget", "navTarget":
+				n := 0
+				if value, ok := attrValue(t.Attr, "playOrder"); ok {
+					var err error
+					if n, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || n < 1 {
+						r.Add(report.Error, "E2-016",
+							fmt.Sprintf("NCX playOrder '%s' is not a positive integer", value))
+						n = 0
+					} else if _, seen := target[n]; !seen {
+						target[n] = ""
+					}
+				}
+				open = append(open, n)
+			case "content":
+				if len(open) == 0 || open[len(open)-1] == 0 {
+					continue
+				}
+				n := open[len(open)-1]
+				open[len(open)-1] = 0
+				src, _ := attrValue(t.Attr, "src")
+				src, _, _ = strings.Cut(src, "#")
+				if prev := target[n]; prev == "" {
+					target[n] = src
+				} else if prev != src {
+					r.Add(report.Warning, "E2-016",
+						fmt.Sprintf("NCX playOrder '%d' is used for different targets '%s' and '%s'", n, prev, src))
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "navPoint", "pageTarget", "navTarget":
+				if len(open) > 0 {
+					open = open[:len(open)-1]
+				}
+			}
+		}
+	}
+
+	for n := 1; n <= len(target); n++ {
+		if _, ok := target[n]; !ok {
+			r.Add(report.Warning, "E2-016",
+				fmt.Sprintf("NCX playOrder values are not sequential: '%d' is missing", n))
+			return
+		}
+	}
+}
+
+// attrValue returns the value of the attribute with the given local name.
+func attrValue(attrs []xml.Attr, local string) (string, bool) {
+	for _, a := range attrs {
+		if a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// E2-017: reading systems show either title, so a docTitle that differs
+// from dc:title is usually left over from another book or an old draft.
+func checkNCXDocTitle(ep *epub.EPUB, data []byte, r *report.Report) {
+	if len(ep.Package.Metadata.Titles) == 0 {
+		return // OPF-001
+	}
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	inDocTitle := false
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "docTitle" {
+				inDocTitle = true
+			}
+			if inDocTitle && t.Name.Local == "text" {
+				docTitle := elementText(decoder)
+				title := strings.Join(strings.Fields(ep.Package.Metadata.Titles[0]), " ")
+				if docTitle != title {
+					r.Add(report.Warning, "E2-017",
+						fmt.Sprintf("NCX docTitle '%s' does not match dc:title '%s'", docTitle, title))
+				}
+				return
+			}
+		case xml.EndElement:
+			if t.Name.Local == "docTitle" {
+				return
+			}
+		}
+	}
+}
+
+// E2-018: an NCX target that exists in the container but is not in the
+// manifest is not part of the publication, and reading systems may refuse
+// to open it. Missing files are E2-008.
+func checkNCXContentInManifest(ep *epub.EPUB, data []byte, ncxFullPath string, r *report.Report) {
+	manifestPaths := make(map[string]bool)
+	for _, item := range ep.Package.Manifest {
+		if item.Href != "\x00MISSING" {
+			manifestPaths[ep.ResolveHref(item.Href)] = true
+		}
+	}
+	ncxDir := path.Dir(ncxFullPath)
+	reported := make(map[string]bool)
+
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "content" {
+			continue
+		}
+		src, _ := attrValue(se.Attr, "src")
+		u, err := url.Parse(src)
+		if src == "" || err != nil || u.Scheme != "" {
+			continue
+		}
+		target := resolvePath(ncxDir, u.Path)
+		if _, exists := ep.Files[target]; !exists || manifestPaths[target] || reported[target] {
+			continue
+		}
+		reported[target] = true
+		r.Add(report.Error, "E2-018",
+			fmt.Sprintf("NCX references '%s', which is not declared in the OPF manifest", src))
+	}
+}
@@ -0,0 +1,148 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adammathes/epubverify/pkg/report"
+)
+
+const testOPF2 = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="chapter2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="ch1"/>
+    <itemref idref="ch2"/>
+  </spine>
+</package>`
+
+func testNCX(title, navPoints string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head><meta name="dtb:uid" content="urn:uuid:12345678-1234-1234-1234-123456789012"/></head>
+  <docTitle><text>` + title + `</text></docTitle>
+  <navMap>` + navPoints + `</navMap>
+</ncx>`
+}
+
+func navPoint(id, order, src string) string {
+	return `<navPoint id="` + id + `" playOrder="` + order + `"><navLabel><text>` + id + `</text></navLabel><content src="` + src + `"/></navPoint>`
+}
+
+func TestNCXStructure(t *testing.T) {
+	tests := []struct {
+		name      string
+		title     string
+		navPoints string
+		want      []string // E2-016/017/018 messages expected, by substring
+	}{
+		{
+			name:      "clean",
+			title:     "Test  Book",
+			navPoints: navPoint("a", "1", "chapter1.xhtml") + navPoint("b", "2", "chapter2.xhtml#s1") + navPoint("c", "2", "chapter2.xhtml"),
+		},
+		{
+			name:      "duplicate playOrder, different targets",
+			title:     "Test Book",
+			navPoints: navPoint("a", "1", "chapter1.xhtml") + navPoint("b", "1", "chapter2.xhtml"),
+			want:      []string{"playOrder '1' is used for different targets"},
+		},
+		{
+			name:      "gap",
+			title:     "Test Book",
+			navPoints: navPoint("a", "1", "chapter1.xhtml") + navPoint("b", "3", "chapter2.xhtml"),
+			want:      []string{"'2' is missing"},
+		},
+		{
+			name:      "not a number",
+			title:     "Test Book",
+			navPoints: navPoint("a", "one", "chapter1.xhtml"),
+			want:      []string{"'one' is not a positive integer"},
+		},
+		{
+			name:      "docTitle mismatch",
+			title:     "Draft Title",
+			navPoints: navPoint("a", "1", "chapter1.xhtml"),
+			want:      []string{"docTitle 'Draft Title' does not match dc:title 'Test Book'"},
+		},
+		{
+			name:      "target not in manifest",
+			title:     "Test Book",
+			navPoints: navPoint("a", "1", "chapter1.xhtml") + navPoint("b", "2", "extra.xhtml"),
+			want:      []string{"'extra.xhtml', which is not declared"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestEPUB(t, map[string]string{
+				"OEBPS/content.opf":    testOPF2,
+				"OEBPS/toc.ncx":        testNCX(tt.title, tt.navPoints),
+				"OEBPS/chapter2.xhtml": testChapterXHTML,
+				"OEBPS/extra.xhtml":    testChapterXHTML,
+			})
+			r, err := Validate(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []report.Message
+			for _, m := range r.Messages {
+				if m.CheckID == "E2-016" || m.CheckID == "E2-017" || m.CheckID == "E2-018" {
+					got = append(got, m)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %d E2-016/017/018 messages", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(got[i].Message, w) {
+					t.Errorf("message %q should contain %q", got[i].Message, w)
+				}
+			}
+		})
+	}
+}
+
+func TestEPUB2Guide(t *testing.T) {
+	guide := `<guide>
+    <reference type="text" title="Start" href="chapter1.xhtml"/>
+    <reference type="other.backmatter" title="Back" href="chapter2.xhtml"/>
+    <reference type="coverpage" title="Cover" href="chapter1.xhtml"/>
+    <reference type="toc" title="Contents" href="contents.xhtml#toc"/>
+  </guide>
+</package>`
+	opf := strings.Replace(testOPF2, "</package>", guide, 1)
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf":    opf,
+		"OEBPS/toc.ncx":        testNCX("Test Book", navPoint("a", "1", "chapter1.xhtml")),
+		"OEBPS/chapter2.xhtml": testChapterXHTML,
+	})
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dead, unknown []report.Message
+	for _, m := range r.Messages {
+		switch m.CheckID {
+		case "E2-009":
+			dead = append(dead, m)
+		case "E2-012":
+			unknown = append(unknown, m)
+		}
+	}
+	if len(dead) != 1 || dead[0].Severity != report.Error || !strings.Contains(dead[0].Message, "contents.xhtml#toc") {
+		t.Errorf("E2-009 = %v, want one error for contents.xhtml#toc", dead)
+	}
+	if len(unknown) != 1 || unknown[0].Severity != report.Warning || !strings.Contains(unknown[0].Message, "coverpage") {
+		t.Errorf("E2-012 = %v, want one warning for coverpage", unknown)
+	}
+}
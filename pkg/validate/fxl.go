@@ -1,7 +1,12 @@
 package validate
 
 import (
+	"encoding/xml"
 	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/adammathes/epubverify/pkg/epub"
@@ -9,7 +14,7 @@ import (
 )
 
 // checkFXL validates fixed-layout properties.
-func checkFXL(ep *epub.EPUB, r *report.Report) {
+func checkFXL(ep *epub.EPUB, r *report.Report, opts Options) {
 	if ep.Package == nil || ep.Package.Version < "3.0" {
 		return
 	}
@@ -56,5 +61,358 @@ func checkFXL(ep *epub.EPUB, r *report.Report) {
 					fmt.Sprintf("Undefined property '%s' on spine itemref", prop))
 			}
 		}
+
+		// FXL-020: at most one value per property group
+		checkSpinePropertyConflicts(ref, r)
+	}
+
+	// FXL-008: fixed-layout documents should actually fix their layout.
+	// A layout heuristic, so only with Quality.
+	if opts.Quality {
+		checkFXLNotReflowing(ep, r)
+	}
+
+	// FXL-009: fixed-viewport content in a book not declared pre-paginated
+	checkFXLLayoutUndeclared(ep, r)
+
+	// FXL-010, HTM-013/HTM-014: fixed-layout spine documents must size
+	// their pages
+	checkFXLSpineDimensions(ep, r)
+}
+
+// spinePropertyGroups maps the prefix of each spine itemref property group
+// to the name reported for it. page-spread-* and rendition:page-spread-*
+// are one group.
+var spinePropertyGroups = []struct{ prefix, group string }{
+	{"page-spread-", "page-spread"},
+	{"rendition:page-spread-", "page-spread"},
+	{"rendition:layout-", "rendition:layout"},
+	{"rendition:orientation-", "rendition:orientation"},
+	{"rendition:spread-", "rendition:spread"},
+	{"rendition:flow-", "rendition:flow"},
+}
+
+// FXL-020: a spine itemref may set only one value from each group, e.g.
+// not both page-spread-left and rendition:page-spread-right.
+func checkSpinePropertyConflicts(ref epub.SpineItemref, r *report.Report) {
+	seen := make(map[string]string) // group -> first property
+	values := make(map[string]string)
+	for _, prop := range strings.Fields(ref.Properties) {
+		if !validSpineProperties[prop] {
+			continue // FXL-004/FXL-005
+		}
+		for _, g := range spinePropertyGroups {
+			if !strings.HasPrefix(prop, g.prefix) {
+				continue
+			}
+			value := strings.TrimPrefix(prop, g.prefix)
+			if first, ok := seen[g.group]; !ok {
+				seen[g.group], values[g.group] = prop, value
+			} else if values[g.group] != value {
+				r.Add(report.Error, "FXL-020",
+					fmt.Sprintf("Spine itemref '%s' sets conflicting %s properties '%s' and '%s'", ref.IDRef, g.group, first, prop))
+			}
+			break
+		}
+	}
+}
+
+// FXL-010: a fixed-layout SVG spine document needs a viewBox to set its
+// page size, as an XHTML one needs a viewport. Content checks already
+// apply HTM-013/HTM-014 to XHTML in a pre-paginated book; items made
+// pre-paginated only by their spine itemref are checked here.
+func checkFXLSpineDimensions(ep *epub.EPUB, r *report.Report) {
+	manifestByID := make(map[string]epub.ManifestItem)
+	for _, item := range ep.Package.Manifest {
+		manifestByID[item.ID] = item
+	}
+	globalFXL := ep.Package.RenditionLayout == "pre-paginated"
+
+	for _, ref := range ep.Package.Spine {
+		fixed := globalFXL
+		if hasProperty(ref.Properties, "rendition:layout-pre-paginated") {
+			fixed = true
+		} else if hasProperty(ref.Properties, "rendition:layout-reflowable") {
+			fixed = false
+		}
+		item, ok := manifestByID[ref.IDRef]
+		if !fixed || !ok || item.Href == "\x00MISSING" || hasProperty(item.Properties, "nav") {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		switch item.MediaType {
+		case "application/xhtml+xml":
+			if !globalFXL {
+				checkFXLViewport(data, fullPath, r)
+			}
+		case "image/svg+xml":
+			if msg := svgViewBoxProblem(data); msg != "" {
+				r.AddWithLocation(report.Error, "FXL-010", msg, fullPath)
+			}
+		}
+	}
+}
+
+// svgViewBoxProblem describes what is wrong with the viewBox of an SVG
+// document's root element, or returns "" if it is four numbers with a
+// positive width and height.
+func svgViewBoxProblem(data []byte) string {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range se.Attr {
+			if attr.Name.Local != "viewBox" {
+				continue
+			}
+			fields := strings.FieldsFunc(attr.Value, func(c rune) bool {
+				return c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r'
+			})
+			var nums [4]float64
+			if len(fields) != 4 {
+				return fmt.Sprintf("Fixed-layout SVG document has a malformed viewBox '%s'", attr.Value)
+			}
+			for i, f := range fields {
+				n, err := strconv.ParseFloat(f, 64)
+				if err != nil {
+					return fmt.Sprintf("Fixed-layout SVG document has a malformed viewBox '%s'", attr.Value)
+				}
+				nums[i] = n
+			}
+			if nums[2] <= 0 || nums[3] <= 0 {
+				return fmt.Sprintf("Fixed-layout SVG document viewBox '%s' must have a positive width and height", attr.Value)
+			}
+			return ""
+		}
+		return "Fixed-layout SVG document has no viewBox on its root svg element"
+	}
+}
+
+// FXL-009: when every content document in the spine sets a pixel viewport
+// but the package never declares pre-paginated layout, reading systems
+// reflow pages that were designed as fixed.
+func checkFXLLayoutUndeclared(ep *epub.EPUB, r *report.Report) {
+	if ep.Package.RenditionLayout == "pre-paginated" {
+		return
+	}
+	manifestByID := make(map[string]epub.ManifestItem)
+	for _, item := range ep.Package.Manifest {
+		manifestByID[item.ID] = item
+	}
+
+	total, fixed := 0, 0
+	example, exampleHref := "", ""
+	for _, ref := range ep.Package.Spine {
+		if hasProperty(ref.Properties, "rendition:layout-pre-paginated") {
+			return // layout is declared per item
+		}
+		item, ok := manifestByID[ref.IDRef]
+		if !ok || item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" || hasProperty(item.Properties, "nav") {
+			continue
+		}
+		data, err := ep.ReadFile(ep.ResolveHref(item.Href))
+		if err != nil {
+			continue
+		}
+		total++
+		if vp := documentViewport(data); fixedViewportRe.MatchString(vp) {
+			fixed++
+			if example == "" {
+				example, exampleHref = vp, item.Href
+			}
+		}
+	}
+	if total == 0 || fixed < total {
+		return
+	}
+
+	declared := "is not declared"
+	if ep.Package.RenditionLayout != "" {
+		declared = fmt.Sprintf("is '%s'", ep.Package.RenditionLayout)
+	}
+	r.Add(report.Warning, "FXL-009",
+		fmt.Sprintf("All %d spine content documents set a fixed viewport (e.g. '%s' in '%s') but rendition:layout %s; add <meta property=\"rendition:layout\">pre-paginated</meta>", total, example, exampleHref, declared))
+}
+
+// fixedViewportRe matches a viewport that pins both width and height in pixels.
+var fixedViewportRe = regexp.MustCompile(`(?i)width\s*=\s*\d+.*height\s*=\s*\d+|height\s*=\s*\d+.*width\s*=\s*\d+`)
+
+// documentViewport returns the content of the document's viewport meta
+// element, or "" if it has none.
+func documentViewport(data []byte) string {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if se.Name.Local == "body" {
+			return ""
+		}
+		if se.Name.Local != "meta" {
+			continue
+		}
+		var name, content string
+		for _, attr := range se.Attr {
+			switch attr.Name.Local {
+			case "name":
+				name = attr.Value
+			case "content":
+				content = attr.Value
+			}
+		}
+		if name == "viewport" {
+			return content
+		}
+	}
+}
+
+// FXL-008: a pre-paginated content document with no absolute positioning or
+// pixel dimensions, or with many top-level flowing text blocks, is probably
+// relying on reflow and will render poorly as a fixed page.
+func checkFXLNotReflowing(ep *epub.EPUB, r *report.Report) {
+	manifestByID := make(map[string]epub.ManifestItem)
+	for _, item := range ep.Package.Manifest {
+		manifestByID[item.ID] = item
+	}
+	globalFXL := ep.Package.RenditionLayout == "pre-paginated"
+
+	for _, ref := range ep.Package.Spine {
+		fixed := globalFXL
+		if hasProperty(ref.Properties, "rendition:layout-pre-paginated") {
+			fixed = true
+		} else if hasProperty(ref.Properties, "rendition:layout-reflowable") {
+			fixed = false
+		}
+		if !fixed {
+			continue
+		}
+		item, ok := manifestByID[ref.IDRef]
+		if !ok || item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" || hasProperty(item.Properties, "nav") {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		checkFXLDocumentLayout(ep, data, fullPath, r)
+	}
+}
+
+// Minimum number of top-level text blocks in <body> before a fixed-layout
+// page is considered to be flowing text.
+const fxlFlowBlockThreshold = 3
+
+var fxlFlowBlocks = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "dl": true, "blockquote": true, "pre": true, "table": true,
+}
+
+// fxlFixedRe matches the CSS of absolutely positioned or pixel-sized
+// content, as laid out for a fixed page.
+var fxlFixedRe = regexp.MustCompile(`(?i)position\s*:\s*(absolute|fixed)|(^|[^-])(width|height)\s*:\s*\d+(\.\d+)?px`)
+
+func checkFXLDocumentLayout(ep *epub.EPUB, data []byte, location string, r *report.Report) {
+	var styles strings.Builder
+	hasFixedGraphic := false
+	flowBlocks := 0
+	depth := 0
+	bodyDepth := -1
+
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			name := t.Name.Local
+			if name == "body" {
+				bodyDepth = depth
+			} else if bodyDepth > 0 && depth == bodyDepth+1 && fxlFlowBlocks[name] {
+				flowBlocks++
+			}
+			var rel, href string
+			for _, attr := range t.Attr {
+				switch attr.Name.Local {
+				case "style":
+					styles.WriteString(attr.Value + ";")
+				case "rel":
+					rel = attr.Value
+				case "href":
+					href = attr.Value
+				case "viewBox", "width":
+					// Sized SVG or image pages are fixed by their own geometry
+					if name == "svg" || (name == "img" && attr.Name.Local == "width") {
+						hasFixedGraphic = true
+					}
+				}
+			}
+			if name == "style" {
+				if cd, ok := nextCharData(decoder); ok {
+					styles.WriteString(cd)
+				}
+				depth--
+			}
+			if name == "link" && containsToken(rel, "stylesheet") && href != "" && !isRemoteURL(href) {
+				if u, err := url.Parse(href); err == nil {
+					if css, err := ep.ReadFile(resolvePath(path.Dir(location), u.Path)); err == nil {
+						styles.Write(css)
+					}
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "body" {
+				bodyDepth = -1
+			}
+			depth--
+		}
+	}
+
+	if !hasFixedGraphic && !fxlFixedRe.MatchString(styles.String()) {
+		r.AddWithLocation(report.Warning, "FXL-008",
+			"Fixed-layout content document has no absolute positioning or pixel dimensions; its layout likely depends on reflow",
+			location)
+		return
+	}
+	if flowBlocks >= fxlFlowBlockThreshold {
+		r.AddWithLocation(report.Warning, "FXL-008",
+			fmt.Sprintf("Fixed-layout content document has %d top-level flowing text blocks; its layout likely depends on reflow", flowBlocks),
+			location)
+	}
+}
+
+// nextCharData reads the text content of the current element and consumes
+// its end tag.
+func nextCharData(decoder *xml.Decoder) (string, bool) {
+	var text strings.Builder
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return text.String(), text.Len() > 0
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			return text.String(), text.Len() > 0
+		}
 	}
 }
@@ -0,0 +1,188 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adammathes/epubverify/pkg/report"
+)
+
+func TestCheckFXLDocumentLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"sized body", `<body style="width: 600px; height: 800px"><div><p>a</p><p>b</p></div></body>`, false},
+		{"absolute blocks", `<body><div style="position: absolute; top: 10px">a</div></body>`, false},
+		{"svg page", `<body><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 600 800"/></body>`, false},
+		{"unsized", `<body><div>a</div></body>`, true},
+		{"flowing text", `<body style="width:600px;height:800px"><h1>T</h1><p>a</p><p>b</p></body>`, true},
+		{"percent only", `<body style="width: 100%"><div>a</div></body>`, true},
+	}
+	for _, tt := range tests {
+		xhtml := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Page</title><meta name="viewport" content="width=600, height=800"/></head>
+` + tt.body + `
+</html>`
+		r := report.NewReport()
+		checkFXLDocumentLayout(nil, []byte(xhtml), "page.xhtml", r)
+		if got := hasCheck(r, "FXL-008"); got != tt.want {
+			t.Errorf("%s: FXL-008 = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFXLLinkedStylesheetSizing(t *testing.T) {
+	opf := strings.Replace(testOPF(`    <item id="css" href="page.css" media-type="text/css"/>
+`), `<meta property="dcterms:modified">`, `<meta property="rendition:layout">pre-paginated</meta>
+    <meta property="dcterms:modified">`, 1)
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Page</title><meta name="viewport" content="width=600, height=800"/>
+<link rel="stylesheet" type="text/css" href="page.css"/></head>
+<body><div class="page">Hi</div></body>
+</html>`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf":    opf,
+		"OEBPS/chapter1.xhtml": chapter,
+		"OEBPS/page.css":       "body { width: 600px; height: 800px; }",
+	})
+
+	r, err := ValidateWithOptions(path, Options{Quality: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "FXL-008") {
+		t.Error("page sized by its linked stylesheet should not trigger FXL-008")
+	}
+
+	// An image page without pixel sizes is only flagged with Quality.
+	path = writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": opf,
+		"OEBPS/chapter1.xhtml": strings.Replace(chapter, `<div class="page">Hi</div>`,
+			`<p>Hi</p><p>there</p><p>reader</p>`, 1),
+	})
+	r, err = Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "FXL-008") {
+		t.Error("FXL-008 should only be reported with Quality")
+	}
+	r, err = ValidateWithOptions(path, Options{Quality: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "FXL-008") {
+		t.Error("expected FXL-008 with Quality for a flowing page")
+	}
+}
+
+func TestFXLLayoutUndeclared(t *testing.T) {
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Page</title><meta name="viewport" content="width=600, height=800"/></head>
+<body style="width: 600px; height: 800px"><div>Hi</div></body>
+</html>`
+
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf":    testOPF(""),
+		"OEBPS/chapter1.xhtml": chapter,
+	})
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "FXL-009") {
+		t.Error("fixed viewports without rendition:layout should trigger FXL-009")
+	}
+
+	opf := strings.Replace(testOPF(""), `<meta property="dcterms:modified">`, `<meta property="rendition:layout">pre-paginated</meta>
+    <meta property="dcterms:modified">`, 1)
+	path = writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf":    opf,
+		"OEBPS/chapter1.xhtml": chapter,
+	})
+	r, err = Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "FXL-009") {
+		t.Error("pre-paginated package should not trigger FXL-009")
+	}
+}
+
+func TestSVGViewBoxProblem(t *testing.T) {
+	tests := []struct {
+		svg  string
+		want string
+	}{
+		{`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 600 800"/>`, ""},
+		{`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0,0,600,800"/>`, ""},
+		{`<svg xmlns="http://www.w3.org/2000/svg" width="600" height="800"/>`, "no viewBox"},
+		{`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 600"/>`, "malformed"},
+		{`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 600 0"/>`, "positive"},
+	}
+	for _, tt := range tests {
+		got := svgViewBoxProblem([]byte(tt.svg))
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("svgViewBoxProblem(%s) = %q, want %q", tt.svg, got, tt.want)
+		}
+	}
+}
+
+func TestFXLSpineDimensions(t *testing.T) {
+	opf := strings.Replace(testOPF(`    <item id="page" href="page.svg" media-type="image/svg+xml"/>
+`), `<itemref idref="ch1"/>`, `<itemref idref="ch1" properties="rendition:layout-pre-paginated"/>
+    <itemref idref="page" properties="rendition:layout-pre-paginated"/>`, 1)
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": opf,
+		"OEBPS/page.svg":    `<svg xmlns="http://www.w3.org/2000/svg" width="600" height="800"><rect width="600" height="800"/></svg>`,
+	})
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		switch m.CheckID {
+		case "FXL-010", "HTM-013":
+			got = append(got, m.CheckID+" "+m.Location)
+		}
+	}
+	want := []string{"HTM-013 OEBPS/chapter1.xhtml", "FXL-010 OEBPS/page.svg"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSpineItemrefProperties(t *testing.T) {
+	tests := []struct {
+		props string
+		want  string // check ID expected, or "" for none
+	}{
+		{"rendition:page-spread-center", ""},
+		{"page-spread-left rendition:page-spread-left", ""},
+		{"rendition:layout-pre-paginated rendition:flow-scrolled-doc", ""},
+		{"page-spread-left rendition:page-spread-right", "FXL-020"},
+		{"rendition:spread-none rendition:spread-both", "FXL-020"},
+		{"page-spread-centre", "FXL-004"},
+	}
+	for _, tt := range tests {
+		opf := strings.Replace(testOPF(""), `<itemref idref="ch1"/>`, `<itemref idref="ch1" properties="`+tt.props+`"/>`, 1)
+		path := writeTestEPUB(t, map[string]string{"OEBPS/content.opf": opf})
+		r, err := Validate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []string{"FXL-004", "FXL-020"} {
+			if got := hasCheck(r, id); got != (id == tt.want) {
+				t.Errorf("%q: %s = %v, want %v", tt.props, id, got, id == tt.want)
+			}
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"math"
 	"net/url"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/adammathes/epubverify/pkg/epub"
@@ -55,6 +57,13 @@ var coreVideoTypes = map[string]bool{
 	"video/webm": true,
 }
 
+// Audio media types usable in media overlays (EPUB 3 core media types)
+var coreAudioTypes = map[string]bool{
+	"audio/mpeg": true,
+	"audio/mp4":  true,
+	"audio/ogg":  true,
+}
+
 // Image magic bytes for type detection
 var pngMagic = []byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a}
 var jpegMagic = []byte{0xff, 0xd8, 0xff}
@@ -106,7 +115,7 @@ func checkMedia(ep *epub.EPUB, r *report.Report) {
 				fmt.Sprintf("Fallback must be provided for foreign resources: '%s' has media type '%s'", item.Href, item.MediaType))
 		}
 
-		// MED-006 through MED-011: media overlay SMIL checks
+		// MED-006 through MED-011, SMIL-006: media overlay SMIL checks
 		if item.MediaType == "application/smil+xml" && ep.Package.Version >= "3.0" {
 			checkMediaOverlay(ep, item, fullPath, r)
 		}
@@ -121,6 +130,11 @@ func checkMedia(ep *epub.EPUB, r *report.Report) {
 	if ep.Package.Version >= "3.0" {
 		checkMediaOverlayProperty(ep, r)
 	}
+
+	// MED-015/MED-016: per-overlay durations must be declared and add up
+	if ep.Package.Version >= "3.0" {
+		checkMediaOverlayItemDurations(ep, r)
+	}
 }
 
 // MED-001: verify image file type matches declared media type
@@ -306,6 +320,8 @@ func checkSMILAudio(ep *epub.EPUB, se xml.StartElement, smilDir string, location
 				r.AddWithLocation(report.Error, "MED-007",
 					fmt.Sprintf("Referenced resource '%s' could not be found in the container", attr.Value),
 					location)
+			} else {
+				checkSMILAudioManifestType(ep, attr.Value, target, location, r)
 			}
 		}
 		// MED-010: clipBegin/clipEnd must be valid SMIL clock values
@@ -317,6 +333,54 @@ func checkSMILAudio(ep *epub.EPUB, se xml.StartElement, smilDir string, location
 			}
 		}
 	}
+
+	// MED-014: the clip must end after it begins
+	checkSMILClipOrder(se, location, r)
+}
+
+// MED-014: an audio clip whose clipEnd is not after its clipBegin plays
+// nothing. A missing clipBegin means the start of the file; invalid values
+// are left to MED-010.
+func checkSMILClipOrder(se xml.StartElement, location string, r *report.Report) {
+	begin, end := "0s", ""
+	for _, attr := range se.Attr {
+		switch attr.Name.Local {
+		case "clipBegin":
+			begin = attr.Value
+		case "clipEnd":
+			end = attr.Value
+		}
+	}
+	if end == "" {
+		return
+	}
+	b, okBegin := smilClockSeconds(begin)
+	e, okEnd := smilClockSeconds(end)
+	if okBegin && okEnd && e <= b {
+		r.AddWithLocation(report.Error, "MED-014",
+			fmt.Sprintf("Audio clip ends at '%s', which is not after its start at '%s'", end, begin),
+			location)
+	}
+}
+
+// SMIL-006: audio referenced from a media overlay must be a manifest item
+// of a supported audio type
+func checkSMILAudioManifestType(ep *epub.EPUB, src, target, location string, r *report.Report) {
+	for _, item := range ep.Package.Manifest {
+		if item.Href == "\x00MISSING" || ep.ResolveHref(item.Href) != target {
+			continue
+		}
+		mt := strings.TrimSpace(strings.SplitN(item.MediaType, ";", 2)[0])
+		if !coreAudioTypes[mt] {
+			r.AddWithLocation(report.Error, "SMIL-006",
+				fmt.Sprintf("Media overlay audio '%s' has media-type '%s', which is not a supported audio type", src, item.MediaType),
+				location)
+		}
+		return
+	}
+	r.AddWithLocation(report.Error, "SMIL-006",
+		fmt.Sprintf("Media overlay audio '%s' is not declared in the OPF manifest", src),
+		location)
 }
 
 // MED-008: text src must reference an existing fragment
@@ -358,6 +422,42 @@ func isValidSMILClockValue(val string) bool {
 	return smilClockRe.MatchString(val)
 }
 
+// smilClockSeconds converts a SMIL clock value to seconds. It reports
+// false for values isValidSMILClockValue rejects.
+func smilClockSeconds(val string) (float64, bool) {
+	if !isValidSMILClockValue(val) {
+		return 0, false
+	}
+	if strings.Contains(val, ":") {
+		// Full (hh:mm:ss.f) or partial (mm:ss.f) clock value
+		var secs float64
+		for _, part := range strings.Split(val, ":") {
+			n, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0, false
+			}
+			secs = secs*60 + n
+		}
+		return secs, true
+	}
+	// Timecount value; seconds when there is no unit
+	scale := 1.0
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{{"ms", 0.001}, {"min", 60}, {"h", 3600}, {"s", 1}} {
+		if strings.HasSuffix(val, unit.suffix) {
+			val, scale = strings.TrimSuffix(val, unit.suffix), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n * scale, true
+}
+
 // MED-009: media overlay items must have media:duration meta elements
 func checkMediaOverlayDuration(ep *epub.EPUB, r *report.Report) {
 	hasSMIL := false
@@ -402,3 +502,64 @@ func checkMediaOverlayProperty(ep *epub.EPUB, r *report.Report) {
 		}
 	}
 }
+
+// MED-015: every media overlay referenced by a media-overlay attribute
+// should have a media:duration meta refining its manifest item.
+// MED-016: the per-overlay durations must add up to the publication's
+// media:duration, to within a second.
+func checkMediaOverlayItemDurations(ep *epub.EPUB, r *report.Report) {
+	smilIDs := make(map[string]bool)
+	for _, item := range ep.Package.Manifest {
+		if item.MediaType == "application/smil+xml" {
+			smilIDs[item.ID] = true
+		}
+	}
+
+	durations := make(map[string]string)
+	for _, mr := range ep.Package.MetaRefines {
+		if mr.Property == "media:duration" {
+			durations[strings.TrimPrefix(mr.Refines, "#")] = strings.TrimSpace(mr.Value)
+		}
+	}
+
+	var overlays []string
+	seen := make(map[string]bool)
+	for _, item := range ep.Package.Manifest {
+		id := item.MediaOverlay
+		if id == "" || !smilIDs[id] || seen[id] {
+			continue // unknown ids are MED-013
+		}
+		seen[id] = true
+		overlays = append(overlays, id)
+	}
+	if len(overlays) == 0 {
+		return
+	}
+
+	sum, complete := 0.0, true
+	for _, id := range overlays {
+		val, ok := durations[id]
+		if !ok {
+			r.Add(report.Warning, "MED-015",
+				fmt.Sprintf("Media overlay '%s' has no media:duration meta element refining it", id))
+			complete = false
+			continue
+		}
+		secs, ok := smilClockSeconds(val)
+		if !ok {
+			complete = false
+			continue
+		}
+		sum += secs
+	}
+
+	totals := ep.Package.Metadata.Meta["media:duration"]
+	if !complete || len(totals) == 0 {
+		return // a missing total is MED-009
+	}
+	total, ok := smilClockSeconds(strings.TrimSpace(totals[0]))
+	if ok && math.Abs(total-sum) > 1 {
+		r.Add(report.Error, "MED-016",
+			fmt.Sprintf("The total media:duration '%s' does not match the sum of the media overlay durations (%gs)", strings.TrimSpace(totals[0]), sum))
+	}
+}
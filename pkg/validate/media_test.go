@@ -0,0 +1,138 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSMILAudioManifestType(t *testing.T) {
+	smil := `<?xml version="1.0" encoding="UTF-8"?>
+<smil xmlns="http://www.w3.org/ns/SMIL" version="3.0">
+<body><par><text src="chapter1.xhtml"/><audio src="audio/ch1.mp3" clipBegin="0s" clipEnd="5s"/></par></body>
+</smil>`
+	tests := []struct {
+		name     string
+		manifest string
+		want     bool
+	}{
+		{"audio item", `    <item id="aud" href="audio/ch1.mp3" media-type="audio/mpeg"/>
+`, false},
+		{"wrong type", `    <item id="aud" href="audio/ch1.mp3" media-type="video/mp4"/>
+`, true},
+		{"not in manifest", ``, true},
+	}
+	for _, tt := range tests {
+		path := writeTestEPUB(t, map[string]string{
+			"OEBPS/content.opf": testOPF(`    <item id="mo" href="ch1.smil" media-type="application/smil+xml"/>
+` + tt.manifest),
+			"OEBPS/ch1.smil":      smil,
+			"OEBPS/audio/ch1.mp3": "ID3",
+		})
+		r, err := Validate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasCheck(r, "SMIL-006"); got != tt.want {
+			t.Errorf("%s: SMIL-006 = %v, want %v (%v)", tt.name, got, tt.want, r.Messages)
+		}
+	}
+}
+
+func TestSMILClockSeconds(t *testing.T) {
+	tests := []struct {
+		val  string
+		want float64
+		ok   bool
+	}{
+		{"5", 5, true},
+		{"1.5s", 1.5, true},
+		{"250ms", 0.25, true},
+		{"2min", 120, true},
+		{"1h", 3600, true},
+		{"01:30", 90, true},
+		{"1:02:03.5", 3723.5, true},
+		{"abc", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := smilClockSeconds(tt.val)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("smilClockSeconds(%q) = %v, %v; want %v, %v", tt.val, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSMILClipOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		clip string
+		want bool
+	}{
+		{"in order", `clipBegin="0:00:01.5" clipEnd="2.5s"`, false},
+		{"end only", `clipEnd="500ms"`, false},
+		{"reversed", `clipBegin="10s" clipEnd="00:05"`, true},
+		{"empty clip", `clipBegin="3s" clipEnd="3000ms"`, true},
+		{"invalid value", `clipBegin="soon" clipEnd="1s"`, false},
+	}
+	for _, tt := range tests {
+		smil := `<?xml version="1.0" encoding="UTF-8"?>
+<smil xmlns="http://www.w3.org/ns/SMIL" version="3.0">
+<body><par><text src="chapter1.xhtml"/><audio src="audio/ch1.mp3" CLIP/></par></body>
+</smil>`
+		path := writeTestEPUB(t, map[string]string{
+			"OEBPS/content.opf": testOPF(`    <item id="mo" href="ch1.smil" media-type="application/smil+xml"/>
+    <item id="aud" href="audio/ch1.mp3" media-type="audio/mpeg"/>
+`),
+			"OEBPS/ch1.smil":      strings.Replace(smil, "CLIP", tt.clip, 1),
+			"OEBPS/audio/ch1.mp3": "ID3",
+		})
+		r, err := Validate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasCheck(r, "MED-014"); got != tt.want {
+			t.Errorf("%s: MED-014 = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMediaOverlayItemDurations(t *testing.T) {
+	smil := `<?xml version="1.0" encoding="UTF-8"?>
+<smil xmlns="http://www.w3.org/ns/SMIL" version="3.0">
+<body><par><text src="chapter1.xhtml"/><audio src="audio/ch1.mp3" clipBegin="0s" clipEnd="5s"/></par></body>
+</smil>`
+	tests := []struct {
+		name    string
+		meta    string
+		want015 bool
+		want016 bool
+	}{
+		{"consistent", `<meta property="media:duration">0:01:00</meta>
+    <meta property="media:duration" refines="#mo">60.4s</meta>`, false, false},
+		{"missing item duration", `<meta property="media:duration">0:01:00</meta>`, true, false},
+		{"totals disagree", `<meta property="media:duration">0:02:00</meta>
+    <meta property="media:duration" refines="#mo">1min</meta>`, false, true},
+	}
+	for _, tt := range tests {
+		opf := testOPF(`    <item id="mo" href="ch1.smil" media-type="application/smil+xml"/>
+    <item id="aud" href="audio/ch1.mp3" media-type="audio/mpeg"/>
+`)
+		opf = strings.Replace(opf, `href="chapter1.xhtml" media-type="application/xhtml+xml"`,
+			`href="chapter1.xhtml" media-type="application/xhtml+xml" media-overlay="mo"`, 1)
+		opf = strings.Replace(opf, "  </metadata>", "    "+tt.meta+"\n  </metadata>", 1)
+		path := writeTestEPUB(t, map[string]string{
+			"OEBPS/content.opf":   opf,
+			"OEBPS/ch1.smil":      smil,
+			"OEBPS/audio/ch1.mp3": "ID3",
+		})
+		r, err := Validate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasCheck(r, "MED-015"); got != tt.want015 {
+			t.Errorf("%s: MED-015 = %v, want %v", tt.name, got, tt.want015)
+		}
+		if got := hasCheck(r, "MED-016"); got != tt.want016 {
+			t.Errorf("%s: MED-016 = %v, want %v", tt.name, got, tt.want016)
+		}
+	}
+}
@@ -31,6 +31,21 @@ func navDocHasToc(data []byte) bool {
 	return false
 }
 
+// navDocHasNav checks whether the given XHTML document contains any
+// <nav> element.
+func navDocHasNav(data []byte) bool {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "nav" {
+			return true
+		}
+	}
+}
+
 func containsToken(s, token string) bool {
 	for _, t := range strings.Fields(s) {
 		if t == token {
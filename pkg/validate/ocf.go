@@ -6,7 +6,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
-	"os"
+	"sort"
 	"strings"
 
 	"github.com/adammathes/epubverify/pkg/epub"
@@ -21,20 +21,26 @@ func checkOCF(ep *epub.EPUB, r *report.Report, opts Options) bool {
 	// OCF-001: mimetype file must be present
 	checkMimetypePresent(ep, r)
 
-	// OCF-002: mimetype must be first entry
-	checkMimetypeFirst(ep, r)
-
 	// OCF-003: mimetype content must be exactly "application/epub+zip"
 	checkMimetypeContent(ep, r)
 
-	// OCF-004: mimetype must not have extra field in local header
-	checkMimetypeNoExtraField(ep, r)
-
-	// OCF-005: mimetype must be stored, not compressed
-	// epubcheck 5.3.0 does not flag compressed mimetype entries.
-	// Only check in strict mode to better follow the spec.
-	if opts.Strict {
-		checkMimetypeStored(ep, r)
+	// OCF-026: an unpacked directory has no zip structure to check
+	if ep.FromDir {
+		r.Add(report.Info, "OCF-026",
+			"Validating an unpacked directory; zip structure checks (OCF-002, OCF-004, OCF-005) do not apply")
+	} else {
+		// OCF-002: mimetype must be first entry
+		checkMimetypeFirst(ep, r)
+
+		// OCF-004: mimetype must not have extra field in local header
+		checkMimetypeNoExtraField(ep, r)
+
+		// OCF-005: mimetype must be stored, not compressed
+		// epubcheck 5.3.0 does not flag compressed mimetype entries.
+		// Only check in strict mode to better follow the spec.
+		if opts.Strict {
+			checkMimetypeStored(ep, r)
+		}
 	}
 
 	// OCF-006: container.xml must be present
@@ -47,6 +53,14 @@ func checkOCF(ep *epub.EPUB, r *report.Report, opts Options) bool {
 		return true
 	}
 
+	// OCF-017/OCF-019: select the requested rendition when there are several
+	if !selectRootfile(ep, r, opts) {
+		return true
+	}
+
+	// OCF-020: alternate renditions need selection attributes
+	checkRenditionSelection(ep, r)
+
 	// OCF-008: container.xml must have a rootfile
 	if !checkContainerHasRootfile(ep, r) {
 		fatal = true
@@ -80,9 +94,55 @@ func checkOCF(ep *epub.EPUB, r *report.Report, opts Options) bool {
 	// OCF-016: file paths should not exceed 65535 bytes
 	checkFilenameLength(ep, r)
 
+	// OCF-025: entries too large to read into memory are skipped
+	checkEntrySizeLimits(ep, r)
+
+	// OCF-018: total uncompressed size over the configured cap
+	if opts.MaxUncompressedBytes > 0 {
+		checkTotalUncompressedSize(ep, r, opts.MaxUncompressedBytes)
+	}
+
 	return fatal
 }
 
+// OCF-018: storefronts cap the size of the books they accept. The warning
+// names the largest entries, which are usually what to shrink.
+func checkTotalUncompressedSize(ep *epub.EPUB, r *report.Report, limit int64) {
+	var total uint64
+	files := make([]*zip.File, 0, len(ep.Zip.File))
+	for _, f := range ep.Zip.File {
+		total += f.UncompressedSize64
+		files = append(files, f)
+	}
+	if total <= uint64(limit) {
+		return
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].UncompressedSize64 > files[j].UncompressedSize64
+	})
+	var largest []string
+	for _, f := range files[:min(5, len(files))] {
+		largest = append(largest, fmt.Sprintf("%s (%d bytes)", f.Name, f.UncompressedSize64))
+	}
+	r.Add(report.Warning, "OCF-018",
+		fmt.Sprintf("Total uncompressed size is %d bytes, over the %d byte limit; largest entries: %s",
+			total, limit, strings.Join(largest, ", ")))
+}
+
+// OCF-025: entries larger than epub.MaxFileSize cannot be loaded, so any
+// content checks on them are skipped. Sizes come from the zip64 extra field
+// when present, so archives and entries over 4 GiB are reported correctly.
+func checkEntrySizeLimits(ep *epub.EPUB, r *report.Report) {
+	for _, f := range ep.Zip.File {
+		if f.UncompressedSize64 > epub.MaxFileSize {
+			r.AddWithLocation(report.Warning, "OCF-025",
+				fmt.Sprintf("File '%s' is %d bytes uncompressed, over the %d byte limit for reading; its contents were not checked", f.Name, f.UncompressedSize64, epub.MaxFileSize),
+				f.Name)
+		}
+	}
+}
+
 // OCF-001: mimetype file must be present
 func checkMimetypePresent(ep *epub.EPUB, r *report.Report) {
 	_, exists := ep.Files["mimetype"]
@@ -93,10 +153,10 @@ func checkMimetypePresent(ep *epub.EPUB, r *report.Report) {
 
 // OCF-002: mimetype must be the first entry in the zip
 func checkMimetypeFirst(ep *epub.EPUB, r *report.Report) {
-	if len(ep.ZipFile.File) == 0 {
+	if len(ep.Zip.File) == 0 {
 		return
 	}
-	first := ep.ZipFile.File[0]
+	first := ep.Zip.File[0]
 	if first.Name != "mimetype" {
 		// Only report if mimetype exists but isn't first (OCF-001 covers missing case)
 		if _, exists := ep.Files["mimetype"]; exists {
@@ -107,11 +167,10 @@ func checkMimetypeFirst(ep *epub.EPUB, r *report.Report) {
 
 // OCF-003: mimetype must contain exactly "application/epub+zip"
 func checkMimetypeContent(ep *epub.EPUB, r *report.Report) {
-	f, exists := ep.Files["mimetype"]
-	if !exists {
+	if _, exists := ep.Files["mimetype"]; !exists {
 		return
 	}
-	data, err := readZipFile(f)
+	data, err := ep.ReadFile("mimetype")
 	if err != nil {
 		return
 	}
@@ -131,7 +190,7 @@ func checkMimetypeNoExtraField(ep *epub.EPUB, r *report.Report) {
 		return
 	}
 
-	hasExtra, err := mimetypeLocalHeaderHasExtra(ep.Path)
+	hasExtra, err := mimetypeLocalHeaderHasExtra(ep.Raw)
 	if err != nil {
 		return
 	}
@@ -143,13 +202,7 @@ func checkMimetypeNoExtraField(ep *epub.EPUB, r *report.Report) {
 // mimetypeLocalHeaderHasExtra reads the raw zip bytes to check if the first
 // local file header (which should be the mimetype entry) has a non-zero
 // extra field length.
-func mimetypeLocalHeaderHasExtra(path string) (bool, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return false, err
-	}
-	defer f.Close()
-
+func mimetypeLocalHeaderHasExtra(ra io.ReaderAt) (bool, error) {
 	// ZIP local file header structure:
 	// 0-3:   signature (0x04034b50)
 	// 4-5:   version needed
@@ -164,8 +217,7 @@ func mimetypeLocalHeaderHasExtra(path string) (bool, error) {
 	// 28-29: extra field length
 
 	header := make([]byte, 30)
-	_, err = f.Read(header)
-	if err != nil {
+	if _, err := ra.ReadAt(header, 0); err != nil {
 		return false, err
 	}
 
@@ -219,6 +271,50 @@ func checkContainerHasRootfile(ep *epub.EPUB, r *report.Report) bool {
 	return true
 }
 
+// OCF-017: validate the rootfile requested in opts.Rootfile.
+// OCF-019: note which rendition was validated when the container lists
+// more than one.
+// Returns false if the requested rootfile isn't in container.xml.
+func selectRootfile(ep *epub.EPUB, r *report.Report, opts Options) bool {
+	if opts.Rootfile != "" {
+		found := false
+		for _, rf := range ep.AllRootfiles {
+			if rf.FullPath == opts.Rootfile {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.Add(report.Fatal, "OCF-017",
+				fmt.Sprintf("Requested rootfile '%s' is not listed in META-INF/container.xml", opts.Rootfile))
+			return false
+		}
+		ep.RootfilePath = opts.Rootfile
+	}
+
+	if len(ep.AllRootfiles) > 1 && ep.RootfilePath != "" && !opts.AllRenditions {
+		r.Add(report.Info, "OCF-019",
+			fmt.Sprintf("Container lists %d rootfiles; validated '%s'", len(ep.AllRootfiles), ep.RootfilePath))
+	}
+	return true
+}
+
+// OCF-020: when container.xml lists several renditions, reading systems
+// pick one by its rendition:* selection attributes. The first rootfile is
+// the default, but every later one needs at least one attribute.
+func checkRenditionSelection(ep *epub.EPUB, r *report.Report) {
+	if len(ep.AllRootfiles) < 2 {
+		return
+	}
+	for _, rf := range ep.AllRootfiles[1:] {
+		if len(rf.Rendition) == 0 {
+			r.AddWithLocation(report.Warning, "OCF-020",
+				fmt.Sprintf("Rootfile '%s' is an alternate rendition but declares no rendition:* selection attributes (rendition:media, rendition:layout, rendition:language, rendition:accessMode or rendition:label)", rf.FullPath),
+				"META-INF/container.xml")
+		}
+	}
+}
+
 // OCF-009: rootfile full-path must point to an existing file
 func checkRootfileExists(ep *epub.EPUB, r *report.Report) bool {
 	if ep.RootfilePath == "" {
@@ -278,8 +374,9 @@ func checkRootfileMediaType(ep *epub.EPUB, r *report.Report) {
 		}
 	}
 	if len(ep.AllRootfiles) > 0 && !hasCorrectMediaType {
+		rf := ep.AllRootfiles[0]
 		r.Add(report.Error, "OCF-012",
-			"No rootfile tag with media type 'application/oebps-package+xml' found")
+			fmt.Sprintf("No rootfile tag with media type 'application/oebps-package+xml' found: rootfile '%s' has media type '%s'", rf.FullPath, rf.MediaType))
 	}
 }
 
@@ -341,7 +438,7 @@ func checkFilenameValidChars(ep *epub.EPUB, r *report.Report) {
 	// Characters restricted in ZIP/EPUB filenames
 	restricted := []rune{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31}
 
-	for _, f := range ep.ZipFile.File {
+	for _, f := range ep.Zip.File {
 		for _, c := range f.Name {
 			for _, r2 := range restricted {
 				if c == r2 {
@@ -356,28 +453,10 @@ func checkFilenameValidChars(ep *epub.EPUB, r *report.Report) {
 
 // OCF-016: file paths should not exceed 65535 bytes
 func checkFilenameLength(ep *epub.EPUB, r *report.Report) {
-	for _, f := range ep.ZipFile.File {
+	for _, f := range ep.Zip.File {
 		if len(f.Name) > 65535 {
 			r.Add(report.Warning, "OCF-016",
 				fmt.Sprintf("File path '%s...' exceeds recommended maximum of 65535 bytes", f.Name[:50]))
 		}
 	}
 }
-
-func readZipFile(f *zip.File) ([]byte, error) {
-	rc, err := f.Open()
-	if err != nil {
-		return nil, err
-	}
-	defer rc.Close()
-	var buf []byte
-	b := make([]byte, 1024)
-	for {
-		n, err := rc.Read(b)
-		buf = append(buf, b[:n]...)
-		if err != nil {
-			break
-		}
-	}
-	return buf, nil
-}
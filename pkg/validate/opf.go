@@ -1,6 +1,7 @@
 package validate
 
 import (
+	"encoding/xml"
 	"fmt"
 	"path"
 	"regexp"
@@ -12,10 +13,17 @@ import (
 
 // checkOPF parses the OPF and runs all package document checks.
 // Returns true if a fatal error prevents further processing.
-func checkOPF(ep *epub.EPUB, r *report.Report) bool {
+func checkOPF(ep *epub.EPUB, r *report.Report, opts Options) bool {
 	if err := ep.ParseOPF(); err != nil {
 		// OPF-011: malformed XML in OPF
-		r.Add(report.Fatal, "OPF-011", "Could not parse package document: XML document structures must start and end within the same entity")
+		r.AddWithLocation(report.Fatal, "OPF-011",
+			fmt.Sprintf("Could not parse package document: XML document structures must start and end within the same entity (%s: %s)", ep.RootfilePath, err.Error()),
+			ep.RootfilePath)
+		return true
+	}
+
+	// OPF-080: the rootfile must actually be a package document
+	if !checkRootfileIsPackage(ep, r) {
 		return true
 	}
 
@@ -45,6 +53,9 @@ func checkOPF(ep *epub.EPUB, r *report.Report) bool {
 	// OPF-002: dc:identifier must be present
 	checkDCIdentifier(pkg, r)
 
+	// OPF-082: dc:identifier values should be distinct
+	checkDCIdentifierUnique(pkg, r)
+
 	// OPF-003: dc:language must be present
 	checkDCLanguage(pkg, r)
 
@@ -52,9 +63,10 @@ func checkOPF(ep *epub.EPUB, r *report.Report) bool {
 	checkDCTermsModified(pkg, r)
 
 	// OPF-019: dcterms:modified must be valid format
-	checkDCTermsModifiedFormat(pkg, r)
+	checkDCTermsModifiedFormat(pkg, r, opts)
 
 	// OPF-020: dc:language must be valid BCP 47
+	// OPF-076: dc:language should be in canonical form
 	checkDCLanguageValid(pkg, r)
 
 	// OPF-005: manifest item IDs must be unique
@@ -96,9 +108,18 @@ func checkOPF(ep *epub.EPUB, r *report.Report) bool {
 	// OPF-024: media-type must match actual content
 	checkMediaTypeMatches(ep, r)
 
+	// OPF-083: image file extension should agree with its media-type
+	checkImageExtensionMatches(ep, r)
+
+	// FONT-004: legacy font media types have font/* replacements
+	checkLegacyFontMediaTypes(pkg, r)
+
 	// OPF-025: cover-image must be on image media type
 	checkCoverImageIsImage(pkg, r)
 
+	// OPF-060: the cover must be declared once and point at a raster image
+	checkCoverImageConsistency(pkg, r)
+
 	// OPF-027: package unique-identifier attribute present
 	checkPackageUniqueIdentifierAttr(pkg, r)
 
@@ -153,9 +174,43 @@ func checkOPF(ep *epub.EPUB, r *report.Report) bool {
 	// OPF-044: media-overlay references
 	checkMediaOverlayRef(pkg, r)
 
+	// OPF-074: media-type values that look like typos of core media types
+	checkMediaTypeTypos(pkg, r)
+
+	// OPF-081: properties should be a clean, duplicate-free token list
+	checkPropertiesTokens(pkg, r)
+
 	return false
 }
 
+// OPF-080: a rootfile that is well-formed XML but not an OPF <package>
+// (an XHTML or NCX file named by mistake) would otherwise surface as a
+// cascade of missing-element errors.
+func checkRootfileIsPackage(ep *epub.EPUB, r *report.Report) bool {
+	data, err := ep.ReadFile(ep.RootfilePath)
+	if err != nil {
+		return true
+	}
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return true
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if se.Name.Local == "package" && (se.Name.Space == "" || se.Name.Space == "http://www.idpf.org/2007/opf") {
+			return true
+		}
+		r.AddWithLocation(report.Fatal, "OPF-080",
+			fmt.Sprintf("Rootfile '%s' is not a package document: its root element is <%s> in namespace '%s'", ep.RootfilePath, se.Name.Local, se.Name.Space),
+			ep.RootfilePath)
+		return false
+	}
+}
+
 // OPF-001
 func checkDCTitle(pkg *epub.Package, r *report.Report) {
 	if len(pkg.Metadata.Titles) == 0 {
@@ -170,6 +225,23 @@ func checkDCIdentifier(pkg *epub.Package, r *report.Report) {
 	}
 }
 
+// OPF-082: the same identifier listed twice adds nothing and usually
+// means metadata was merged from two sources without deduplicating.
+func checkDCIdentifierUnique(pkg *epub.Package, r *report.Report) {
+	seen := make(map[string]int)
+	for _, id := range pkg.Metadata.Identifiers {
+		v := strings.TrimSpace(id.Value)
+		if v == "" {
+			continue // OPF-031
+		}
+		seen[v]++
+		if seen[v] == 2 {
+			r.Add(report.Warning, "OPF-082",
+				fmt.Sprintf("Identifier '%s' is listed in more than one dc:identifier element", v))
+		}
+	}
+}
+
 // OPF-003
 func checkDCLanguage(pkg *epub.Package, r *report.Report) {
 	if len(pkg.Metadata.Languages) == 0 {
@@ -184,21 +256,33 @@ func checkDCTermsModified(pkg *epub.Package, r *report.Report) {
 	}
 }
 
-// OPF-005
+// OPF-005: a reading system resolving an idref to a duplicated id may pick
+// either item, so name both hrefs.
 func checkManifestUniqueIDs(pkg *epub.Package, r *report.Report) {
-	seen := make(map[string]bool)
+	first := make(map[string]epub.ManifestItem)
 	for _, item := range pkg.Manifest {
-		if item.ID == "" {
+		if !item.HasID || item.ID == "" {
 			continue
 		}
-		if seen[item.ID] {
+		if prev, ok := first[item.ID]; ok {
 			r.Add(report.Error, "OPF-005",
-				fmt.Sprintf("Duplicate manifest item id '%s'", item.ID))
+				fmt.Sprintf("Duplicate manifest item id '%s' is used by both '%s' and '%s'",
+					item.ID, manifestHrefLabel(prev), manifestHrefLabel(item)))
+			continue
 		}
-		seen[item.ID] = true
+		first[item.ID] = item
 	}
 }
 
+// manifestHrefLabel returns the item's href, or a placeholder when the
+// href attribute is missing.
+func manifestHrefLabel(item epub.ManifestItem) string {
+	if item.Href == "\x00MISSING" {
+		return "(no href)"
+	}
+	return item.Href
+}
+
 // OPF-006
 func checkManifestHrefRequired(pkg *epub.Package, r *report.Report) {
 	for _, item := range pkg.Manifest {
@@ -225,13 +309,20 @@ func checkUniqueIdentifierResolves(pkg *epub.Package, r *report.Report) {
 		r.Add(report.Error, "OPF-008", "Package element is missing unique-identifier attribute")
 		return
 	}
+	var ids []string
 	for _, id := range pkg.Metadata.Identifiers {
 		if id.ID == pkg.UniqueIdentifier {
 			return
 		}
+		if id.ID != "" {
+			ids = append(ids, "'"+id.ID+"'")
+		}
 	}
-	r.Add(report.Error, "OPF-008",
-		fmt.Sprintf("The unique-identifier '%s' was not found among dc:identifier elements", pkg.UniqueIdentifier))
+	msg := fmt.Sprintf("The unique-identifier '%s' was not found among dc:identifier elements", pkg.UniqueIdentifier)
+	if len(ids) > 0 {
+		msg += " (identifier ids: " + strings.Join(ids, ", ") + ")"
+	}
+	r.Add(report.Error, "OPF-008", msg)
 }
 
 // OPF-009
@@ -311,26 +402,128 @@ func checkManifestIDRequired(pkg *epub.Package, r *report.Report) {
 // OPF-019: dcterms:modified format validation
 var modifiedDateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`)
 
-func checkDCTermsModifiedFormat(pkg *epub.Package, r *report.Report) {
+// Timestamps that are unambiguous but not in the required form: missing
+// seconds or Z, fractional seconds, or a numeric timezone offset.
+var nearModifiedDateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?$`)
+
+func checkDCTermsModifiedFormat(pkg *epub.Package, r *report.Report, opts Options) {
 	if pkg.Version < "3.0" || pkg.Metadata.Modified == "" {
 		return
 	}
-	if !modifiedDateRe.MatchString(pkg.Metadata.Modified) {
+	if modifiedDateRe.MatchString(pkg.Metadata.Modified) {
+		return
+	}
+	if opts.LenientTimestamps && !opts.Strict && nearModifiedDateRe.MatchString(pkg.Metadata.Modified) {
+		r.Add(report.Warning, "OPF-019",
+			fmt.Sprintf("dcterms:modified value '%s' is not in the required CCYY-MM-DDThh:mm:ssZ format (e.g. 2024-01-31T12:00:00Z)", pkg.Metadata.Modified))
+	} else {
 		r.Add(report.Error, "OPF-019",
 			fmt.Sprintf("Invalid dcterms:modified value '%s': must be CCYY-MM-DDThh:mm:ssZ format", pkg.Metadata.Modified))
 	}
 }
 
-// OPF-020: dc:language must be a well-formed BCP 47 tag
-var bcp47Re = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{1,8})*$`)
+// OPF-020: dc:language must be a well-formed BCP 47 tag. Besides the usual
+// language-based tags this allows whole private-use tags (x-foo) and the
+// irregular grandfathered i- tags; the other grandfathered tags already
+// have the usual shape.
+var bcp47Re = regexp.MustCompile(`^(?:[a-zA-Z]{2,3}(-[a-zA-Z0-9]{1,8})*` +
+	`|[xX](-[a-zA-Z0-9]{1,8})+` +
+	`|[iI]-(?i:ami|bnn|default|enochian|hak|klingon|lux|mingo|navajo|pwn|tao|tay|tsu))$`)
+
+// WellFormedLanguageTag reports whether tag is a well-formed BCP 47
+// language tag.
+func WellFormedLanguageTag(tag string) bool {
+	return bcp47Re.MatchString(tag)
+}
 
 func checkDCLanguageValid(pkg *epub.Package, r *report.Report) {
 	for _, lang := range pkg.Metadata.Languages {
-		if !bcp47Re.MatchString(lang) {
-			r.Add(report.Error, "OPF-020",
-				fmt.Sprintf("Language tag '%s' is not well-formed according to BCP 47", lang))
+		norm := NormalizeLanguageTag(lang)
+		if !WellFormedLanguageTag(lang) {
+			msg := fmt.Sprintf("Language tag '%s' is not well-formed according to BCP 47", lang)
+			if norm != "" {
+				msg += fmt.Sprintf("; did you mean '%s'?", norm)
+			}
+			r.Add(report.Error, "OPF-020", msg)
+			continue
+		}
+		// OPF-076: well-formed but not in canonical form
+		if norm != "" && norm != lang {
+			r.Add(report.Warning, "OPF-076",
+				fmt.Sprintf("Language tag '%s' is not in canonical form; use '%s'", lang, norm))
+		}
+	}
+}
+
+// Language names and ISO 639-2 codes commonly used in place of the
+// shortest BCP 47 language subtag.
+var languageNames = map[string]string{
+	"english": "en", "french": "fr", "german": "de", "spanish": "es",
+	"italian": "it", "portuguese": "pt", "dutch": "nl", "russian": "ru",
+	"japanese": "ja", "chinese": "zh", "korean": "ko", "arabic": "ar",
+	"hebrew": "he", "greek": "el", "latin": "la", "polish": "pl",
+	"swedish": "sv", "danish": "da", "norwegian": "no", "finnish": "fi",
+	"turkish": "tr", "czech": "cs", "hungarian": "hu", "hindi": "hi",
+	"eng": "en", "fre": "fr", "fra": "fr", "ger": "de", "deu": "de",
+	"spa": "es", "ita": "it", "por": "pt", "dut": "nl", "nld": "nl",
+	"rus": "ru", "jpn": "ja", "chi": "zh", "zho": "zh",
+}
+
+// NormalizeLanguageTag returns the canonical BCP 47 form of a language tag,
+// fixing separators, case, and spelled-out language names. It returns ""
+// if the tag can't be turned into a well-formed one.
+func NormalizeLanguageTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	tag = strings.ReplaceAll(tag, "_", "-")
+	for strings.Contains(tag, "--") {
+		tag = strings.ReplaceAll(tag, "--", "-")
+	}
+	tag = strings.Trim(tag, "-")
+	if tag == "" {
+		return ""
+	}
+
+	subtags := strings.Split(tag, "-")
+	if code, ok := languageNames[strings.ToLower(subtags[0])]; ok {
+		subtags[0] = code
+	}
+	// A tag starting with a singleton (x-foo, i-klingon) has no language
+	// subtag, so it is lowercased throughout.
+	singleton := len(subtags[0]) == 1
+	for i, st := range subtags {
+		switch {
+		case i == 0 || singleton:
+			subtags[i] = strings.ToLower(st)
+		case len(st) == 1:
+			// extension or private-use singleton (u, x): what follows is
+			// not a script or region, so it is only lowercased
+			singleton = true
+			subtags[i] = strings.ToLower(st)
+		case len(st) == 4 && isAlpha(st):
+			// script subtag: Latn, Cyrl
+			subtags[i] = strings.ToUpper(st[:1]) + strings.ToLower(st[1:])
+		case len(st) == 2 && isAlpha(st):
+			// region subtag: US, GB
+			subtags[i] = strings.ToUpper(st)
+		default:
+			subtags[i] = strings.ToLower(st)
 		}
 	}
+
+	norm := strings.Join(subtags, "-")
+	if !WellFormedLanguageTag(norm) {
+		return ""
+	}
+	return norm
+}
+
+func isAlpha(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
 }
 
 // OPF-021: fallback must reference existing manifest item
@@ -398,6 +591,13 @@ var contentDocTypes = map[string]bool{
 	"image/svg+xml":         true,
 }
 
+// epub2ContentDocTypes are the additional OPS core document types EPUB 2
+// allows in the spine.
+var epub2ContentDocTypes = map[string]bool{
+	"application/x-dtbook+xml": true,
+	"text/x-oeb1-document":     true,
+}
+
 func checkSpineContentDocs(pkg *epub.Package, r *report.Report) {
 	manifestByID := make(map[string]epub.ManifestItem)
 	for _, item := range pkg.Manifest {
@@ -417,6 +617,9 @@ func checkSpineContentDocs(pkg *epub.Package, r *report.Report) {
 		if contentDocTypes[item.MediaType] {
 			continue
 		}
+		if pkg.Version < "3.0" && epub2ContentDocTypes[item.MediaType] {
+			continue
+		}
 		if !hasFallbackToContentDoc(item.ID, manifestByID) {
 			r.Add(report.Error, "OPF-023",
 				fmt.Sprintf("Spine item '%s' has non-standard media-type '%s' with no fallback to a content document", item.ID, item.MediaType))
@@ -468,6 +671,10 @@ func checkMediaTypeMatches(ep *epub.EPUB, r *report.Report) {
 		}
 
 		if item.MediaType != expectedType {
+			// Skip legacy font types for the same format - handled by FONT-004
+			if modernFontMediaType(item.MediaType, item.Href) == expectedType {
+				continue
+			}
 			// Skip image-to-image mismatches - handled by MED-001
 			if strings.HasPrefix(item.MediaType, "image/") && strings.HasPrefix(expectedType, "image/") {
 				continue
@@ -476,12 +683,100 @@ func checkMediaTypeMatches(ep *epub.EPUB, r *report.Report) {
 			if expectedType == "image/svg+xml" {
 				continue
 			}
-			r.Add(report.Error, "OPF-024",
-				fmt.Sprintf("The file '%s' does not appear to match the media type '%s'", item.Href, item.MediaType))
+			line, col := manifestItemPosition(ep, item.ID)
+			r.AddWithPosition(report.Error, "OPF-024",
+				fmt.Sprintf("The file '%s' does not appear to match the media type '%s'", item.Href, item.MediaType),
+				ep.RootfilePath, line, col)
+		}
+	}
+}
+
+// OPF-083: an image whose extension names another image format, such as
+// cover.png declared image/jpeg. Reading systems go by the media-type, but
+// tools and servers that go by the extension get it wrong. When the file's
+// magic bytes contradict the media-type that is MED-001 instead.
+func checkImageExtensionMatches(ep *epub.EPUB, r *report.Report) {
+	for _, item := range ep.Package.Manifest {
+		if item.Href == "\x00MISSING" || !strings.HasPrefix(item.MediaType, "image/") {
+			continue
+		}
+		expectedType := extensionToMediaType(strings.ToLower(path.Ext(item.Href)))
+		if !strings.HasPrefix(expectedType, "image/") || expectedType == item.MediaType {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		if _, exists := ep.Files[fullPath]; !exists {
+			continue
+		}
+		if data, err := ep.ReadFile(fullPath); err == nil {
+			if detected := detectImageType(data); detected != "" && detected != item.MediaType {
+				continue
+			}
+		}
+		line, col := manifestItemPosition(ep, item.ID)
+		r.AddWithPosition(report.Warning, "OPF-083",
+			fmt.Sprintf("The file extension of '%s' suggests '%s', but it is declared as '%s'", item.Href, expectedType, item.MediaType),
+			ep.RootfilePath, line, col)
+	}
+}
+
+// manifestItemPosition returns the line and column of the manifest <item>
+// with the given id in the package document, or 0, 0 if it is not found.
+func manifestItemPosition(ep *epub.EPUB, id string) (line, column int) {
+	data, err := ep.ReadFile(ep.RootfilePath)
+	if err != nil {
+		return 0, 0
+	}
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		offset := decoder.InputOffset()
+		tok, err := decoder.Token()
+		if err != nil {
+			return 0, 0
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "item" {
+			continue
+		}
+		for _, attr := range se.Attr {
+			if attr.Name.Local == "id" && attr.Value == id {
+				return lineColumn(data, int(offset))
+			}
 		}
 	}
 }
 
+// FONT-004: application/* font media types from before the font/*
+// registrations are still accepted, but EPUB 3.3 prefers the font/* forms.
+func checkLegacyFontMediaTypes(pkg *epub.Package, r *report.Report) {
+	for _, item := range pkg.Manifest {
+		if item.Href == "\x00MISSING" || item.MediaType == "\x00MISSING" {
+			continue
+		}
+		if modern := modernFontMediaType(item.MediaType, item.Href); modern != "" {
+			r.Add(report.Warning, "FONT-004",
+				fmt.Sprintf("Font '%s' uses legacy media type '%s'; use '%s' instead", item.Href, item.MediaType, modern))
+		}
+	}
+}
+
+// modernFontMediaType returns the font/* media type that replaces a legacy
+// font media type, or "" if mediaType is not a legacy font type.
+func modernFontMediaType(mediaType, href string) string {
+	switch mediaType {
+	case "application/vnd.ms-opentype":
+		return "font/otf"
+	case "application/font-woff":
+		return "font/woff"
+	case "application/font-sfnt":
+		if strings.ToLower(path.Ext(href)) == ".otf" {
+			return "font/otf"
+		}
+		return "font/ttf"
+	}
+	return ""
+}
+
 func extensionToMediaType(ext string) string {
 	switch ext {
 	case ".xhtml", ".html", ".htm":
@@ -534,17 +829,25 @@ var validManifestProperties = map[string]bool{
 
 // Valid spine itemref properties
 var validSpineProperties = map[string]bool{
-	"page-spread-left":        true,
-	"page-spread-right":       true,
-	"rendition:layout-pre-paginated": true,
-	"rendition:layout-reflowable":    true,
-	"rendition:orientation-auto":     true,
-	"rendition:orientation-landscape": true,
-	"rendition:orientation-portrait":  true,
-	"rendition:spread-auto":          true,
-	"rendition:spread-landscape":     true,
-	"rendition:spread-both":          true,
-	"rendition:spread-none":          true,
+	"page-spread-left":                   true,
+	"page-spread-right":                  true,
+	"rendition:layout-pre-paginated":     true,
+	"rendition:layout-reflowable":        true,
+	"rendition:orientation-auto":         true,
+	"rendition:orientation-landscape":    true,
+	"rendition:orientation-portrait":     true,
+	"rendition:spread-auto":              true,
+	"rendition:spread-landscape":         true,
+	"rendition:spread-both":              true,
+	"rendition:spread-none":              true,
+	"rendition:page-spread-left":         true,
+	"rendition:page-spread-right":        true,
+	"rendition:page-spread-center":       true,
+	"rendition:align-x-center":           true,
+	"rendition:flow-auto":                true,
+	"rendition:flow-paginated":           true,
+	"rendition:flow-scrolled-continuous": true,
+	"rendition:flow-scrolled-doc":        true,
 }
 
 // OPF-027: package element must have unique-identifier attribute
@@ -652,6 +955,77 @@ func checkCoverImageIsImage(pkg *epub.Package, r *report.Report) {
 	}
 }
 
+// rasterImageTypes are the image types reading systems can use directly
+// as a library thumbnail.
+var rasterImageTypes = map[string]bool{
+	"image/gif":  true,
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// OPF-060: reading systems take the cover from the cover-image manifest
+// property (EPUB 3) or <meta name="cover"> (EPUB 2), so a cover that is
+// declared twice, declared differently in each place, or not a raster image
+// shows up as a missing or wrong thumbnail. A book without a cover is fine.
+func checkCoverImageConsistency(pkg *epub.Package, r *report.Report) {
+	byID := make(map[string]epub.ManifestItem, len(pkg.Manifest))
+	var covers []epub.ManifestItem
+	for _, item := range pkg.Manifest {
+		byID[item.ID] = item
+		if hasProperty(item.Properties, "cover-image") {
+			covers = append(covers, item)
+		}
+	}
+	metaCovers := pkg.Metadata.NamedMeta["cover"]
+
+	if pkg.Version >= "3.0" {
+		switch {
+		case len(covers) > 1:
+			ids := make([]string, len(covers))
+			for i, item := range covers {
+				ids[i] = item.ID
+			}
+			r.Add(report.Warning, "OPF-060",
+				fmt.Sprintf("The cover-image property is declared on %d manifest items (%s); declare it on exactly one", len(covers), strings.Join(ids, ", ")))
+			return
+		case len(covers) == 0:
+			if len(metaCovers) == 0 {
+				return
+			}
+			r.Add(report.Warning, "OPF-060",
+				fmt.Sprintf("<meta name=\"cover\"> names '%s' but no manifest item has the cover-image property; EPUB 3 reading systems will not find the cover", metaCovers[0]))
+			return
+		}
+		cover := covers[0]
+		if len(metaCovers) > 0 && metaCovers[0] != cover.ID {
+			r.Add(report.Warning, "OPF-060",
+				fmt.Sprintf("<meta name=\"cover\"> names '%s' but the cover-image property is on '%s'", metaCovers[0], cover.ID))
+		}
+		// Non-image media types are reported by OPF-025.
+		if strings.HasPrefix(cover.MediaType, "image/") && !rasterImageTypes[cover.MediaType] {
+			r.Add(report.Warning, "OPF-060",
+				fmt.Sprintf("The cover image '%s' has media type '%s'; use a JPEG or PNG so reading systems can show it as a thumbnail", cover.Href, cover.MediaType))
+		}
+		return
+	}
+
+	switch {
+	case len(metaCovers) > 1:
+		r.Add(report.Warning, "OPF-060",
+			fmt.Sprintf("<meta name=\"cover\"> occurs %d times; declare the cover exactly once", len(metaCovers)))
+	case len(metaCovers) == 1:
+		item, ok := byID[metaCovers[0]]
+		if !ok {
+			r.Add(report.Warning, "OPF-060",
+				fmt.Sprintf("<meta name=\"cover\"> names '%s', which is not the id of a manifest item", metaCovers[0]))
+		} else if !rasterImageTypes[item.MediaType] {
+			r.Add(report.Warning, "OPF-060",
+				fmt.Sprintf("<meta name=\"cover\"> names '%s', which has media type '%s' rather than a raster image type", item.ID, item.MediaType))
+		}
+	}
+}
+
 // OPF-035: page-progression-direction must be ltr, rtl, or default
 func checkPageProgressionDirection(pkg *epub.Package, r *report.Report) {
 	if pkg.Version < "3.0" || pkg.PageProgressionDirection == "" {
@@ -716,7 +1090,7 @@ func checkSpineLinearValid(pkg *epub.Package, r *report.Report) {
 		}
 		if ref.Linear != "yes" && ref.Linear != "no" {
 			r.Add(report.Error, "OPF-038",
-				fmt.Sprintf("The spine itemref linear attribute value '%s' must be equal to 'yes' or 'no'", ref.Linear))
+				fmt.Sprintf("The linear attribute value '%s' on spine itemref '%s' must be equal to 'yes' or 'no'", ref.Linear, ref.IDRef))
 		}
 	}
 }
@@ -843,3 +1217,95 @@ func checkMediaOverlayRef(pkg *epub.Package, r *report.Report) {
 		}
 	}
 }
+
+// Common misspellings of core media types and the value they were meant to be.
+var mediaTypeTypos = map[string]string{
+	"application/xhtml":        "application/xhtml+xml",
+	"application/xhtml-xml":    "application/xhtml+xml",
+	"application/html+xml":     "application/xhtml+xml",
+	"application/xml+xhtml":    "application/xhtml+xml",
+	"text/xhtml":               "application/xhtml+xml",
+	"text/xhtml+xml":           "application/xhtml+xml",
+	"image/jpg":                "image/jpeg",
+	"image/pjpeg":              "image/jpeg",
+	"image/x-png":              "image/png",
+	"image/svg":                "image/svg+xml",
+	"image/svg-xml":            "image/svg+xml",
+	"text/svg+xml":             "image/svg+xml",
+	"application/x-dtbncx":     "application/x-dtbncx+xml",
+	"application/ncx+xml":      "application/x-dtbncx+xml",
+	"application/x-dtbncx-xml": "application/x-dtbncx+xml",
+	"text/stylesheet":          "text/css",
+	"application/css":          "text/css",
+	"application/x-javascript": "application/javascript",
+	"application/x-font-ttf":   "font/ttf",
+	"application/x-font-otf":   "font/otf",
+	"application/x-font-woff":  "font/woff",
+	"font/opentype":            "font/otf",
+	"audio/mp3":                "audio/mpeg",
+	"audio/x-mp3":              "audio/mpeg",
+	"application/smil":         "application/smil+xml",
+}
+
+// SuggestMediaType returns the core media type a manifest item most likely
+// meant to declare, or "" if the declared value doesn't look like a typo.
+// It is what OPF-074 reports.
+func SuggestMediaType(mediaType, href string) string {
+	mt := strings.ToLower(strings.TrimSpace(mediaType))
+	if coreMediaTypes[mt] {
+		if mt != mediaType {
+			return mt
+		}
+		return ""
+	}
+	if s, ok := mediaTypeTypos[mt]; ok {
+		return s
+	}
+	// Generic XML types are only a typo when the extension says what was meant
+	if mt == "text/xml" || mt == "application/xml" || mt == "text/html" {
+		if s := extensionToMediaType(strings.ToLower(path.Ext(href))); coreMediaTypes[s] && strings.HasSuffix(s, "+xml") {
+			return s
+		}
+	}
+	return ""
+}
+
+// OPF-074: manifest media-type looks like a typo of a core media type
+func checkMediaTypeTypos(pkg *epub.Package, r *report.Report) {
+	for _, item := range pkg.Manifest {
+		if item.Href == "\x00MISSING" || item.MediaType == "\x00MISSING" {
+			continue
+		}
+		if s := SuggestMediaType(item.MediaType, item.Href); s != "" {
+			r.Add(report.Warning, "OPF-074",
+				fmt.Sprintf("Manifest item '%s' has media type '%s'; did you mean '%s'?", item.Href, item.MediaType, s))
+		}
+	}
+}
+
+// OPF-081: manifest properties with repeated tokens or stray whitespace.
+// Reading systems split on whitespace so these work, but duplicates point
+// to careless editing and some tools compare the raw string.
+func checkPropertiesTokens(pkg *epub.Package, r *report.Report) {
+	for _, item := range pkg.Manifest {
+		if item.Properties == "" {
+			continue
+		}
+		tokens := strings.Fields(item.Properties)
+		seen := make(map[string]bool)
+		var dups []string
+		for _, t := range tokens {
+			if seen[t] {
+				dups = append(dups, t)
+			}
+			seen[t] = true
+		}
+		if len(dups) > 0 {
+			r.Add(report.Warning, "OPF-081",
+				fmt.Sprintf("Manifest item '%s' repeats properties '%s' in '%s'", item.ID, strings.Join(dups, " "), item.Properties))
+		} else if strings.Join(tokens, " ") != item.Properties {
+			r.Add(report.Info, "OPF-081",
+				fmt.Sprintf("Manifest item '%s' has extra whitespace in properties '%s'", item.ID, item.Properties))
+		}
+	}
+}
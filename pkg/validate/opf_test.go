@@ -0,0 +1,486 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adammathes/epubverify/pkg/epub"
+	"github.com/adammathes/epubverify/pkg/report"
+)
+
+func TestSuggestMediaType(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		href      string
+		want      string
+	}{
+		{"image/jpg", "images/cover.jpg", "image/jpeg"},
+		{"application/xhtml", "chapter1.xhtml", "application/xhtml+xml"},
+		{"text/xml", "chapter1.xhtml", "application/xhtml+xml"},
+		{"text/xml", "toc.ncx", "application/x-dtbncx+xml"},
+		{"text/xml", "data.xml", ""},
+		{"Image/JPEG", "cover.jpg", "image/jpeg"},
+		{"image/jpeg", "cover.jpg", ""},
+		{"application/x-custom", "data.bin", ""},
+	}
+	for _, tt := range tests {
+		if got := SuggestMediaType(tt.mediaType, tt.href); got != tt.want {
+			t.Errorf("SuggestMediaType(%q, %q) = %q, want %q", tt.mediaType, tt.href, got, tt.want)
+		}
+	}
+}
+
+func TestMediaTypeTypos(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="img" href="images/photo.png" media-type="image/x-png"/>
+`),
+		"OEBPS/images/photo.png": "\x89PNG\r\n\x1a\n",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "OPF-074") {
+		t.Error("expected OPF-074 for image/x-png")
+	}
+}
+
+func TestNormalizeLanguageTag(t *testing.T) {
+	tests := []struct {
+		tag, want string
+	}{
+		{"en", "en"},
+		{"english", "en"},
+		{"English", "en"},
+		{"EN_us", "en-US"},
+		{"en-US-", "en-US"},
+		{"zh-hant-tw", "zh-Hant-TW"},
+		{"eng", "en"},
+		{"fr-CA", "fr-CA"},
+		{"en-x-ab", "en-x-ab"},
+		{"de-DE-u-co-phonebk", "de-DE-u-co-phonebk"},
+		{"en-a-bc-de", "en-a-bc-de"},
+		{"EN-us-X-Abcd", "en-US-x-abcd"},
+		{"x-foo", "x-foo"},
+		{"X-Foo", "x-foo"},
+		{"i-klingon", "i-klingon"},
+		{"I-Klingon", "i-klingon"},
+		{"i-foo", ""},
+		{"x", ""},
+		{"klingon!", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeLanguageTag(tt.tag); got != tt.want {
+			t.Errorf("NormalizeLanguageTag(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestDCLanguageCanonical(t *testing.T) {
+	opf := strings.Replace(testOPF(""), "<dc:language>en</dc:language>", "<dc:language>EN-us</dc:language>", 1)
+	path := writeTestEPUB(t, map[string]string{"OEBPS/content.opf": opf})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "OPF-020") {
+		t.Error("EN-us is well-formed and should not be reported as OPF-020")
+	}
+	if !hasCheck(r, "OPF-076") {
+		t.Error("expected OPF-076 for non-canonical EN-us")
+	}
+}
+
+func TestDCLanguageMalformed(t *testing.T) {
+	tests := []struct{ lang, suggestion string }{
+		{"en_US", "en-US"},
+		{"english", "en"},
+	}
+	for _, tt := range tests {
+		opf := strings.Replace(testOPF(""), "<dc:language>en</dc:language>", "<dc:language>"+tt.lang+"</dc:language>", 1)
+		path := writeTestEPUB(t, map[string]string{"OEBPS/content.opf": opf})
+
+		r, err := Validate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, m := range r.Messages {
+			if m.CheckID == "OPF-020" {
+				found = true
+				if !strings.Contains(m.Message, "did you mean '"+tt.suggestion+"'") {
+					t.Errorf("%s: OPF-020 message %q should suggest %q", tt.lang, m.Message, tt.suggestion)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: expected OPF-020", tt.lang)
+		}
+	}
+}
+
+func TestDCIdentifierUnique(t *testing.T) {
+	pkg := &epub.Package{Metadata: epub.Metadata{Identifiers: []epub.DCIdentifier{
+		{ID: "uid", Value: "urn:isbn:9780000000001"},
+		{Value: " urn:isbn:9780000000001 "},
+		{Value: "urn:isbn:9780000000001"},
+		{Value: "urn:isbn:9780000000002"},
+		{Value: ""},
+		{Value: ""},
+	}}}
+	r := report.NewReport()
+	checkDCIdentifierUnique(pkg, r)
+	if len(r.Messages) != 1 || r.Messages[0].CheckID != "OPF-082" || r.Messages[0].Severity != report.Warning {
+		t.Fatalf("got %v, want a single OPF-082 warning", r.Messages)
+	}
+	if !strings.Contains(r.Messages[0].Message, "urn:isbn:9780000000001") {
+		t.Errorf("message %q should name the duplicated value", r.Messages[0].Message)
+	}
+
+	r = report.NewReport()
+	checkDCIdentifierUnique(&epub.Package{Metadata: epub.Metadata{Identifiers: pkg.Metadata.Identifiers[3:4]}}, r)
+	if hasCheck(r, "OPF-082") {
+		t.Error("a single identifier should not be reported")
+	}
+}
+
+func TestImageExtensionMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		notWant string
+	}{
+		{"jpeg named .png", string(jpegMagic) + "\xe0 jpeg data", "OPF-083", "MED-001"},
+		{"png declared as jpeg", string(pngMagic) + " png data", "MED-001", "OPF-083"},
+		{"unrecognised bytes", "not an image header", "OPF-083", "MED-001"},
+	}
+	for _, tt := range tests {
+		path := writeTestEPUB(t, map[string]string{
+			"OEBPS/content.opf": testOPF(`    <item id="img" href="cover.png" media-type="image/jpeg"/>
+`),
+			"OEBPS/cover.png": tt.data,
+		})
+		r, err := Validate(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hasCheck(r, tt.want) || hasCheck(r, tt.notWant) {
+			t.Errorf("%s: want %s and no %s, got %v", tt.name, tt.want, tt.notWant, r.Messages)
+		}
+	}
+}
+
+func TestDCTermsModifiedLenient(t *testing.T) {
+	tests := []struct {
+		value string
+		opts  Options
+		want  report.Severity
+	}{
+		{"2024-01-01T00:00:00Z", Options{LenientTimestamps: true}, ""},
+		{"2024-01-01T00:00:00", Options{}, report.Error},
+		{"2024-01-01T00:00:00", Options{LenientTimestamps: true}, report.Warning},
+		{"2024-01-01T00:00:00.123Z", Options{LenientTimestamps: true}, report.Warning},
+		{"2024-01-01T00:00:00+02:00", Options{LenientTimestamps: true}, report.Warning},
+		{"2024-01-01T00:00:00+02:00", Options{LenientTimestamps: true, Strict: true}, report.Error},
+		{"January 1, 2024", Options{LenientTimestamps: true}, report.Error},
+	}
+	for _, tt := range tests {
+		pkg := &epub.Package{Version: "3.0", Metadata: epub.Metadata{Modified: tt.value}}
+		r := report.NewReport()
+		checkDCTermsModifiedFormat(pkg, r, tt.opts)
+		var got report.Severity
+		for _, m := range r.Messages {
+			if m.CheckID == "OPF-019" {
+				got = m.Severity
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%q with %+v: severity %q, want %q", tt.value, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestSpineLinear(t *testing.T) {
+	tests := []struct {
+		name    string
+		spine   []epub.SpineItemref
+		checkID string
+		want    bool
+	}{
+		{"valid values", []epub.SpineItemref{{IDRef: "a", Linear: "yes"}, {IDRef: "b", Linear: "no"}, {IDRef: "c"}}, "OPF-038", false},
+		{"invalid value", []epub.SpineItemref{{IDRef: "a", Linear: "true"}}, "OPF-038", true},
+		{"all explicitly non-linear", []epub.SpineItemref{{IDRef: "a", Linear: "no"}, {IDRef: "b", Linear: "no"}}, "OPF-041", true},
+		{"implicit linear", []epub.SpineItemref{{IDRef: "a", Linear: "no"}, {IDRef: "b"}}, "OPF-041", false},
+	}
+	for _, tt := range tests {
+		pkg := &epub.Package{Version: "3.0", Spine: tt.spine}
+		r := report.NewReport()
+		checkSpineLinearValid(pkg, r)
+		checkSpineHasLinear(pkg, r)
+		if got := hasCheck(r, tt.checkID); got != tt.want {
+			t.Errorf("%s: %s = %v, want %v", tt.name, tt.checkID, got, tt.want)
+		}
+	}
+
+	r := report.NewReport()
+	checkSpineLinearValid(&epub.Package{Spine: []epub.SpineItemref{{IDRef: "ch3", Linear: "false"}}}, r)
+	if len(r.Messages) != 1 || !strings.Contains(r.Messages[0].Message, "'ch3'") || !strings.Contains(r.Messages[0].Message, "'false'") {
+		t.Errorf("OPF-038 should name the itemref and value, got %v", r.Messages)
+	}
+}
+
+func TestRootfileIsPackage(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{"OEBPS/content.opf": testChapterXHTML})
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "OPF-080") || r.FatalCount() != 1 {
+		t.Errorf("expected a single OPF-080 fatal for an XHTML rootfile, got %v", r.Messages)
+	}
+	if hasCheck(r, "OPF-012") {
+		t.Error("missing-element errors should not cascade after OPF-080")
+	}
+
+	path = writeTestEPUB(t, map[string]string{"OEBPS/content.opf": `<?xml version="1.0"?><package`})
+	r, err = Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, m := range r.Messages {
+		if m.CheckID == "OPF-011" {
+			found = strings.HasPrefix(m.Message, "Could not parse package document: XML document structures must start and end within the same entity") &&
+				strings.Contains(m.Message, "OEBPS/content.opf")
+		}
+	}
+	if !found {
+		t.Errorf("expected OPF-011 naming the package document, got %v", r.Messages)
+	}
+}
+
+func TestPropertiesTokens(t *testing.T) {
+	tests := []struct {
+		props string
+		want  report.Severity
+	}{
+		{"nav", ""},
+		{"nav scripted", ""},
+		{"nav nav", report.Warning},
+		{"nav  scripted", report.Info},
+		{"\tnav ", report.Info},
+	}
+	for _, tt := range tests {
+		pkg := &epub.Package{Manifest: []epub.ManifestItem{{ID: "nav", Href: "nav.xhtml", Properties: tt.props}}}
+		r := report.NewReport()
+		checkPropertiesTokens(pkg, r)
+		var got report.Severity
+		if len(r.Messages) > 0 {
+			got = r.Messages[0].Severity
+		}
+		if got != tt.want {
+			t.Errorf("properties %q: severity %q, want %q", tt.props, got, tt.want)
+		}
+	}
+}
+
+func TestLegacyFontMediaTypes(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="f1" href="fonts/a.otf" media-type="application/vnd.ms-opentype"/>
+    <item id="f2" href="fonts/b.woff" media-type="application/font-woff"/>
+    <item id="f3" href="fonts/c.ttf" media-type="font/ttf"/>
+`),
+		"OEBPS/fonts/a.otf":  "OTTO",
+		"OEBPS/fonts/b.woff": "wOFF",
+		"OEBPS/fonts/c.ttf":  "\x00\x01\x00\x00",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "OPF-024") {
+		t.Error("legacy font media types should not be reported as OPF-024 mismatches")
+	}
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "FONT-004" {
+			got = append(got, m.Message)
+		}
+	}
+	if len(got) != 2 || !strings.Contains(got[0], "'font/otf'") || !strings.Contains(got[1], "'font/woff'") {
+		t.Errorf("expected FONT-004 suggesting font/otf and font/woff, got %q", got)
+	}
+}
+
+func TestMediaTypeMismatchPosition(t *testing.T) {
+	opf := testOPF(`    <item id="css" href="style.css" media-type="text/plain"/>
+`)
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": opf,
+		"OEBPS/style.css":   "p { margin: 0; }",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := strings.Index(opf, `<item id="css"`)
+	wantLine := strings.Count(opf[:idx], "\n") + 1
+	wantCol := idx - strings.LastIndex(opf[:idx], "\n")
+	for _, m := range r.Messages {
+		if m.CheckID != "OPF-024" {
+			continue
+		}
+		if m.Location != "OEBPS/content.opf" || m.Line != wantLine || m.Column != wantCol {
+			t.Errorf("OPF-024 at %s:%d:%d, want OEBPS/content.opf:%d:%d", m.Location, m.Line, m.Column, wantLine, wantCol)
+		}
+		return
+	}
+	t.Error("expected OPF-024 for a stylesheet declared as text/plain")
+}
+
+func TestDuplicateManifestIDs(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="img" href="a.png" media-type="image/png"/>
+    <item id="img" href="b.png" media-type="image/png"/>
+    <item href="c.png" media-type="image/png"/>
+    <item href="d.png" media-type="image/png"/>
+`),
+		"OEBPS/a.png": "\x89PNG\r\n\x1a\n",
+		"OEBPS/b.png": "\x89PNG\r\n\x1a\n",
+		"OEBPS/c.png": "\x89PNG\r\n\x1a\n",
+		"OEBPS/d.png": "\x89PNG\r\n\x1a\n",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "OPF-005" {
+			got = append(got, m.Message)
+		}
+	}
+	if len(got) != 1 || !strings.Contains(got[0], "'a.png'") || !strings.Contains(got[0], "'b.png'") {
+		t.Errorf("expected one OPF-005 naming a.png and b.png, got %q", got)
+	}
+}
+
+func TestSpineContentDocTypes(t *testing.T) {
+	tests := []struct {
+		version   string
+		mediaType string
+		want      bool
+	}{
+		{"3.0", "application/xhtml+xml", false},
+		{"3.0", "image/svg+xml", false},
+		{"3.0", "text/css", true},
+		{"3.0", "application/x-dtbook+xml", true},
+		{"2.0", "application/x-dtbook+xml", false},
+		{"2.0", "text/x-oeb1-document", false},
+		{"2.0", "image/png", true},
+	}
+	for _, tt := range tests {
+		pkg := &epub.Package{
+			Version:  tt.version,
+			Manifest: []epub.ManifestItem{{ID: "s", Href: "s.bin", MediaType: tt.mediaType, HasID: true}},
+			Spine:    []epub.SpineItemref{{IDRef: "s"}},
+		}
+		r := report.NewReport()
+		checkSpineContentDocs(pkg, r)
+		if got := hasCheck(r, "OPF-023"); got != tt.want {
+			t.Errorf("EPUB %s spine item %s: OPF-023 = %v, want %v", tt.version, tt.mediaType, got, tt.want)
+			continue
+		}
+		if tt.want && !strings.Contains(r.Messages[0].Message, "'s'") {
+			t.Errorf("OPF-023 should name the itemref: %s", r.Messages[0].Message)
+		}
+	}
+}
+
+func TestUniqueIdentifierResolves(t *testing.T) {
+	tests := []struct {
+		uid  string
+		want string
+	}{
+		{"uid", ""},
+		{"bookid", "(identifier ids: 'uid', 'isbn')"},
+	}
+	for _, tt := range tests {
+		pkg := &epub.Package{
+			UniqueIdentifier: tt.uid,
+			Metadata: epub.Metadata{Identifiers: []epub.DCIdentifier{
+				{ID: "uid", Value: "urn:uuid:1"},
+				{ID: "isbn", Value: "9780000000000"},
+				{Value: "no-id"},
+			}},
+		}
+		r := report.NewReport()
+		checkUniqueIdentifierResolves(pkg, r)
+		if tt.want == "" {
+			if hasCheck(r, "OPF-008") {
+				t.Errorf("unique-identifier %q: unexpected OPF-008 %v", tt.uid, r.Messages)
+			}
+			continue
+		}
+		if !hasCheck(r, "OPF-008") || !strings.Contains(r.Messages[0].Message, tt.want) {
+			t.Errorf("unique-identifier %q: want OPF-008 listing %s, got %v", tt.uid, tt.want, r.Messages)
+		}
+	}
+}
+
+func TestCoverImageConsistency(t *testing.T) {
+	png := epub.ManifestItem{ID: "cover", Href: "cover.png", MediaType: "image/png", Properties: "cover-image"}
+	svg := epub.ManifestItem{ID: "cover", Href: "cover.svg", MediaType: "image/svg+xml", Properties: "cover-image"}
+	other := epub.ManifestItem{ID: "back", Href: "back.jpg", MediaType: "image/jpeg", Properties: "cover-image"}
+	page := epub.ManifestItem{ID: "ch1", Href: "chapter1.xhtml", MediaType: "application/xhtml+xml"}
+	plain := png
+	plain.Properties = ""
+
+	tests := []struct {
+		name     string
+		version  string
+		manifest []epub.ManifestItem
+		meta     []string // <meta name="cover"> values
+		want     report.Severity
+		contains string
+	}{
+		{"epub3 single cover", "3.0", []epub.ManifestItem{png, page}, nil, "", ""},
+		{"epub3 matching meta", "3.0", []epub.ManifestItem{png}, []string{"cover"}, "", ""},
+		{"epub3 no cover", "3.0", []epub.ManifestItem{page}, nil, "", ""},
+		{"epub3 duplicate", "3.0", []epub.ManifestItem{png, other}, nil, report.Warning, "2 manifest items (cover, back)"},
+		{"epub3 meta only", "3.0", []epub.ManifestItem{plain}, []string{"cover"}, report.Warning, "no manifest item has the cover-image property"},
+		{"epub3 meta mismatch", "3.0", []epub.ManifestItem{plain, other}, []string{"cover"}, report.Warning, "property is on 'back'"},
+		{"epub3 svg", "3.0", []epub.ManifestItem{svg}, nil, report.Warning, "image/svg+xml"},
+		{"epub2 meta", "2.0", []epub.ManifestItem{plain}, []string{"cover"}, "", ""},
+		{"epub2 no meta", "2.0", []epub.ManifestItem{plain}, nil, "", ""},
+		{"epub2 unknown id", "2.0", []epub.ManifestItem{plain}, []string{"cover.png"}, report.Warning, "not the id of a manifest item"},
+		{"epub2 not an image", "2.0", []epub.ManifestItem{plain, page}, []string{"ch1"}, report.Warning, "application/xhtml+xml"},
+		{"epub2 repeated", "2.0", []epub.ManifestItem{plain}, []string{"cover", "cover"}, report.Warning, "occurs 2 times"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := &epub.Package{Version: tt.version, Manifest: tt.manifest}
+			if tt.meta != nil {
+				pkg.Metadata.NamedMeta = map[string][]string{"cover": tt.meta}
+			}
+			r := report.NewReport()
+			checkCoverImageConsistency(pkg, r)
+			if tt.want == "" {
+				if len(r.Messages) != 0 {
+					t.Errorf("expected no OPF-060, got %v", r.Messages)
+				}
+				return
+			}
+			if len(r.Messages) != 1 || r.Messages[0].CheckID != "OPF-060" || r.Messages[0].Severity != tt.want || !strings.Contains(r.Messages[0].Message, tt.contains) {
+				t.Errorf("got %v, want one %s OPF-060 containing %q", r.Messages, tt.want, tt.contains)
+			}
+		})
+	}
+}
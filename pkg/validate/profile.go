@@ -0,0 +1,130 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adammathes/epubverify/pkg/epub"
+)
+
+// BookProfile summarizes the structure of an EPUB for corpus analytics.
+// It is built from the container and package document alone, without
+// running content validation.
+type BookProfile struct {
+	Version            string         `json:"version"`
+	Rootfiles          int            `json:"rootfiles"`
+	SpineItems         int            `json:"spine_items"`
+	ManifestItems      int            `json:"manifest_items"`
+	ResourceCounts     map[string]int `json:"resource_counts"`
+	TotalSize          int64          `json:"total_size"`
+	CompressedSize     int64          `json:"compressed_size"`
+	FixedLayout        bool           `json:"fixed_layout"`
+	HasMediaOverlays   bool           `json:"has_media_overlays"`
+	HasScripts         bool           `json:"has_scripts"`
+	HasRemoteResources bool           `json:"has_remote_resources"`
+}
+
+// Profile returns the structural profile of the EPUB at path.
+func Profile(path string) (*BookProfile, error) {
+	ep, err := epub.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer ep.Close()
+	return profileEPUB(ep)
+}
+
+// ProfileBytes returns the structural profile of an EPUB held in memory.
+func ProfileBytes(data []byte) (*BookProfile, error) {
+	ep, err := epub.OpenFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	defer ep.Close()
+	return profileEPUB(ep)
+}
+
+func profileEPUB(ep *epub.EPUB) (*BookProfile, error) {
+	if err := ep.ParseContainer(); err != nil {
+		return nil, err
+	}
+	if ep.RootfilePath == "" {
+		return nil, fmt.Errorf("container.xml does not contain a rootfile element")
+	}
+	if err := ep.ParseOPF(); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ep.RootfilePath, err)
+	}
+	pkg := ep.Package
+
+	p := &BookProfile{
+		Version:        pkg.Version,
+		Rootfiles:      len(ep.AllRootfiles),
+		SpineItems:     len(pkg.Spine),
+		ManifestItems:  len(pkg.Manifest),
+		ResourceCounts: make(map[string]int),
+		FixedLayout:    pkg.RenditionLayout == "pre-paginated",
+	}
+
+	for _, f := range ep.Zip.File {
+		p.TotalSize += int64(f.UncompressedSize64)
+		p.CompressedSize += int64(f.CompressedSize64)
+	}
+
+	for _, item := range pkg.Manifest {
+		if item.MediaType != "\x00MISSING" {
+			p.ResourceCounts[resourceKind(item.MediaType)]++
+		}
+		if item.MediaOverlay != "" || item.MediaType == "application/smil+xml" {
+			p.HasMediaOverlays = true
+		}
+		if hasProperty(item.Properties, "scripted") ||
+			item.MediaType == "application/javascript" || item.MediaType == "text/javascript" {
+			p.HasScripts = true
+		}
+		if hasProperty(item.Properties, "remote-resources") || isRemoteURL(item.Href) {
+			p.HasRemoteResources = true
+		}
+	}
+
+	// A reflowable book can still lay out every spine item as pre-paginated
+	if !p.FixedLayout && len(pkg.Spine) > 0 {
+		allFixed := true
+		for _, ref := range pkg.Spine {
+			if !hasProperty(ref.Properties, "rendition:layout-pre-paginated") {
+				allFixed = false
+				break
+			}
+		}
+		p.FixedLayout = allFixed
+	}
+
+	return p, nil
+}
+
+// resourceKind groups a media type into a coarse resource category.
+func resourceKind(mediaType string) string {
+	switch {
+	case mediaType == "application/xhtml+xml" || mediaType == "text/html":
+		return "xhtml"
+	case mediaType == "image/svg+xml":
+		return "svg"
+	case mediaType == "text/css":
+		return "css"
+	case mediaType == "application/x-dtbncx+xml":
+		return "ncx"
+	case mediaType == "application/smil+xml":
+		return "smil"
+	case mediaType == "application/javascript" || mediaType == "text/javascript":
+		return "script"
+	case isFontMediaType(mediaType):
+		return "font"
+	case strings.HasPrefix(mediaType, "image/"):
+		return "image"
+	case strings.HasPrefix(mediaType, "audio/"):
+		return "audio"
+	case strings.HasPrefix(mediaType, "video/"):
+		return "video"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,38 @@
+package validate
+
+import "testing"
+
+func TestProfile(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="css" href="style.css" media-type="text/css"/>
+    <item id="img" href="cover.png" media-type="image/png"/>
+    <item id="js" href="app.js" media-type="application/javascript"/>
+`),
+		"OEBPS/style.css": "p { margin: 0; }",
+		"OEBPS/cover.png": "\x89PNG\r\n\x1a\n",
+		"OEBPS/app.js":    "",
+	})
+
+	p, err := Profile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != "3.0" {
+		t.Errorf("Version = %q, want 3.0", p.Version)
+	}
+	if p.SpineItems != 1 || p.ManifestItems != 5 {
+		t.Errorf("SpineItems = %d, ManifestItems = %d, want 1 and 5", p.SpineItems, p.ManifestItems)
+	}
+	want := map[string]int{"xhtml": 2, "css": 1, "image": 1, "script": 1}
+	for kind, n := range want {
+		if p.ResourceCounts[kind] != n {
+			t.Errorf("ResourceCounts[%q] = %d, want %d", kind, p.ResourceCounts[kind], n)
+		}
+	}
+	if !p.HasScripts || p.FixedLayout || p.HasMediaOverlays || p.HasRemoteResources {
+		t.Errorf("unexpected flags: %+v", p)
+	}
+	if p.TotalSize == 0 {
+		t.Error("TotalSize should be non-zero")
+	}
+}
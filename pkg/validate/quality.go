@@ -0,0 +1,488 @@
+package validate
+
+import (
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/adammathes/epubverify/pkg/epub"
+	"github.com/adammathes/epubverify/pkg/report"
+)
+
+// checkQuality runs heuristic packaging and editorial checks. These catch
+// problems that are legal per the spec but cause trouble downstream, and
+// are off by default to keep false positives out of normal validation.
+func checkQuality(ep *epub.EPUB, r *report.Report, opts Options) {
+	// OCF-024: many (or many tiny) files add zip overhead
+	checkResourceCount(ep, r, opts)
+
+	// RSC-015: byte-identical files stored under different names
+	checkDuplicateResources(ep, r)
+
+	if ep.Package == nil {
+		return
+	}
+
+	// OPF-075: unique-identifier should be stable across builds
+	checkStableUniqueIdentifier(ep.Package, r)
+
+	// NAV-020: toc labels should resemble the title of the document they link to
+	checkNavLabelsMatchTargets(ep, r)
+
+	// HTM-037: pathologically deep element nesting
+	checkContentDepth(ep, r, opts)
+
+	// COVER-004: the book should open on its cover
+	checkCoverFirst(ep, r)
+}
+
+const (
+	// defaultResourceLimit is the file count above which OCF-024 suggests
+	// consolidating resources, when Options.ResourceLimit is unset.
+	defaultResourceLimit = 1000
+
+	// tinyFileSize is the uncompressed size under which a file counts as tiny.
+	tinyFileSize = 512
+
+	// tinyFileLimit is the number of tiny files above which OCF-024 fires.
+	tinyFileLimit = 200
+
+	// defaultMaxDepth is the element nesting depth above which HTM-037
+	// fires, when Options.MaxDepth is unset.
+	defaultMaxDepth = 100
+)
+
+// OCF-024: a container split into very many files, or very many tiny
+// ones, bloats the zip directory and slows down some reading systems.
+func checkResourceCount(ep *epub.EPUB, r *report.Report, opts Options) {
+	limit := opts.ResourceLimit
+	if limit <= 0 {
+		limit = defaultResourceLimit
+	}
+
+	total, tiny := 0, 0
+	for _, f := range ep.Zip.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		total++
+		if f.UncompressedSize64 < tinyFileSize {
+			tiny++
+		}
+	}
+
+	if total > limit {
+		r.Add(report.Warning, "OCF-024",
+			fmt.Sprintf("Container holds %d files (more than %d); consider consolidating resources", total, limit))
+	} else if tiny > tinyFileLimit {
+		r.Add(report.Warning, "OCF-024",
+			fmt.Sprintf("Container holds %d files smaller than %d bytes; consider consolidating them", tiny, tinyFileSize))
+	}
+}
+
+// OPF-075: the unique-identifier should not look like a value regenerated
+// on every build (a file path, an embedded timestamp, or a calibre id).
+// Random UUIDs alone are not flagged since they are usually assigned once.
+func checkStableUniqueIdentifier(pkg *epub.Package, r *report.Report) {
+	if pkg.UniqueIdentifier == "" {
+		return
+	}
+	var ident *epub.DCIdentifier
+	for i := range pkg.Metadata.Identifiers {
+		if pkg.Metadata.Identifiers[i].ID == pkg.UniqueIdentifier {
+			ident = &pkg.Metadata.Identifiers[i]
+			break
+		}
+	}
+	if ident == nil || ident.Value == "" {
+		return
+	}
+
+	if reason := volatileIdentifierReason(ident.ID, ident.Value); reason != "" {
+		r.Add(report.Warning, "OPF-075",
+			fmt.Sprintf("Unique identifier '%s' %s; use a value that stays the same across builds and editions", ident.Value, reason))
+	}
+}
+
+var (
+	volatilePathRe = regexp.MustCompile(`^(file:|/(tmp|var|home|users|private)/|[a-z]:\\)`)
+
+	// Compact or ISO timestamps down to the second, e.g. 20240101120000 or
+	// 2024-01-01T12:00:00. A bare date is common in stable ids and is allowed.
+	buildTimestampRe = regexp.MustCompile(`(^|[^0-9])(19|20)\d{2}(\d{4}|-\d{2}-\d{2})[T_-]?\d{2}:?\d{2}:?\d{2}([^0-9]|$)`)
+)
+
+// volatileIdentifierReason returns why an identifier looks volatile, or ""
+// if it looks stable.
+func volatileIdentifierReason(id, value string) string {
+	lower := strings.ToLower(value)
+
+	if volatilePathRe.MatchString(lower) || strings.Contains(lower, `\temp\`) || strings.Contains(lower, "/tmp/") {
+		return "looks like a file path"
+	}
+
+	if strings.HasPrefix(lower, "calibre:") || strings.EqualFold(id, "calibre_id") {
+		return "is a calibre library id that changes when the book is re-imported"
+	}
+
+	if buildTimestampRe.MatchString(value) {
+		return "contains a build timestamp"
+	}
+
+	return ""
+}
+
+// RSC-015: byte-identical files stored under several paths waste space;
+// the manifest can point every reference at a single copy instead.
+func checkDuplicateResources(ep *epub.EPUB, r *report.Report) {
+	// Group by size and CRC-32 from the zip directory first, so only likely
+	// duplicates are read and hashed.
+	type key struct {
+		size uint64
+		crc  uint32
+	}
+	candidates := make(map[key][]string)
+	for _, f := range ep.Zip.File {
+		if f.UncompressedSize64 == 0 || strings.HasSuffix(f.Name, "/") ||
+			f.Name == "mimetype" || strings.HasPrefix(f.Name, "META-INF/") {
+			continue
+		}
+		k := key{f.UncompressedSize64, f.CRC32}
+		candidates[k] = append(candidates[k], f.Name)
+	}
+
+	var groups [][]string
+	var sizes []uint64
+	for k, names := range candidates {
+		if len(names) < 2 {
+			continue
+		}
+		byHash := make(map[[sha256.Size]byte][]string)
+		for _, name := range names {
+			data, err := ep.ReadFile(name)
+			if err != nil {
+				continue
+			}
+			h := sha256.Sum256(data)
+			byHash[h] = append(byHash[h], name)
+		}
+		for _, same := range byHash {
+			if len(same) > 1 {
+				sort.Strings(same)
+				groups = append(groups, same)
+				sizes = append(sizes, k.size)
+			}
+		}
+	}
+
+	// Report in a stable order
+	order := make([]int, len(groups))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return groups[order[a]][0] < groups[order[b]][0] })
+
+	for _, i := range order {
+		wasted := sizes[i] * uint64(len(groups[i])-1)
+		r.AddWithLocation(report.Warning, "RSC-015",
+			fmt.Sprintf("%d identical files (%d bytes wasted): %s; reference a single copy instead",
+				len(groups[i]), wasted, strings.Join(groups[i], ", ")),
+			groups[i][0])
+	}
+}
+
+// NAV-020: a toc entry whose label shares no words with either the title or
+// the first heading of the document it points to is likely mis-wired
+// ("Chapter 5" linking to the index). Only whole-document links are
+// compared, and titles shared by several documents (typically the book
+// title repeated everywhere) are ignored.
+func checkNavLabelsMatchTargets(ep *epub.EPUB, r *report.Report) {
+	var navPath string
+	for _, item := range ep.Package.Manifest {
+		if hasProperty(item.Properties, "nav") && item.Href != "\x00MISSING" {
+			navPath = ep.ResolveHref(item.Href)
+			break
+		}
+	}
+	if navPath == "" {
+		return
+	}
+	data, err := ep.ReadFile(navPath)
+	if err != nil {
+		return
+	}
+	navInfo := parseNavDocument(ep, data, navPath)
+
+	type docLabels struct{ title, heading string }
+	targets := make(map[string]docLabels)
+	titleCount := make(map[string]int)
+	for _, link := range navInfo.tocLinks {
+		u, err := url.Parse(link.href)
+		if err != nil || u.Scheme != "" || u.Path == "" {
+			continue
+		}
+		target := resolvePath(path.Dir(navPath), u.Path)
+		if _, done := targets[target]; done {
+			continue
+		}
+		targetData, err := ep.ReadFile(target)
+		if err != nil {
+			continue
+		}
+		title, heading := documentTitleAndHeading(targetData)
+		targets[target] = docLabels{title, heading}
+		titleCount[strings.ToLower(title)]++
+	}
+
+	bookTitle := ""
+	if len(ep.Package.Metadata.Titles) > 0 {
+		bookTitle = strings.ToLower(strings.TrimSpace(ep.Package.Metadata.Titles[0]))
+	}
+
+	for _, link := range navInfo.tocLinks {
+		u, err := url.Parse(link.href)
+		if err != nil || u.Scheme != "" || u.Path == "" || u.Fragment != "" {
+			continue
+		}
+		target := resolvePath(path.Dir(navPath), u.Path)
+		labels, ok := targets[target]
+		if !ok {
+			continue
+		}
+		title := labels.title
+		if titleCount[strings.ToLower(title)] > 1 || strings.ToLower(title) == bookTitle {
+			title = ""
+		}
+		if title == "" && labels.heading == "" {
+			continue
+		}
+		words := significantWords(link.text)
+		if len(words) == 0 {
+			continue
+		}
+		targetWords := significantWords(title + " " + labels.heading)
+		if len(targetWords) == 0 {
+			continue
+		}
+		shared := false
+		for w := range words {
+			if targetWords[w] {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			shown := title
+			if shown == "" {
+				shown = labels.heading
+			}
+			r.AddWithLocation(report.Warning, "NAV-020",
+				fmt.Sprintf("Table of contents entry '%s' links to '%s', whose title is '%s'", link.text, link.href, shown),
+				navPath)
+		}
+	}
+}
+
+// documentTitleAndHeading returns the text of a content document's <title>
+// and of its first h1-h6 heading.
+func documentTitleAndHeading(data []byte) (title, heading string) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "title":
+			if title == "" {
+				title = elementText(decoder)
+			}
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			heading = elementText(decoder)
+			return
+		}
+	}
+}
+
+// elementText returns the whitespace-normalized text content of the
+// current element, including descendants, and consumes its end tag.
+func elementText(decoder *xml.Decoder) string {
+	var text strings.Builder
+	depth := 1
+	for depth > 0 {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+	return strings.Join(strings.Fields(text.String()), " ")
+}
+
+// significantWords returns the lowercased words of s that carry meaning
+// for comparison: numbers, and words of three or more letters.
+func significantWords(s string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}) {
+		if len([]rune(w)) >= 3 || unicode.IsDigit([]rune(w)[0]) {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+// HTM-037: hundreds of nested elements, usually left by a converter
+// wrapping every paragraph in another span or div, can exhaust the layout
+// engines of some reading systems.
+func checkContentDepth(ep *epub.EPUB, r *report.Report, opts Options) {
+	limit := opts.MaxDepth
+	if limit <= 0 {
+		limit = defaultMaxDepth
+	}
+	for _, item := range ep.Package.Manifest {
+		if item.MediaType != "application/xhtml+xml" || item.Href == "\x00MISSING" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		if depth := maxElementDepth(data); depth > limit {
+			r.AddWithLocation(report.Warning, "HTM-037",
+				fmt.Sprintf("Content document '%s' nests elements %d levels deep (more than %d)", item.Href, depth, limit),
+				fullPath)
+		}
+	}
+}
+
+// maxElementDepth returns the deepest element nesting in an XML document.
+func maxElementDepth(data []byte) int {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	depth, max := 0, 0
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return max
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth > max {
+				max = depth
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// COVER-004: stores and readers open a book at its first linear spine item,
+// so a book with a cover image should start on a page that shows it.
+func checkCoverFirst(ep *epub.EPUB, r *report.Report) {
+	var coverImage string
+	for _, item := range ep.Package.Manifest {
+		if hasProperty(item.Properties, "cover-image") && item.Href != "\x00MISSING" {
+			coverImage = ep.ResolveHref(item.Href)
+			break
+		}
+	}
+	guideCover := ""
+	for _, ref := range ep.Package.Guide {
+		if ref.Type == "cover" {
+			if u, err := url.Parse(ref.Href); err == nil {
+				guideCover = ep.ResolveHref(u.Path)
+			}
+			break
+		}
+	}
+	if coverImage == "" && guideCover == "" {
+		return
+	}
+
+	manifestByID := make(map[string]epub.ManifestItem)
+	for _, item := range ep.Package.Manifest {
+		manifestByID[item.ID] = item
+	}
+	var first epub.ManifestItem
+	for _, ref := range ep.Package.Spine {
+		if ref.Linear == "no" {
+			continue
+		}
+		if item, ok := manifestByID[ref.IDRef]; ok && item.Href != "\x00MISSING" {
+			first = item
+			break
+		}
+	}
+	if first.Href == "" {
+		return
+	}
+	firstPath := ep.ResolveHref(first.Href)
+	if firstPath == coverImage || firstPath == guideCover {
+		return
+	}
+	data, err := ep.ReadFile(firstPath)
+	if err != nil || isCoverPage(data, firstPath, coverImage) {
+		return
+	}
+
+	shown := coverImage
+	if shown == "" {
+		shown = guideCover
+	}
+	r.AddWithLocation(report.Warning, "COVER-004",
+		fmt.Sprintf("The first spine item '%s' is not a cover page and does not show the cover '%s'; the book will open on content", first.Href, shown),
+		firstPath)
+}
+
+// isCoverPage reports whether a content document is marked as a cover with
+// epub:type, or displays coverImage through an img or SVG image element.
+func isCoverPage(data []byte, location, coverImage string) bool {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range se.Attr {
+			if attr.Name.Local == "type" && containsToken(attr.Value, "cover") {
+				return true
+			}
+			if coverImage == "" {
+				continue
+			}
+			isSrc := (se.Name.Local == "img" && attr.Name.Local == "src") ||
+				(se.Name.Local == "image" && attr.Name.Local == "href")
+			if !isSrc {
+				continue
+			}
+			if u, err := url.Parse(attr.Value); err == nil && u.Scheme == "" &&
+				resolvePath(path.Dir(location), u.Path) == coverImage {
+				return true
+			}
+		}
+	}
+}
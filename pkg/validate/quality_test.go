@@ -0,0 +1,223 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVolatileIdentifierReason(t *testing.T) {
+	tests := []struct {
+		id, value string
+		volatile  bool
+	}{
+		{"uid", "urn:uuid:12345678-1234-1234-1234-123456789012", false},
+		{"uid", "urn:isbn:9780000000000", false},
+		{"uid", "book-2024-01-01", false},
+		{"uid", "/tmp/build/book.epub", true},
+		{"uid", `C:\Users\me\book`, true},
+		{"uid", "calibre:42", true},
+		{"calibre_id", "42", true},
+		{"uid", "mybook-20240101123045", true},
+		{"uid", "mybook-2024-01-01T12:30:45Z", true},
+	}
+	for _, tt := range tests {
+		got := volatileIdentifierReason(tt.id, tt.value) != ""
+		if got != tt.volatile {
+			t.Errorf("volatileIdentifierReason(%q, %q) volatile = %v, want %v", tt.id, tt.value, got, tt.volatile)
+		}
+	}
+}
+
+func TestStableUniqueIdentifierQualityOnly(t *testing.T) {
+	opf := strings.Replace(testOPF(""),
+		"urn:uuid:12345678-1234-1234-1234-123456789012", "/tmp/build-42/book", 1)
+	path := writeTestEPUB(t, map[string]string{"OEBPS/content.opf": opf})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "OPF-075") {
+		t.Error("OPF-075 should only be reported with Quality enabled")
+	}
+
+	r, err = ValidateWithOptions(path, Options{Quality: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "OPF-075") {
+		t.Error("expected OPF-075 for a file path identifier")
+	}
+}
+
+func TestResourceCount(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("OEBPS/extra/%d.txt", i)] = "x"
+	}
+	path := writeTestEPUB(t, files)
+
+	r, err := ValidateWithOptions(path, Options{Quality: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "OCF-024") {
+		t.Error("OCF-024 should not fire below the default limit")
+	}
+
+	r, err = ValidateWithOptions(path, Options{Quality: true, ResourceLimit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "OCF-024") {
+		t.Error("expected OCF-024 above a configured limit of 10")
+	}
+}
+
+func TestDuplicateResources(t *testing.T) {
+	img := "\x89PNG\r\n\x1a\n" + strings.Repeat("x", 100)
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/images/a.png": img,
+		"OEBPS/images/b.png": img,
+		"OEBPS/images/c.png": img,
+		"OEBPS/images/d.png": img + "y",
+	})
+
+	r, err := ValidateWithOptions(path, Options{Quality: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []string
+	for _, m := range r.Messages {
+		if m.CheckID == "RSC-015" {
+			msgs = append(msgs, m.Message)
+		}
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("expected one RSC-015 group, got %v", msgs)
+	}
+	if !strings.Contains(msgs[0], "3 identical files (216 bytes wasted)") || strings.Contains(msgs[0], "d.png") {
+		t.Errorf("unexpected RSC-015 message: %s", msgs[0])
+	}
+}
+
+func TestNavLabelsMatchTargets(t *testing.T) {
+	nav := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>Navigation</title></head>
+<body>
+<nav epub:type="toc"><ol>
+<li><a href="chapter1.xhtml">Chapter 1: Beginnings</a></li>
+<li><a href="index.xhtml">Chapter 5</a></li>
+<li><a href="index.xhtml#a">Chapter 6</a></li>
+</ol></nav>
+</body>
+</html>`
+	page := func(title, heading string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>` + title + `</title></head>
+<body><h1 id="a">` + heading + `</h1></body></html>`
+	}
+	opf := strings.Replace(testOPF(`    <item id="index" href="index.xhtml" media-type="application/xhtml+xml"/>
+`), `<itemref idref="ch1"/>`, `<itemref idref="ch1"/>
+    <itemref idref="index"/>`, 1)
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf":    opf,
+		"OEBPS/nav.xhtml":      nav,
+		"OEBPS/chapter1.xhtml": page("Test Book", "1. <em>Beginnings</em>"),
+		"OEBPS/index.xhtml":    page("Index", "Index"),
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "NAV-020") {
+		t.Error("NAV-020 should only be reported in quality mode")
+	}
+
+	r, err = ValidateWithOptions(path, Options{Quality: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "NAV-020" {
+			got = append(got, m.Message)
+		}
+	}
+	if len(got) != 1 || !strings.Contains(got[0], "'Chapter 5'") || !strings.Contains(got[0], "'Index'") {
+		t.Errorf("expected a single NAV-020 for 'Chapter 5' -> Index, got %q", got)
+	}
+}
+
+func TestContentDepth(t *testing.T) {
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>T</title></head><body>` +
+		strings.Repeat("<div>", 20) + "deep" + strings.Repeat("</div>", 20) + `</body></html>`
+	path := writeTestEPUB(t, map[string]string{"OEBPS/chapter1.xhtml": chapter})
+
+	r, err := ValidateWithOptions(path, Options{Quality: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "HTM-037") {
+		t.Error("22 levels should be under the default depth limit")
+	}
+
+	r, err = ValidateWithOptions(path, Options{Quality: true, MaxDepth: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, m := range r.Messages {
+		if m.CheckID == "HTM-037" {
+			found = strings.Contains(m.Message, "22 levels")
+		}
+	}
+	if !found {
+		t.Errorf("expected HTM-037 reporting 22 levels, got %v", r.Messages)
+	}
+}
+
+func TestCoverFirst(t *testing.T) {
+	cover := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Cover</title></head>
+<body><img src="images/cover.png" alt="Cover"/></body></html>`
+	coverItems := `    <item id="img" href="images/cover.png" media-type="image/png" properties="cover-image"/>
+    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
+`
+	tests := []struct {
+		name  string
+		spine string
+		want  bool
+	}{
+		{"cover first", `<itemref idref="cover"/>
+    <itemref idref="ch1"/>`, false},
+		{"cover after content", `<itemref idref="ch1"/>
+    <itemref idref="cover"/>`, true},
+		{"non-linear content before cover", `<itemref idref="ch1" linear="no"/>
+    <itemref idref="cover"/>`, false},
+	}
+	for _, tt := range tests {
+		opf := strings.Replace(testOPF(coverItems), `<itemref idref="ch1"/>`, tt.spine, 1)
+		path := writeTestEPUB(t, map[string]string{
+			"OEBPS/content.opf":      opf,
+			"OEBPS/cover.xhtml":      cover,
+			"OEBPS/images/cover.png": "\x89PNG\r\n\x1a\n",
+		})
+		r, err := ValidateWithOptions(path, Options{Quality: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasCheck(r, "COVER-004"); got != tt.want {
+			t.Errorf("%s: COVER-004 = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/url"
 	"path"
+	"regexp"
 	"strings"
 
 	"github.com/adammathes/epubverify/pkg/epub"
@@ -48,6 +49,283 @@ func checkReferences(ep *epub.EPUB, r *report.Report, opts Options) {
 
 	// NAV-002: nav document must have epub:type="toc"
 	checkNavHasToc(ep, r)
+
+	// RSC-017: manifest items nothing links to (strict only)
+	if opts.Strict {
+		checkOrphanedResources(ep, r)
+	}
+
+	// RSC-020: remote resources make the publication depend on the network
+	checkRemoteResources(ep, r, opts)
+}
+
+// remoteResourceAttrs lists the element attributes that load a resource
+// and that no other check reports when remote. Remote img, audio, video
+// and source src are RSC-004, and remote stylesheet links are RSC-008.
+var remoteResourceAttrs = map[string]string{
+	"script": "src",
+	"video":  "poster",
+	"object": "data",
+	"embed":  "src",
+	"iframe": "src",
+	"track":  "src",
+	"image":  "href",
+}
+
+// RSC-020: distribution platforms expect a self-contained publication, so
+// remote http(s) resources are reported from the manifest, content and
+// SVG documents and stylesheets. URLs starting with one of
+// opts.AllowedRemoteURLs are skipped. Remote @font-face sources in
+// stylesheets are left to CSS-004, and remote audio and video manifest
+// items, which the spec allows, to the remote-resources property checks.
+func checkRemoteResources(ep *epub.EPUB, r *report.Report, opts Options) {
+	severity := report.Warning
+	if opts.Strict {
+		severity = report.Error
+	}
+	allowed := func(u string) bool {
+		for _, prefix := range opts.AllowedRemoteURLs {
+			if strings.HasPrefix(u, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	reported := make(map[string]bool)
+	add := func(u, location string) {
+		u = strings.TrimSpace(u)
+		if !isRemoteURL(u) || allowed(u) || reported[location+"\x00"+u] {
+			return
+		}
+		reported[location+"\x00"+u] = true
+		r.AddWithLocation(severity, "RSC-020",
+			fmt.Sprintf("Remote resource '%s' requires a network connection; package it in the EPUB instead", u),
+			location)
+	}
+
+	for _, item := range ep.Package.Manifest {
+		if strings.HasPrefix(item.MediaType, "audio/") || strings.HasPrefix(item.MediaType, "video/") {
+			continue
+		}
+		add(item.Href, ep.RootfilePath)
+	}
+
+	for _, item := range ep.Package.Manifest {
+		if item.Href == "\x00MISSING" || isRemoteURL(item.Href) {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		switch item.MediaType {
+		case "text/css":
+			data, err := ep.ReadFile(fullPath)
+			if err != nil {
+				continue
+			}
+			css := fontFaceBlockRe.ReplaceAllString(string(data), "")
+			for _, m := range cssImportRe.FindAllStringSubmatch(css, -1) {
+				add(m[1], fullPath)
+			}
+			for _, m := range cssURLRe.FindAllStringSubmatch(css, -1) {
+				add(m[1], fullPath)
+			}
+		case "application/xhtml+xml", "image/svg+xml":
+			data, err := ep.ReadFile(fullPath)
+			if err != nil {
+				continue
+			}
+			decoder := xml.NewDecoder(strings.NewReader(string(data)))
+			decoder.Strict = false
+			// The CSS of <style> elements and style attributes
+			var styles strings.Builder
+			inStyle := false
+			for {
+				tok, err := decoder.Token()
+				if err != nil {
+					break
+				}
+				switch t := tok.(type) {
+				case xml.StartElement:
+					inStyle = t.Name.Local == "style"
+					for _, attr := range t.Attr {
+						if attr.Name.Local == "style" {
+							styles.WriteString(attr.Value)
+							styles.WriteByte('\n')
+						}
+					}
+					name, ok := remoteResourceAttrs[t.Name.Local]
+					if !ok {
+						continue
+					}
+					for _, attr := range t.Attr {
+						if attr.Name.Local == name {
+							add(attr.Value, fullPath)
+						}
+					}
+				case xml.EndElement:
+					inStyle = false
+				case xml.CharData:
+					if inStyle {
+						styles.Write(t)
+					}
+				}
+			}
+			for _, m := range cssURLRe.FindAllStringSubmatch(styles.String(), -1) {
+				add(m[1], fullPath)
+			}
+		}
+	}
+}
+
+var (
+	cssURLRe    = regexp.MustCompile(`url\(\s*['"]?([^'")\s]+)['"]?\s*\)`)
+	cssImportRe = regexp.MustCompile(`@import\s+['"]([^'"]+)['"]`)
+	opfCoverRe  = regexp.MustCompile(`<meta\s[^>]*name=["']cover["'][^>]*>`)
+	contentRe   = regexp.MustCompile(`content=["']([^"']*)["']`)
+)
+
+// RSC-017: manifest items that cannot be reached from the spine, the nav
+// document, the NCX, the cover or the guide by following links, resource
+// references, stylesheets, fallbacks and media overlays are dead weight.
+func checkOrphanedResources(ep *epub.EPUB, r *report.Report) {
+	pkg := ep.Package
+	byPath := make(map[string]epub.ManifestItem)
+	byID := make(map[string]epub.ManifestItem)
+	for _, item := range pkg.Manifest {
+		if item.Href == "\x00MISSING" || item.Href == "" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		byPath[fullPath] = item
+		if unescaped, err := url.PathUnescape(fullPath); err == nil {
+			byPath[unescaped] = item
+		}
+		byID[item.ID] = item
+	}
+
+	reached := make(map[string]bool)
+	var queue []string
+	reach := func(item epub.ManifestItem, ok bool) {
+		if !ok {
+			return
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		if !reached[fullPath] {
+			reached[fullPath] = true
+			queue = append(queue, fullPath)
+		}
+	}
+
+	for _, ref := range pkg.Spine {
+		item, ok := byID[ref.IDRef]
+		reach(item, ok)
+	}
+	item, ok := byID[pkg.SpineToc]
+	reach(item, ok)
+	for _, item := range pkg.Manifest {
+		if hasProperty(item.Properties, "nav") || hasProperty(item.Properties, "cover-image") {
+			reach(item, item.Href != "\x00MISSING" && item.Href != "")
+		}
+	}
+	for _, ref := range pkg.Guide {
+		if u, err := url.Parse(ref.Href); err == nil && u.Scheme == "" {
+			item, ok := byPath[ep.ResolveHref(u.Path)]
+			reach(item, ok)
+		}
+	}
+	if opf, err := ep.ReadFile(ep.RootfilePath); err == nil {
+		for _, meta := range opfCoverRe.FindAllString(string(opf), -1) {
+			if m := contentRe.FindStringSubmatch(meta); m != nil {
+				item, ok := byID[m[1]]
+				reach(item, ok)
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		fullPath := queue[0]
+		queue = queue[1:]
+		current := byPath[fullPath]
+
+		if current.Fallback != "" {
+			item, ok := byID[current.Fallback]
+			reach(item, ok)
+		}
+		if current.MediaOverlay != "" {
+			item, ok := byID[current.MediaOverlay]
+			reach(item, ok)
+		}
+
+		data, err := ep.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		for _, target := range resourceRefs(data, fullPath, current.MediaType) {
+			item, ok := byPath[target]
+			reach(item, ok)
+		}
+	}
+
+	for _, item := range pkg.Manifest {
+		if item.Href == "\x00MISSING" || item.Href == "" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		if _, exists := ep.Files[fullPath]; !exists || reached[fullPath] {
+			continue
+		}
+		r.AddWithLocation(report.Warning, "RSC-017",
+			fmt.Sprintf("Manifest item '%s' is not referenced from the spine, navigation or any other resource", item.Href),
+			fullPath)
+	}
+}
+
+// resourceRefs returns the container paths a document refers to through
+// href, src and similar attributes, CSS url() and @import, resolved
+// against the document. Remote, data: and other absolute URLs are left out.
+func resourceRefs(data []byte, fullPath, mediaType string) []string {
+	dir := path.Dir(fullPath)
+	var raw []string
+	if mediaType == "text/css" {
+		for _, m := range cssImportRe.FindAllStringSubmatch(string(data), -1) {
+			raw = append(raw, m[1])
+		}
+	} else if xmlMediaTypes[mediaType] {
+		if mediaType == "application/xhtml+xml" {
+			dir = contentBaseDir(data, fullPath)
+		}
+		decoder := xml.NewDecoder(strings.NewReader(string(data)))
+		decoder.Strict = false
+		for {
+			tok, err := decoder.Token()
+			if err != nil {
+				break
+			}
+			if se, ok := tok.(xml.StartElement); ok {
+				for _, attr := range se.Attr {
+					switch attr.Name.Local {
+					case "href", "src", "poster", "data", "altimg":
+						raw = append(raw, attr.Value)
+					}
+				}
+			}
+		}
+	} else {
+		return nil
+	}
+	// CSS url() also covers <style> elements and style attributes
+	for _, m := range cssURLRe.FindAllStringSubmatch(string(data), -1) {
+		raw = append(raw, m[1])
+	}
+
+	var refs []string
+	for _, ref := range raw {
+		u, err := url.Parse(strings.TrimSpace(ref))
+		if err != nil || u.Scheme != "" || u.Path == "" {
+			continue
+		}
+		refs = append(refs, resolvePath(dir, u.Path))
+	}
+	return refs
 }
 
 // RSC-001 / RSC-005 / RSC-009: manifest file existence checks
@@ -113,7 +391,7 @@ func checkResourcesInManifest(ep *epub.EPUB, r *report.Report) {
 // and checks that the referenced CSS/resource is in the manifest.
 func checkReferencedResourcesInManifest(ep *epub.EPUB, data []byte, fullPath string, manifestHrefs map[string]bool, r *report.Report) {
 	decoder := xml.NewDecoder(strings.NewReader(string(data)))
-	itemDir := path.Dir(fullPath)
+	itemDir := contentBaseDir(data, fullPath)
 
 	for {
 		tok, err := decoder.Token()
@@ -223,18 +501,19 @@ func checkNavHasToc(ep *epub.EPUB, r *report.Report) {
 }
 
 // checkNavigation validates the navigation document (Level 2+3 checks).
-func checkNavigation(ep *epub.EPUB, r *report.Report) {
+func checkNavigation(ep *epub.EPUB, r *report.Report, strict bool) {
 	if ep.Package == nil || ep.Package.Version < "3.0" {
 		return
 	}
 
-	var navHref string
+	var navItem epub.ManifestItem
 	for _, item := range ep.Package.Manifest {
 		if hasProperty(item.Properties, "nav") {
-			navHref = item.Href
+			navItem = item
 			break
 		}
 	}
+	navHref := navItem.Href
 	if navHref == "" {
 		return
 	}
@@ -245,11 +524,26 @@ func checkNavigation(ep *epub.EPUB, r *report.Report) {
 		return
 	}
 
+	// NAV-019: the nav item must be an XHTML document
+	if navItem.MediaType != "application/xhtml+xml" {
+		r.AddWithLocation(report.Error, "NAV-019",
+			fmt.Sprintf("Manifest item '%s' has the 'nav' property but media type '%s'; the navigation document must be application/xhtml+xml", navHref, navItem.MediaType),
+			fullPath)
+		return
+	}
+
 	// NAV-011: nav document must be well-formed XHTML
 	if !checkNavWellFormed(data, fullPath, r) {
 		return // Can't check further
 	}
 
+	// NAV-019: the nav document must contain at least one nav element
+	if !navDocHasNav(data) {
+		r.AddWithLocation(report.Warning, "NAV-019",
+			fmt.Sprintf("Manifest item '%s' has the 'nav' property but contains no nav element", navHref),
+			fullPath)
+	}
+
 	navInfo := parseNavDocument(ep, data, fullPath)
 
 	// NAV-008: toc nav must have ol element
@@ -308,11 +602,93 @@ func checkNavigation(ep *epub.EPUB, r *report.Report) {
 				fullPath)
 		}
 	}
+
+	// NAV-025: every landmark entry needs an epub:type
+	// NAV-023: each landmark type should appear once
+	checkLandmarkEntries(navInfo, fullPath, r)
+
+	// NAV-024: landmarks nav should be present (strict mode only)
+	if strict && !navInfo.hasLandmarks {
+		r.AddWithLocation(report.Warning, "NAV-024",
+			"Navigation document has no landmarks nav; reading systems use it to find the cover, toc and start of the book",
+			fullPath)
+	}
+
+	// NAV-022: toc entries should follow the spine order
+	checkTocSpineOrder(ep, navInfo, fullPath, r)
+}
+
+// NAV-025: the landmarks nav is identified by the epub:type of each entry,
+// so an entry without one cannot be used.
+// NAV-023: listing the same landmark type twice leaves reading systems to
+// guess which entry is meant.
+func checkLandmarkEntries(navInfo navDocInfo, navPath string, r *report.Report) {
+	seen := make(map[string]bool)
+	for _, link := range navInfo.landmarkLinks {
+		types := strings.Fields(link.epubType)
+		if len(types) == 0 {
+			r.AddWithLocation(report.Error, "NAV-025",
+				fmt.Sprintf("Landmark nav entry '%s' is missing the required epub:type attribute", link.text),
+				navPath)
+			continue
+		}
+		for _, t := range types {
+			if seen[t] {
+				r.AddWithLocation(report.Warning, "NAV-023",
+					fmt.Sprintf("Landmark type '%s' is listed more than once in the landmarks nav", t),
+					navPath)
+			}
+			seen[t] = true
+		}
+	}
+}
+
+// NAV-022: a toc that lists documents in a different order than the spine
+// sends readers through the book out of sequence. Links to fragments of
+// the document already reached, and documents outside the spine, are
+// ignored.
+func checkTocSpineOrder(ep *epub.EPUB, navInfo navDocInfo, navPath string, r *report.Report) {
+	manifestByID := make(map[string]epub.ManifestItem)
+	for _, item := range ep.Package.Manifest {
+		manifestByID[item.ID] = item
+	}
+	spineIndex := make(map[string]int)
+	for i, ref := range ep.Package.Spine {
+		item, ok := manifestByID[ref.IDRef]
+		if !ok || item.Href == "\x00MISSING" {
+			continue
+		}
+		fullPath := ep.ResolveHref(item.Href)
+		if _, seen := spineIndex[fullPath]; !seen {
+			spineIndex[fullPath] = i
+		}
+	}
+
+	last := -1
+	var lastLink navLink
+	for _, link := range navInfo.tocLinks {
+		u, err := url.Parse(link.href)
+		if err != nil || u.Scheme != "" || u.Path == "" {
+			continue
+		}
+		idx, ok := spineIndex[resolvePath(path.Dir(navPath), u.Path)]
+		if !ok {
+			continue
+		}
+		if idx < last {
+			r.AddWithLocation(report.Warning, "NAV-022",
+				fmt.Sprintf("Table of contents entry '%s' (%s) comes after '%s' (%s) but is earlier in the spine", link.text, link.href, lastLink.text, lastLink.href),
+				navPath)
+			continue
+		}
+		last, lastLink = idx, link
+	}
 }
 
 type navLink struct {
-	href string
-	text string
+	href     string
+	text     string
+	epubType string // epub:type attribute, recorded for landmarks
 }
 
 type navDocInfo struct {
@@ -354,6 +730,7 @@ func parseNavDocument(ep *epub.EPUB, data []byte, navPath string) navDocInfo {
 	inAnchor := false
 	var currentHref string
 	var currentText string
+	var currentType string
 
 	for {
 		tok, err := decoder.Token()
@@ -394,12 +771,14 @@ func parseNavDocument(ep *epub.EPUB, data []byte, navPath string) navDocInfo {
 				inAnchor = true
 				currentHref = ""
 				currentText = ""
+				currentType = ""
 				for _, attr := range t.Attr {
 					if attr.Name.Local == "href" {
 						currentHref = attr.Value
 					}
 					// Capture epub:type on landmark anchors
 					if currentNavType == "landmarks" && attr.Name.Local == "type" {
+						currentType = attr.Value
 						for _, val := range strings.Fields(attr.Value) {
 							info.landmarkTypes = append(info.landmarkTypes, val)
 						}
@@ -413,8 +792,9 @@ func parseNavDocument(ep *epub.EPUB, data []byte, navPath string) navDocInfo {
 		case xml.EndElement:
 			if t.Name.Local == "a" && inAnchor {
 				link := navLink{
-					href: currentHref,
-					text: strings.TrimSpace(currentText),
+					href:     currentHref,
+					text:     strings.TrimSpace(currentText),
+					epubType: currentType,
 				}
 				switch currentNavType {
 				case "toc":
@@ -505,7 +885,7 @@ func checkManifestNoPathTraversal(ep *epub.EPUB, r *report.Report) {
 func checkNoDuplicateZipEntries(ep *epub.EPUB, r *report.Report) {
 	// Check for files that map to the same case-insensitive path
 	seen := make(map[string]string) // lowercase -> original
-	for _, f := range ep.ZipFile.File {
+	for _, f := range ep.Zip.File {
 		lower := strings.ToLower(f.Name)
 		if existing, ok := seen[lower]; ok {
 			if existing != f.Name {
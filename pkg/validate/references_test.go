@@ -0,0 +1,239 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adammathes/epubverify/pkg/report"
+)
+
+func TestNavItemMustBeXHTML(t *testing.T) {
+	opf := strings.Replace(testOPF(""),
+		`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>`,
+		`<item id="nav" href="toc.ncx" media-type="application/x-dtbncx+xml" properties="nav"/>`, 1)
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": opf,
+		"OEBPS/toc.ncx": `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1"><navMap/></ncx>`,
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "NAV-019") {
+		t.Error("expected NAV-019 for a nav item pointing at the NCX")
+	}
+}
+
+func TestNavDocHasNav(t *testing.T) {
+	if navDocHasNav([]byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body><ol><li>x</li></ol></body></html>`)) {
+		t.Error("document without nav reported as having one")
+	}
+	if !navDocHasNav([]byte(testNavXHTML)) {
+		t.Error("nav document not detected")
+	}
+}
+
+func TestOrphanedResources(t *testing.T) {
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter 1</title><link rel="stylesheet" type="text/css" href="style.css"/></head>
+<body><p><img src="used.png" alt="Used"/></p></body>
+</html>`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="css" href="style.css" media-type="text/css"/>
+    <item id="bg" href="bg.png" media-type="image/png"/>
+    <item id="used" href="used.png" media-type="image/png"/>
+    <item id="cover" href="cover.png" media-type="image/png" properties="cover-image"/>
+    <item id="orphan" href="orphan.png" media-type="image/png"/>
+`),
+		"OEBPS/chapter1.xhtml": chapter,
+		"OEBPS/style.css":      `body { background: url("bg.png"); }`,
+		"OEBPS/bg.png":         "\x89PNG\r\n\x1a\n",
+		"OEBPS/used.png":       "\x89PNG\r\n\x1a\n",
+		"OEBPS/cover.png":      "\x89PNG\r\n\x1a\n",
+		"OEBPS/orphan.png":     "\x89PNG\r\n\x1a\n",
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "RSC-017") {
+		t.Error("RSC-017 should only run in strict mode")
+	}
+
+	r, err = ValidateWithOptions(path, Options{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "RSC-017" {
+			got = append(got, m.Location)
+		}
+	}
+	if len(got) != 1 || got[0] != "OEBPS/orphan.png" {
+		t.Errorf("expected RSC-017 only for orphan.png, got %v", got)
+	}
+}
+
+func TestTocSpineOrder(t *testing.T) {
+	opf := strings.Replace(testOPF(`    <item id="ch2" href="chapter2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch3" href="chapter3.xhtml" media-type="application/xhtml+xml"/>
+`), `<itemref idref="ch1"/>`, `<itemref idref="ch1"/><itemref idref="ch2"/><itemref idref="ch3"/>`, 1)
+	chapter := func(id string) string {
+		return strings.Replace(testChapterXHTML, "<p>", `<p id="`+id+`">`, 1)
+	}
+	nav := func(entries string) string {
+		return strings.Replace(testNavXHTML, `<li><a href="chapter1.xhtml">Chapter 1</a></li>`, entries, 1)
+	}
+	files := map[string]string{
+		"OEBPS/content.opf":    opf,
+		"OEBPS/chapter1.xhtml": chapter("s1"),
+		"OEBPS/chapter2.xhtml": chapter("s2"),
+		"OEBPS/chapter3.xhtml": chapter("s3"),
+	}
+
+	files["OEBPS/nav.xhtml"] = nav(`<li><a href="chapter1.xhtml">One</a></li>` +
+		`<li><a href="chapter2.xhtml">Two</a><ol><li><a href="chapter2.xhtml#s2">Two, part</a></li></ol></li>` +
+		`<li><a href="chapter3.xhtml">Three</a></li>`)
+	r, err := Validate(writeTestEPUB(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "NAV-022") {
+		t.Error("NAV-022 reported for a toc in spine order")
+	}
+
+	files["OEBPS/nav.xhtml"] = nav(`<li><a href="chapter1.xhtml">One</a></li>` +
+		`<li><a href="chapter3.xhtml">Three</a></li>` +
+		`<li><a href="chapter2.xhtml">Two</a></li>`)
+	r, err = Validate(writeTestEPUB(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, m := range r.Messages {
+		if m.CheckID == "NAV-022" {
+			msgs = append(msgs, m.Message)
+		}
+	}
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "'Two' (chapter2.xhtml) comes after 'Three' (chapter3.xhtml)") {
+		t.Errorf("expected one NAV-022 for Two after Three, got %v", msgs)
+	}
+}
+
+func TestLandmarkEntries(t *testing.T) {
+	nav := strings.Replace(testNavXHTML, "</nav>", `</nav>
+<nav epub:type="landmarks"><ol>
+<li><a epub:type="toc" href="nav.xhtml">Contents</a></li>
+<li><a epub:type="bodymatter" href="chapter1.xhtml">Start</a></li>
+<li><a epub:type="bodymatter" href="chapter1.xhtml#p">Start again</a></li>
+<li><a href="chapter1.xhtml">Untyped</a></li>
+</ol></nav>`, 1)
+	r, err := Validate(writeTestEPUB(t, map[string]string{"OEBPS/nav.xhtml": nav}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		if m.CheckID == "NAV-010" || m.CheckID == "NAV-023" || m.CheckID == "NAV-025" {
+			got = append(got, m.CheckID+": "+m.Message)
+		}
+	}
+	want := []string{
+		"NAV-025: Landmark nav entry 'Untyped' is missing the required epub:type attribute",
+		"NAV-023: Landmark type 'bodymatter' is listed more than once in the landmarks nav",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Without a landmarks nav, NAV-024 fires only in strict mode.
+	path := writeTestEPUB(t, nil)
+	r, err = Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "NAV-024") {
+		t.Error("NAV-024 should only be reported in strict mode")
+	}
+	r, err = ValidateWithOptions(path, Options{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "NAV-024") {
+		t.Error("expected NAV-024 in strict mode without a landmarks nav")
+	}
+}
+
+func TestRemoteResources(t *testing.T) {
+	chapter := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter 1</title><link rel="stylesheet" type="text/css" href="style.css"/>
+<script src="https://cdn.example.com/app.js"></script></head>
+<body>
+<p style="background: url(http://example.com/bg.png)">Text</p>
+<video src="https://media.example.com/clip.mp4" poster="https://media.example.com/clip.jpg"/>
+<p><a href="https://example.com/">A link is fine</a></p>
+<p>So is url(https://text.example.com/x.png) written in the text.</p>
+</body>
+</html>`
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="css" href="style.css" media-type="text/css"/>
+    <item id="clip" href="https://media.example.com/clip.mp4" media-type="video/mp4"/>
+`),
+		"OEBPS/chapter1.xhtml": chapter,
+		"OEBPS/style.css": `@import url("https://fonts.example.com/css");
+@font-face { font-family: "F"; src: url(https://fonts.example.com/f.woff); }
+body { font-family: "F"; }`,
+	})
+
+	remote := func(opts Options) map[string]report.Severity {
+		t.Helper()
+		r, err := ValidateWithOptions(path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]report.Severity)
+		for _, m := range r.Messages {
+			if m.CheckID == "RSC-020" {
+				u := m.Message[strings.Index(m.Message, "'")+1:]
+				got[m.Location+" "+u[:strings.Index(u, "'")]] = m.Severity
+			}
+		}
+		return got
+	}
+
+	got := remote(Options{})
+	// The remote video item is allowed by the spec, so only its poster
+	// is reported.
+	want := []string{
+		"OEBPS/chapter1.xhtml https://cdn.example.com/app.js",
+		"OEBPS/chapter1.xhtml http://example.com/bg.png",
+		"OEBPS/chapter1.xhtml https://media.example.com/clip.jpg",
+		"OEBPS/style.css https://fonts.example.com/css",
+	}
+	if len(got) != len(want) {
+		t.Errorf("RSC-020 = %v, want %v", got, want)
+	}
+	for _, w := range want {
+		if sev, ok := got[w]; !ok || sev != report.Warning {
+			t.Errorf("missing RSC-020 warning for %s", w)
+		}
+	}
+
+	got = remote(Options{Strict: true, AllowedRemoteURLs: []string{"https://media.example.com/"}})
+	if len(got) != 3 {
+		t.Errorf("RSC-020 with media allowed = %v, want 3 messages", got)
+	}
+	for k, sev := range got {
+		if strings.Contains(k, "media.example.com") || sev != report.Error {
+			t.Errorf("RSC-020 %s: severity %s, want an error and no allowed URLs", k, sev)
+		}
+	}
+}
@@ -1,6 +1,13 @@
 package validate
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
 	"github.com/adammathes/epubverify/pkg/epub"
 	"github.com/adammathes/epubverify/pkg/report"
 )
@@ -9,12 +16,108 @@ import (
 type Options struct {
 	// Strict enables checks that follow the EPUB spec more closely,
 	// even when the reference epubcheck tool doesn't flag them.
-	// This includes OCF-005 (compressed mimetype) and RSC-002 (file not in manifest).
+	// This includes OCF-005 (compressed mimetype), RSC-002 (file not in manifest),
+	// RSC-017 (manifest item nothing refers to), NAV-024 (no landmarks nav),
+	// CSS-020 (discouraged CSS in reflowable books) and FONT-003 (embedded
+	// font no @font-face uses). It also raises RSC-020 (remote resources)
+	// from a warning to an error.
 	Strict bool
 
+	// AllowedRemoteURLs lists URL prefixes that RSC-020 does not report,
+	// such as the host of remote audio and video and their poster images.
+	AllowedRemoteURLs []string
+
+	// DiscouragedCSS overrides the declarations that the Strict check
+	// CSS-020 warns about. Each entry is a property name, which matches
+	// any value, or "property: value", which matches values starting with
+	// value. Nil warns about "position: fixed" and "text-indent: -999",
+	// which catches off-screen indents like -9999px.
+	DiscouragedCSS []string
+
 	// Accessibility enables accessibility metadata and best-practice checks (ACC-*).
 	// These are not flagged by epubcheck without --profile and are off by default.
 	Accessibility bool
+
+	// Quality enables heuristic packaging and editorial checks that are
+	// legal per the spec but cause problems for reading systems and
+	// libraries. These are off by default to avoid false positives.
+	// Besides the checks of the quality phase, Quality enables the
+	// fixed-layout heuristic FXL-008 and FONT-003 (embedded font no
+	// @font-face uses).
+	Quality bool
+
+	// LenientTimestamps reports dcterms:modified values that are close to
+	// the required form (missing Z or seconds, fractional seconds, or a
+	// timezone offset) as warnings instead of errors. Strict overrides it.
+	LenientTimestamps bool
+
+	// ResourceLimit is the file count above which the Quality check OCF-024
+	// suggests consolidating resources. Zero uses the default of 1000.
+	ResourceLimit int
+
+	// MaxDepth is the element nesting depth above which the Quality check
+	// HTM-037 flags a content document. Zero uses the default of 100.
+	MaxDepth int
+
+	// MaxDataURIBytes is the decoded size above which HTM-040 flags a
+	// data: URI in a content document. Zero uses the default of 64 KiB.
+	MaxDataURIBytes int
+
+	// Rootfile selects which rendition to validate by its full-path in
+	// META-INF/container.xml. The default is the first package document.
+	Rootfile string
+
+	// AllRenditions validates every rendition listed in container.xml, one
+	// after another, when Rootfile is not set. Messages from each
+	// rendition are prefixed with its package document path; container
+	// (OCF) messages, and findings in a file several renditions share,
+	// are reported once. Progress runs once per rendition.
+	AllRenditions bool
+
+	// Sink, if set, receives each message as soon as a check reports it.
+	Sink report.Sink
+
+	// MinSeverity drops messages less serious than it, e.g. report.Error
+	// to skip warnings and info. The zero value reports everything.
+	MinSeverity report.Severity
+
+	// MaxDecompressedBytes caps how many bytes any single entry may
+	// decompress to when a check reads it. An entry over the cap ends
+	// validation with a fatal OCF-031, as a guard against zip bombs. Zero
+	// uses DefaultMaxDecompressedBytes.
+	MaxDecompressedBytes int64
+
+	// MaxUncompressedBytes, if set, warns (OCF-018) when the entries add up
+	// to more than this many bytes uncompressed, as for a storefront cap.
+	MaxUncompressedBytes int64
+
+	// Progress, if set, is called after each validation phase with the
+	// phase name ("ocf", "opf", "references", ...) and the percentage of
+	// phases completed. It reports 100 when a fatal error ends validation
+	// early.
+	Progress func(phase string, pct int)
+
+	// EnabledChecks, if non-empty, limits the report to these check IDs.
+	// DisabledChecks removes these check IDs from the report, for checks a
+	// publisher knowingly violates.
+	EnabledChecks  []string
+	DisabledChecks []string
+
+	// Concurrency is the number of content documents checked at once.
+	// Values above 1 speed up books with many chapters; messages are
+	// still reported in manifest order. Zero or 1 checks them serially.
+	Concurrency int
+}
+
+// newReport returns an empty report that applies the output settings
+// in opts to every message the checks add.
+func newReport(opts Options) *report.Report {
+	r := report.NewReport()
+	r.Sink = opts.Sink
+	r.MinSeverity = opts.MinSeverity
+	r.EnabledChecks = opts.EnabledChecks
+	r.DisabledChecks = opts.DisabledChecks
+	return r
 }
 
 // Validate runs all validation checks on an EPUB file and returns a report.
@@ -24,53 +127,384 @@ func Validate(path string) (*report.Report, error) {
 
 // ValidateWithOptions runs validation with the given options.
 func ValidateWithOptions(path string, opts Options) (*report.Report, error) {
-	r := report.NewReport()
+	return ValidateWithContext(context.Background(), path, opts)
+}
+
+// ValidateWithContext is like ValidateWithOptions but stops early when ctx
+// is cancelled or times out. It then returns the partial report together
+// with ctx.Err(); the partial report should not be taken as a verdict.
+func ValidateWithContext(ctx context.Context, path string, opts Options) (*report.Report, error) {
+	r := newReport(opts)
 
 	ep, err := epub.Open(path)
 	if err != nil {
-		r.Add(report.Fatal, "PKG-000", "Could not open EPUB: "+err.Error())
+		reportOpenError(r, err)
 		return r, nil
 	}
 	defer ep.Close()
 
-	// Phase 1: OCF container checks
-	if fatal := checkOCF(ep, r, opts); fatal {
+	return r, validateEPUB(ctx, ep, r, opts)
+}
+
+// DefaultMaxDecompressedBytes is the per-entry decompression cap used when
+// Options.MaxDecompressedBytes is zero. No content document, stylesheet or
+// image a check needs to read comes anywhere near it.
+const DefaultMaxDecompressedBytes = 256 << 20
+
+// reportOpenError records why the archive could not be opened: OCF-030
+// for an entry path that escapes the container, OCF-031 for a file in an
+// unpacked book over the decompression cap, PKG-000 otherwise.
+func reportOpenError(r *report.Report, err error) {
+	id := "PKG-000"
+	switch {
+	case errors.Is(err, epub.ErrUnsafePath):
+		id = "OCF-030"
+	case errors.Is(err, epub.ErrDecompressionLimit):
+		id = "OCF-031"
+	}
+	r.Add(report.Fatal, id, "Could not open EPUB: "+err.Error())
+}
+
+// ValidateDir runs validation on an unpacked EPUB, a directory holding
+// what would be the contents of the archive, as while a book is being
+// authored. All phases run as for a zipped book except the checks on how
+// the zip itself was built, which are reported as not applicable (OCF-026).
+func ValidateDir(path string, opts Options) (*report.Report, error) {
+	r := newReport(opts)
+
+	maxBytes := opts.MaxDecompressedBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxDecompressedBytes
+	}
+	ep, err := epub.OpenDir(path, maxBytes)
+	if err != nil {
+		reportOpenError(r, err)
 		return r, nil
 	}
+	defer ep.Close()
+
+	return r, validateEPUB(context.Background(), ep, r, opts)
+}
+
+// ValidateByFile runs validation and groups the messages by the file they
+// apply to, for editor integrations that show diagnostics per open file.
+// Publication-wide messages are keyed by the empty string.
+func ValidateByFile(path string, opts Options) (map[string][]report.Message, error) {
+	r, err := ValidateWithOptions(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.MessagesByFile(), nil
+}
 
-	// Phase 2: Parse and check OPF
-	if fatal := checkOPF(ep, r); fatal {
+// Quick runs only the container (OCF) and package document (OPF) checks,
+// skipping cross-references, navigation, content, CSS and media. It is
+// meant as a cheap structural gate before queuing full validation: a
+// report with no errors from Quick can still fail Validate.
+//
+// Messages are limited to PKG-000 (unreadable archive), OCF-031 (an entry
+// over the decompression cap) and the OCF-*, OPF-* and FONT-004 (legacy
+// font media type) IDs raised by checkOCF and checkOPF. opts applies as for ValidateWithOptions; the
+// options that enable later phases are ignored.
+func Quick(path string, opts Options) (*report.Report, error) {
+	return QuickWithContext(context.Background(), path, opts)
+}
+
+// QuickWithContext is like Quick but stops early when ctx is done,
+// returning the partial report and ctx.Err().
+func QuickWithContext(ctx context.Context, path string, opts Options) (*report.Report, error) {
+	r := newReport(opts)
+
+	ep, err := epub.Open(path)
+	if err != nil {
+		reportOpenError(r, err)
+		return r, nil
+	}
+	defer ep.Close()
+	ep.SetContext(ctx)
+	ep.MaxDecompressedBytes = opts.MaxDecompressedBytes
+	if ep.MaxDecompressedBytes <= 0 {
+		ep.MaxDecompressedBytes = DefaultMaxDecompressedBytes
+	}
+	defer r.Sort()
+
+	if fatal := checkOCF(ep, r, opts); !fatal {
+		checkOPF(ep, r, opts)
+	}
+	// OCF-031: an entry decompressed past the cap
+	if err := ep.DecompressionErr(); err != nil {
+		r.Add(report.Fatal, "OCF-031", "Validation stopped: "+err.Error())
+	}
+	return r, ctx.Err()
+}
+
+// ValidateBytes runs validation on an EPUB held in memory.
+func ValidateBytes(data []byte, opts Options) (*report.Report, error) {
+	return ValidateReader(bytes.NewReader(data), int64(len(data)), opts)
+}
+
+// ValidateBytesWithContext is like ValidateBytes but stops early when ctx
+// is done, returning the partial report and ctx.Err().
+func ValidateBytesWithContext(ctx context.Context, data []byte, opts Options) (*report.Report, error) {
+	return validateReader(ctx, bytes.NewReader(data), int64(len(data)), opts)
+}
+
+// ValidateReader runs validation on an EPUB read through ra, which holds
+// size bytes. Entries are read on demand as each phase needs them, so a
+// large book behind a file, object store or HTTP range reader never has
+// to be buffered in full.
+func ValidateReader(ra io.ReaderAt, size int64, opts Options) (*report.Report, error) {
+	return validateReader(context.Background(), ra, size, opts)
+}
+
+func validateReader(ctx context.Context, ra io.ReaderAt, size int64, opts Options) (*report.Report, error) {
+	r := newReport(opts)
+
+	ep, err := epub.OpenFromReaderAt(ra, size)
+	if err != nil {
+		reportOpenError(r, err)
 		return r, nil
 	}
+	defer ep.Close()
+
+	return r, validateEPUB(ctx, ep, r, opts)
+}
+
+// ValidateBytesFull is like ValidateBytes but also returns the parsed EPUB,
+// so callers can read metadata and files without opening it a second time.
+//
+// The returned EPUB reads directly from data, which must not be modified
+// while it is in use. Close is a no-op for in-memory EPUBs but is safe to
+// call. The EPUB is nil if the archive could not be opened, and its Package
+// is nil if validation stopped before the package document was parsed.
+func ValidateBytesFull(data []byte, opts Options) (*report.Report, *epub.EPUB, error) {
+	r := newReport(opts)
+
+	ep, err := epub.OpenFromBytes(data)
+	if err != nil {
+		reportOpenError(r, err)
+		return r, nil, nil
+	}
+
+	validateEPUB(context.Background(), ep, r, opts)
+	return r, ep, nil
+}
+
+// validateEPUB runs the validation phases in order. It returns ctx.Err()
+// if ctx is done before the last phase finishes; ReadFile fails fast once
+// the context is cancelled, so the phase in progress winds down quickly.
+// The messages are sorted with report.Sort when it returns.
+func validateEPUB(ctx context.Context, ep *epub.EPUB, r *report.Report, opts Options) (err error) {
+	ep.SetContext(ctx)
+	ep.MaxDecompressedBytes = opts.MaxDecompressedBytes
+	if ep.MaxDecompressedBytes <= 0 {
+		ep.MaxDecompressedBytes = DefaultMaxDecompressedBytes
+	}
+	defer r.Sort()
+	// An entry over the decompression cap ends validation with OCF-031;
+	// that is a verdict, not a failure to validate.
+	defer func() {
+		if errors.Is(err, epub.ErrDecompressionLimit) {
+			err = nil
+		}
+	}()
+
+	if opts.AllRenditions && opts.Rootfile == "" && ep.ParseContainer() == nil && len(ep.AllRootfiles) > 1 {
+		return validateRenditions(ctx, ep, r, opts)
+	}
+
+	total := 10
+	if opts.Accessibility {
+		total++
+	}
+	if opts.Quality {
+		total++
+	}
+	done := 0
+	// phaseDone reports progress after a phase and stops on cancellation
+	// or when an entry hit the decompression cap. A fatal phase ends
+	// validation, so it reports 100%.
+	phaseDone := func(phase string, fatal bool) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// OCF-031: an entry decompressed past the cap
+		if err := ep.DecompressionErr(); err != nil {
+			r.Add(report.Fatal, "OCF-031", "Validation stopped: "+err.Error())
+			if opts.Progress != nil {
+				opts.Progress(phase, 100)
+			}
+			return err
+		}
+		done++
+		if opts.Progress != nil {
+			pct := done * 100 / total
+			if fatal {
+				pct = 100
+			}
+			opts.Progress(phase, pct)
+		}
+		return nil
+	}
+
+	// Phase 1: OCF container checks
+	fatal := checkOCF(ep, r, opts)
+	if err := phaseDone("ocf", fatal); err != nil || fatal {
+		return err
+	}
+
+	// Phase 2: Parse and check OPF
+	fatal = checkOPF(ep, r, opts)
+	if ep.Package != nil {
+		r.Meta.Version = ep.Package.Version
+		if len(ep.Package.Metadata.Titles) > 0 {
+			r.Meta.Title = ep.Package.Metadata.Titles[0]
+		}
+	}
+	if err := phaseDone("opf", fatal); err != nil || fatal {
+		return err
+	}
 
 	// Phase 3: Cross-reference checks
 	checkReferences(ep, r, opts)
+	if err := phaseDone("references", false); err != nil {
+		return err
+	}
 
 	// Phase 4: Navigation document checks
-	checkNavigation(ep, r)
+	checkNavigation(ep, r, opts.Strict)
+	if err := phaseDone("navigation", false); err != nil {
+		return err
+	}
 
 	// Phase 5: Encoding checks (before content to identify bad files)
 	badEncoding := checkEncoding(ep, r)
+	if err := phaseDone("encoding", false); err != nil {
+		return err
+	}
 
 	// Phase 6: Content document checks
-	checkContentWithSkips(ep, r, badEncoding)
+	checkContentWithSkips(ep, r, badEncoding, opts)
+	if err := phaseDone("content", false); err != nil {
+		return err
+	}
 
 	// Phase 7: CSS checks
-	checkCSS(ep, r)
+	checkCSS(ep, r, opts)
+	if err := phaseDone("css", false); err != nil {
+		return err
+	}
 
 	// Phase 8: Fixed-layout checks
-	checkFXL(ep, r)
+	checkFXL(ep, r, opts)
+	if err := phaseDone("fxl", false); err != nil {
+		return err
+	}
 
 	// Phase 9: Media checks
 	checkMedia(ep, r)
+	if err := phaseDone("media", false); err != nil {
+		return err
+	}
 
 	// Phase 10: EPUB 2 specific checks
 	checkEPUB2(ep, r)
+	if err := phaseDone("epub2", false); err != nil {
+		return err
+	}
 
 	// Phase 11: Accessibility checks (opt-in, not flagged by epubcheck without --profile)
 	if opts.Accessibility {
 		checkAccessibility(ep, r)
+		if err := phaseDone("accessibility", false); err != nil {
+			return err
+		}
+	}
+
+	// Phase 12: Quality heuristics (opt-in)
+	if opts.Quality {
+		checkQuality(ep, r, opts)
+		if err := phaseDone("quality", false); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// validateRenditions runs validateEPUB once per rootfile in container.xml
+// and merges the results into r. Container (OCF) messages and messages
+// about a file that more than one rendition reported, such as a shared
+// stylesheet, are added once; the rest are prefixed with the package
+// document path of the rendition, or renditions, that reported them.
+// r.Meta describes the first rendition.
+func validateRenditions(ctx context.Context, ep *epub.EPUB, r *report.Report, opts Options) error {
+	var paths []string
+	for _, rf := range ep.AllRootfiles {
+		paths = append(paths, rf.FullPath)
+	}
+
+	// reportedBy lists, for each container or file message, the
+	// renditions that reported it, so it is added once for all of them.
+	reportedBy := make(map[report.Message][]string)
+	var results [][]report.Message
+	for i, rootfile := range paths {
+		sub := opts
+		sub.Rootfile = rootfile
+		ep.Package = nil
+		ep.OPFParseError = nil
 
-	return r, nil
+		rr := report.NewReport()
+		err := validateEPUB(ctx, ep, rr, sub)
+		if i == 0 {
+			r.Meta = rr.Meta
+		}
+		results = append(results, rr.Messages)
+		inRendition := make(map[report.Message]bool)
+		for _, m := range rr.Messages {
+			if sharedMessage(m) && !inRendition[m] {
+				inRendition[m] = true
+				reportedBy[m] = append(reportedBy[m], rootfile)
+			}
+		}
+		// validateEPUB hides the decompression cap from its caller; stop
+		// here rather than decompress the remaining renditions.
+		if err == nil {
+			err = ep.DecompressionErr()
+		}
+		if err != nil {
+			mergeRenditions(r, paths, results, reportedBy)
+			return err
+		}
+	}
+	mergeRenditions(r, paths, results, reportedBy)
+	return nil
+}
+
+// sharedMessage reports whether m may be reported identically by several
+// renditions: a container message, or one about a particular file.
+func sharedMessage(m report.Message) bool {
+	return strings.HasPrefix(m.CheckID, "OCF-") || m.Location != ""
+}
+
+// mergeRenditions adds the messages of each rendition in results to r.
+// A shared message is added with the first rendition that reported it,
+// and repeats within that rendition are kept, so counts match validating
+// the renditions one at a time.
+func mergeRenditions(r *report.Report, paths []string, results [][]report.Message, reportedBy map[report.Message][]string) {
+	for i, msgs := range results {
+		for _, m := range msgs {
+			prefix := paths[i]
+			if sharedMessage(m) {
+				by := reportedBy[m]
+				if by[0] != paths[i] {
+					continue
+				}
+				prefix = strings.Join(by, ", ")
+			}
+			if !strings.HasPrefix(m.CheckID, "OCF-") {
+				m.Message = fmt.Sprintf("[%s] %s", prefix, m.Message)
+			}
+			r.AddMessage(m)
+		}
+	}
 }
@@ -1,12 +1,21 @@
 package validate
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
+
+	"github.com/adammathes/epubverify/pkg/report"
 )
 
 func specDir(t *testing.T) string {
@@ -165,3 +174,795 @@ func TestValidateAgainstExpected(t *testing.T) {
 		}
 	}
 }
+
+const testContainerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+const testNavXHTML = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>Navigation</title></head>
+<body>
+<nav epub:type="toc"><ol><li><a href="chapter1.xhtml">Chapter 1</a></li></ol></nav>
+</body>
+</html>`
+
+const testChapterXHTML = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter 1</title></head>
+<body><p>Hello world</p></body>
+</html>`
+
+// testOPF returns a minimal EPUB 3 package document with extra manifest
+// items inserted after the nav and chapter entries.
+func testOPF(extraManifest string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:12345678-1234-1234-1234-123456789012</dc:identifier>
+    <dc:title>Test Book</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">2024-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+` + extraManifest + `  </manifest>
+  <spine>
+    <itemref idref="ch1"/>
+  </spine>
+</package>`
+}
+
+// writeTestEPUB builds an EPUB in a temp dir from the given files and
+// returns its path. The mimetype and container.xml are always written
+// first; OEBPS/nav.xhtml and OEBPS/chapter1.xhtml get defaults unless
+// provided.
+func writeTestEPUB(t *testing.T, files map[string]string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "test.epub")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	mw, err := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw.Write([]byte("application/epub+zip"))
+
+	defaults := map[string]string{
+		"META-INF/container.xml": testContainerXML,
+		"OEBPS/content.opf":      testOPF(""),
+		"OEBPS/nav.xhtml":        testNavXHTML,
+		"OEBPS/chapter1.xhtml":   testChapterXHTML,
+	}
+	for name, data := range files {
+		defaults[name] = data
+	}
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cw.Write([]byte(defaults[name]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// hasCheck reports whether the report contains a message with the given check ID.
+func hasCheck(r *report.Report, checkID string) bool {
+	for _, m := range r.Messages {
+		if m.CheckID == checkID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateBytesStreamsMessages(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="missing" href="missing.png" media-type="image/png"/>
+`),
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var streamed []report.Message
+	r, err := ValidateBytes(data, Options{Sink: func(m report.Message) {
+		streamed = append(streamed, m)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Messages) == 0 {
+		t.Fatal("expected messages for a missing manifest resource")
+	}
+	if len(streamed) != len(r.Messages) {
+		t.Fatalf("streamed %d messages, report has %d", len(streamed), len(r.Messages))
+	}
+	// Messages stream in the order they are found; the report is sorted.
+	sorted := &report.Report{Messages: streamed}
+	sorted.Sort()
+	for i := range sorted.Messages {
+		if sorted.Messages[i] != r.Messages[i] {
+			t.Errorf("message %d: streamed %v, report has %v", i, sorted.Messages[i], r.Messages[i])
+		}
+	}
+}
+
+func TestValidateSelectedRootfile(t *testing.T) {
+	container := `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+    <rootfile full-path="OEBPS/alt.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+	// The alternate rendition is missing dc:title
+	alt := strings.Replace(testOPF(""), "<dc:title>Test Book</dc:title>", "", 1)
+	path := writeTestEPUB(t, map[string]string{
+		"META-INF/container.xml": container,
+		"OEBPS/alt.opf":          alt,
+	})
+
+	r, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "OPF-001") {
+		t.Error("default validation should use the first rootfile")
+	}
+	if !hasCheck(r, "OCF-019") || hasCheck(r, "OCF-017") {
+		t.Errorf("expected only OCF-019 noting which rootfile was validated, got %v", r.Messages)
+	}
+
+	r, err = ValidateWithOptions(path, Options{Rootfile: "OEBPS/alt.opf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "OPF-001") {
+		t.Error("expected OPF-001 from the selected rootfile")
+	}
+
+	r, err = ValidateWithOptions(path, Options{Rootfile: "OEBPS/nope.opf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.FatalCount() != 1 || !hasCheck(r, "OCF-017") || hasCheck(r, "OCF-019") {
+		t.Errorf("expected a single OCF-017 fatal for an unlisted rootfile, got %v", r.Messages)
+	}
+}
+
+func TestValidateAllRenditions(t *testing.T) {
+	container := `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container"
+    xmlns:rendition="http://www.idpf.org/2013/rendition">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+    <rootfile full-path="OEBPS/alt.opf" media-type="application/oebps-package+xml" rendition:layout="pre-paginated"/>
+    <rootfile full-path="OEBPS/third.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+	// Both alternates are missing dc:title
+	alt := strings.Replace(testOPF(""), "<dc:title>Test Book</dc:title>", "", 1)
+	path := writeTestEPUB(t, map[string]string{
+		"META-INF/container.xml": container,
+		"OEBPS/alt.opf":          alt,
+		"OEBPS/third.opf":        alt,
+	})
+
+	r, err := ValidateWithOptions(path, Options{AllRenditions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages {
+		switch m.CheckID {
+		case "OPF-001", "OCF-017", "OCF-019", "OCF-020":
+			got = append(got, m.CheckID+" "+m.Message)
+		}
+	}
+	want := []string{
+		"OPF-001 [OEBPS/alt.opf] Package metadata is missing required element dc:title",
+		"OPF-001 [OEBPS/third.opf] Package metadata is missing required element dc:title",
+		"OCF-020 Rootfile 'OEBPS/third.opf' is an alternate rendition but declares no rendition:* selection attributes (rendition:media, rendition:layout, rendition:language, rendition:accessMode or rendition:label)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if r.Meta.Title != "Test Book" {
+		t.Errorf("Meta.Title = %q, want the first rendition's title", r.Meta.Title)
+	}
+
+	// A chapter shared by every rendition, with the same broken reference
+	// twice: both findings are kept, once for all three renditions.
+	chapter := strings.Replace(testChapterXHTML, "</body>",
+		`<img src="missing.png" alt=""/><img src="missing.png" alt=""/></body>`, 1)
+	path = writeTestEPUB(t, map[string]string{
+		"META-INF/container.xml": container,
+		"OEBPS/alt.opf":          alt,
+		"OEBPS/third.opf":        alt,
+		"OEBPS/chapter1.xhtml":   chapter,
+	})
+	single, err := ValidateWithOptions(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = ValidateWithOptions(path, Options{AllRenditions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var singleCount, allCount int
+	for _, m := range single.Messages {
+		if m.CheckID == "RSC-007" {
+			singleCount++
+		}
+	}
+	for _, m := range r.Messages {
+		if m.CheckID == "RSC-007" {
+			allCount++
+			if !strings.HasPrefix(m.Message, "[OEBPS/content.opf, OEBPS/alt.opf, OEBPS/third.opf] ") {
+				t.Errorf("RSC-007 message = %q, want it attributed to all three renditions", m.Message)
+			}
+		}
+	}
+	if singleCount != 2 || allCount != singleCount {
+		t.Errorf("RSC-007 count = %d across renditions, %d for one; want 2 for both", allCount, singleCount)
+	}
+}
+
+func TestValidateBytesFull(t *testing.T) {
+	data, err := os.ReadFile(writeTestEPUB(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, ep, err := ValidateBytesFull(data, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Close()
+	if r.FatalCount() != 0 {
+		t.Fatalf("unexpected fatal messages: %v", r.Messages)
+	}
+	if ep.Package == nil || len(ep.Package.Metadata.Titles) != 1 || ep.Package.Metadata.Titles[0] != "Test Book" {
+		t.Fatalf("expected parsed package with title, got %+v", ep.Package)
+	}
+	if _, err := ep.ReadFile("OEBPS/chapter1.xhtml"); err != nil {
+		t.Errorf("reading from returned EPUB: %v", err)
+	}
+
+	r, ep, err = ValidateBytesFull([]byte("not a zip"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep != nil || !hasCheck(r, "PKG-000") {
+		t.Errorf("expected PKG-000 and nil EPUB for invalid data, got %v, %v", r.Messages, ep)
+	}
+}
+
+func TestQuick(t *testing.T) {
+	opf := strings.Replace(testOPF(`    <item id="img" href="missing.png" media-type="image/png"/>
+`), "<dc:title>Test Book</dc:title>", "", 1)
+	path := writeTestEPUB(t, map[string]string{"OEBPS/content.opf": opf})
+
+	r, err := Quick(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "OPF-001") {
+		t.Errorf("expected OPF-001 for a missing title, got %v", r.Messages)
+	}
+	for _, m := range r.Messages {
+		if !strings.HasPrefix(m.CheckID, "OCF-") && !strings.HasPrefix(m.CheckID, "OPF-") && m.CheckID != "FONT-004" {
+			t.Errorf("unexpected non-structural message from Quick: %s %s", m.CheckID, m.Message)
+		}
+	}
+
+	full, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(full, "RSC-001") {
+		t.Errorf("expected full validation to report the missing resource, got %v", full.Messages)
+	}
+
+	r, err = Quick(path, Options{MaxDecompressedBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "OCF-031") {
+		t.Errorf("expected OCF-031 for a package document over the cap, got %v", r.Messages)
+	}
+}
+
+func TestValidateDir(t *testing.T) {
+	chapter := strings.Replace(testChapterXHTML, "</body>", `<img src="missing.png" alt=""/></body>`, 1)
+	zipped := writeTestEPUB(t, map[string]string{"OEBPS/chapter1.xhtml": chapter})
+
+	zr, err := zip.OpenReader(zipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	dir := t.TempDir()
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Version control and editor files are not part of the book.
+	for _, name := range []string{".git/config", "OEBPS/.chapter1.xhtml.swp"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("junk"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := Validate(zipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ValidateDir(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(got, "OCF-026") {
+		t.Errorf("expected OCF-026 noting the zip checks were skipped, got %v", got.Messages)
+	}
+	var rest []report.Message
+	for _, m := range got.Messages {
+		if m.CheckID != "OCF-026" {
+			rest = append(rest, m)
+		}
+	}
+	if !hasCheck(want, "RSC-007") || fmt.Sprint(rest) != fmt.Sprint(want.Messages) {
+		t.Errorf("directory messages = %v, want the zipped book's %v", rest, want.Messages)
+	}
+
+	r, err := ValidateDir(dir, Options{MaxDecompressedBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "OCF-031") {
+		t.Errorf("expected OCF-031 for a file over the cap, got %v", r.Messages)
+	}
+
+	r, err = ValidateDir(filepath.Join(dir, "nope"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "PKG-000") {
+		t.Errorf("expected PKG-000 for a missing directory, got %v", r.Messages)
+	}
+}
+
+func TestUnsafeEntryPath(t *testing.T) {
+	zr, err := zip.OpenReader(writeTestEPUB(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		if err := w.Copy(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evil, err := w.Create("../../etc/evil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	evil.Write([]byte("pwned"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ValidateBytes(buf.Bytes(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Messages) != 1 || r.Messages[0].CheckID != "OCF-030" || r.Messages[0].Severity != report.Fatal {
+		t.Fatalf("got %v, want a single OCF-030 fatal", r.Messages)
+	}
+	if !strings.Contains(r.Messages[0].Message, "../../etc/evil") {
+		t.Errorf("message %q should name the entry", r.Messages[0].Message)
+	}
+}
+
+func TestMaxDecompressedBytes(t *testing.T) {
+	chapter := strings.Replace(testChapterXHTML, "</body>", "<!--"+strings.Repeat(" ", 64<<10)+"--></body>", 1)
+	path := writeTestEPUB(t, map[string]string{"OEBPS/chapter1.xhtml": chapter})
+
+	r, err := ValidateWithOptions(path, Options{MaxDecompressedBytes: 16 << 10})
+	if err != nil {
+		t.Fatalf("ValidateWithOptions error = %v, want a report", err)
+	}
+	if !hasCheck(r, "OCF-031") || r.FatalCount() != 1 {
+		t.Errorf("expected a single OCF-031 fatal, got %v", r.Messages)
+	}
+
+	r, err = Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "OCF-031") {
+		t.Errorf("the default cap should allow a 64 KiB chapter, got %v", r.Messages)
+	}
+}
+
+func TestMaxDecompressedBytesAllRenditions(t *testing.T) {
+	container := `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+    <rootfile full-path="OEBPS/alt.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+	chapter := strings.Replace(testChapterXHTML, "</body>", "<!--"+strings.Repeat(" ", 64<<10)+"--></body>", 1)
+	path := writeTestEPUB(t, map[string]string{
+		"META-INF/container.xml": container,
+		"OEBPS/chapter1.xhtml":   chapter,
+		"OEBPS/alt.opf":          testOPF(""),
+	})
+
+	var finished []string
+	r, err := ValidateWithOptions(path, Options{
+		AllRenditions:        true,
+		MaxDecompressedBytes: 16 << 10,
+		Progress: func(phase string, pct int) {
+			if pct == 100 {
+				finished = append(finished, phase)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("ValidateWithOptions error = %v, want a report", err)
+	}
+	if r.FatalCount() != 1 || !hasCheck(r, "OCF-031") {
+		t.Errorf("expected a single OCF-031 fatal, got %v", r.Messages)
+	}
+	if len(finished) != 1 {
+		t.Errorf("validation should stop at the first rendition over the cap, but phases %v ended it", finished)
+	}
+}
+
+func TestEntrySizeLimits(t *testing.T) {
+	zr, err := zip.OpenReader(writeTestEPUB(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		if err := w.Copy(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Declared (zip64) size only; the entry is never read.
+	if _, err := w.CreateRaw(&zip.FileHeader{
+		Name:               "OEBPS/huge.mp3",
+		Method:             zip.Store,
+		UncompressedSize64: 5 << 30,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ValidateBytes(buf.Bytes(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "OCF-025") {
+		t.Errorf("expected OCF-025 for an entry over the read limit, got %v", r.Messages)
+	}
+	for _, m := range r.Messages {
+		if strings.HasPrefix(m.CheckID, "OCF-00") {
+			t.Errorf("unexpected container error for a valid zip64 entry: %s", m)
+		}
+	}
+}
+
+func TestValidateReaderMatchesValidateBytes(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="missing" href="missing.png" media-type="image/png"/>
+`),
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ValidateBytes(data, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := ValidateReader(f, int64(len(data)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Messages) != len(want.Messages) {
+		t.Fatalf("ValidateReader reported %d messages, ValidateBytes %d", len(got.Messages), len(want.Messages))
+	}
+	for i := range got.Messages {
+		if got.Messages[i] != want.Messages[i] {
+			t.Errorf("message %d: %v, want %v", i, got.Messages[i], want.Messages[i])
+		}
+	}
+}
+
+func TestValidateWithContextCancelled(t *testing.T) {
+	path := writeTestEPUB(t, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ValidateWithContext(ctx, path, Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("ValidateWithContext error = %v, want context.Canceled", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateBytesWithContext(ctx, data, Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("ValidateBytesWithContext error = %v, want context.Canceled", err)
+	}
+
+	r, err := ValidateWithContext(context.Background(), path, Options{})
+	if err != nil {
+		t.Fatalf("ValidateWithContext with live context: %v", err)
+	}
+	if !r.IsValid() {
+		t.Errorf("expected valid report, got %v", r.Messages)
+	}
+}
+
+func TestValidateMinSeverity(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": strings.Replace(testOPF(""), "2024-01-01T00:00:00Z", "2024-01-01T00:00", 1),
+	})
+
+	r, err := ValidateWithOptions(path, Options{LenientTimestamps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.WarningCount() == 0 {
+		t.Fatalf("expected a warning for a lenient dcterms:modified, got %v", r.Messages)
+	}
+
+	r, err = ValidateWithOptions(path, Options{LenientTimestamps: true, MinSeverity: report.Error})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range r.Messages {
+		if !m.Severity.AtLeast(report.Error) {
+			t.Errorf("MinSeverity Error kept %v", m)
+		}
+	}
+}
+
+func TestValidateCheckFiltering(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/content.opf": testOPF(`    <item id="missing" href="missing.png" media-type="image/png"/>
+`),
+	})
+
+	r, err := ValidateWithOptions(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCheck(r, "RSC-001") {
+		t.Fatalf("expected RSC-001 for a missing manifest resource, got %v", r.Messages)
+	}
+
+	r, err = ValidateWithOptions(path, Options{DisabledChecks: []string{"RSC-001"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "RSC-001") {
+		t.Error("DisabledChecks should drop RSC-001")
+	}
+
+	r, err = ValidateWithOptions(path, Options{EnabledChecks: []string{"OPF-019"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Messages) != 0 {
+		t.Errorf("EnabledChecks should keep only OPF-019, got %v", r.Messages)
+	}
+}
+
+func TestValidateProgress(t *testing.T) {
+	path := writeTestEPUB(t, nil)
+
+	var phases []string
+	var pcts []int
+	opts := Options{Quality: true, Progress: func(phase string, pct int) {
+		phases = append(phases, phase)
+		pcts = append(pcts, pct)
+	}}
+	if _, err := ValidateWithOptions(path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(phases) != 11 || phases[0] != "ocf" || phases[10] != "quality" {
+		t.Fatalf("phases = %v", phases)
+	}
+	for i := 1; i < len(pcts); i++ {
+		if pcts[i] <= pcts[i-1] {
+			t.Errorf("progress not increasing: %v", pcts)
+			break
+		}
+	}
+	if pcts[len(pcts)-1] != 100 {
+		t.Errorf("final progress = %d, want 100", pcts[len(pcts)-1])
+	}
+
+	phases, pcts = nil, nil
+	broken := writeTestEPUB(t, map[string]string{"OEBPS/content.opf": "<package"})
+	if _, err := ValidateWithOptions(broken, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(phases) != 2 || phases[1] != "opf" || pcts[1] != 100 {
+		t.Errorf("fatal OPF should end progress at opf with 100, got %v %v", phases, pcts)
+	}
+}
+
+func TestTotalUncompressedSize(t *testing.T) {
+	path := writeTestEPUB(t, map[string]string{
+		"OEBPS/big.css": strings.Repeat("p { margin: 0; }\n", 200),
+	})
+
+	r, err := ValidateWithOptions(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "OCF-018") {
+		t.Error("OCF-018 should not fire without MaxUncompressedBytes")
+	}
+
+	r, err = ValidateWithOptions(path, Options{MaxUncompressedBytes: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg string
+	for _, m := range r.Messages {
+		if m.CheckID == "OCF-018" {
+			msg = m.Message
+		}
+	}
+	if !strings.Contains(msg, "largest entries: OEBPS/big.css (3400 bytes)") {
+		t.Errorf("expected OCF-018 leading with big.css, got %q", msg)
+	}
+
+	r, err = ValidateWithOptions(path, Options{MaxUncompressedBytes: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCheck(r, "OCF-018") {
+		t.Error("OCF-018 should not fire under the limit")
+	}
+}
+
+func TestReportMeta(t *testing.T) {
+	r, err := Validate(writeTestEPUB(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Meta.Version != "3.0" || r.Meta.Title != "Test Book" {
+		t.Errorf("Meta = %+v, want version 3.0 and title Test Book", r.Meta)
+	}
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"version": "3.0"`) || !strings.Contains(buf.String(), `"title": "Test Book"`) {
+		t.Errorf("JSON output missing version or title:\n%s", buf.String())
+	}
+
+	path := writeTestEPUB(t, map[string]string{
+		"META-INF/container.xml": strings.Replace(testContainerXML, "OEBPS/content.opf", "OEBPS/missing.opf", 1),
+	})
+	r, err = Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Meta != (report.Meta{}) {
+		t.Errorf("Meta = %+v, want empty when the package document is missing", r.Meta)
+	}
+}
+
+func TestValidateConcurrency(t *testing.T) {
+	files := map[string]string{}
+	var manifest, spine strings.Builder
+	for i := 1; i <= 12; i++ {
+		name := fmt.Sprintf("ch%02d.xhtml", i)
+		files["OEBPS/"+name] = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Chapter</title></head>
+<body><p id="a" onclick="go()">One</p><p id="a">Two</p><a href="missing.xhtml">x</a><center>old</center></body>
+</html>`
+		fmt.Fprintf(&manifest, "    <item id=\"c%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i, name)
+		fmt.Fprintf(&spine, "<itemref idref=\"c%d\"/>", i)
+	}
+	// A spine SVG document with the wrong root, for HTM-034
+	files["OEBPS/page.svg"] = `<?xml version="1.0" encoding="UTF-8"?><svg xmlns="http://example.com/not-svg"/>`
+	manifest.WriteString(`    <item id="page" href="page.svg" media-type="image/svg+xml"/>` + "\n")
+	spine.WriteString(`<itemref idref="page"/>`)
+	files["OEBPS/content.opf"] = strings.Replace(testOPF(manifest.String()), `<itemref idref="ch1"/>`, `<itemref idref="ch1"/>`+spine.String(), 1)
+	path := writeTestEPUB(t, files)
+
+	serial, err := ValidateWithOptions(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serial.Messages) < 12 {
+		t.Fatalf("expected messages from every chapter, got %d", len(serial.Messages))
+	}
+	var streamed int
+	parallel, err := ValidateWithOptions(path, Options{Concurrency: 4, Sink: func(report.Message) { streamed++ }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parallel.Messages) != len(serial.Messages) {
+		t.Fatalf("Concurrency 4 gave %d messages, serial gave %d", len(parallel.Messages), len(serial.Messages))
+	}
+	for i := range serial.Messages {
+		if parallel.Messages[i] != serial.Messages[i] {
+			t.Errorf("message %d: got %v, want %v", i, parallel.Messages[i], serial.Messages[i])
+		}
+	}
+	if streamed != len(parallel.Messages) {
+		t.Errorf("Sink saw %d messages, want %d", streamed, len(parallel.Messages))
+	}
+	if !hasCheck(parallel, "HTM-034") || !hasCheck(parallel, "HTM-039") {
+		t.Errorf("expected HTM-034 and HTM-039 from the worker pool, got %v", parallel.Messages)
+	}
+}